@@ -8,10 +8,12 @@ import (
 	"os"
 
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/slyt3/Logryph/internal/assert"
 	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
 	"github.com/slyt3/Logryph/internal/pool"
 )
 
@@ -63,10 +65,119 @@ func EventsCommand() {
 		if idx < 0 {
 			break
 		}
-		e := events[idx]
-		fmt.Printf("[%d] %s | %s | %s\n", e.SeqIndex, e.ID[:8], e.EventType, e.Method)
-		if e.WasBlocked {
-			fmt.Print("    BLOCKED\n")
+		printEvent(&events[idx])
+	}
+}
+
+// printEvent prints one event of the events listing with the details its type
+// and transport recorded.
+func printEvent(e *models.Event) {
+	fmt.Printf("[%d] %s | %s | %s\n", e.SeqIndex, e.ID[:8], e.EventType, e.Method)
+	if e.WasBlocked {
+		fmt.Print("    BLOCKED\n")
+	}
+	if e.BatchID != "" {
+		fmt.Printf("    Batch: %s\n", e.BatchID)
+	}
+	if e.SessionID != "" {
+		fmt.Printf("    Session: %s\n", e.SessionID)
+	}
+	if e.BodyStorage != "" {
+		fmt.Printf("    Body: %d bytes, sha256 %s (%s)\n", e.BodySize, e.BodySHA256, e.BodyStorage)
+	}
+	if e.ParentID != "" && (e.EventType == "tool_response" || e.EventType == "tool_error") {
+		fmt.Printf("    Reply to: %s (%dms)\n", e.ParentID, e.LatencyMs)
+	}
+	if e.EventType == "approval_decision" {
+		printDecision(e)
+	}
+	if e.Upstream != "" {
+		fmt.Printf("    Upstream: %s\n", e.Upstream)
+	}
+	if e.HTTPStatus != 0 {
+		fmt.Printf("    HTTP: %d\n", e.HTTPStatus)
+	}
+	if e.TraceID != "" {
+		fmt.Printf("    Trace: %s span %s\n", e.TraceID, e.SpanID)
+	}
+	if e.HTTP != nil {
+		fmt.Printf("    Client: %s %s %s\n", e.HTTP.ClientAddr, e.HTTP.Method, e.HTTP.Path)
+		printHeaders("Request", e.HTTP.RequestHeaders)
+		printHeaders("Response", e.HTTP.ResponseHeaders)
+	}
+}
+
+// printDecision prints the decision line of an approval_decision event.
+func printDecision(e *models.Event) {
+	fmt.Printf("    Decision: %v on %s by %s", e.Params["decision"], e.ParentID, e.Actor)
+	if approver, ok := e.Params["approver"].(string); ok {
+		fmt.Printf(" (%s via %v)", approver, e.Params["credential"])
+	}
+	if note, ok := e.Params["approver_note"].(string); ok {
+		fmt.Printf(" [says %q]", note)
+	}
+	if reason, ok := e.Params["reason"].(string); ok {
+		fmt.Printf(": %s", reason)
+	}
+	fmt.Println()
+}
+
+func printHeaders(label string, headers map[string]string) {
+	const maxHeaders = 64
+	if err := assert.Check(len(headers) <= maxHeaders, "headers exceed max: %d", len(headers)); err != nil {
+		return
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i := 0; i < len(names); i++ {
+		fmt.Printf("    %s %s: %s\n", label, names[i], headers[names[i]])
+	}
+}
+
+// BatchCommand lists every event recorded as part of one JSON-RPC batch.
+func BatchCommand() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: logyctl batch <batch-id>")
+		os.Exit(1)
+	}
+	batchID := os.Args[2]
+
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+
+	events, err := db.GetEventsByBatchID(batchID)
+	if err := assert.Check(err == nil, "failed to get batch events: %v", err); err != nil {
+		log.Fatalf("Failed to get batch events: %v", err)
+	}
+	if len(events) == 0 {
+		fmt.Printf("No events found for batch %s\n", batchID)
+		return
+	}
+	const maxBatchEvents = 10000
+	if err := assert.Check(len(events) <= maxBatchEvents, "batch events exceed max: %d", len(events)); err != nil {
+		log.Fatalf("Batch events exceed max: %v", err)
+	}
+
+	fmt.Printf("Batch %s (%d events)\n", batchID, len(events))
+	fmt.Println("===========================")
+	for i := 0; i < maxBatchEvents; i++ {
+		if i >= len(events) {
+			break
+		}
+		e := events[i]
+		fmt.Printf("[%d] %s | %-13s | %s\n", e.SeqIndex, e.ID[:8], e.EventType, e.Method)
+		if e.RiskLevel != "" {
+			fmt.Printf("    Risk: %s (%s)\n", e.RiskLevel, e.PolicyID)
 		}
 	}
 }
@@ -100,6 +211,7 @@ func StatsCommand() {
 	fmt.Printf("Total Events:    %d\n", stats.TotalEvents)
 	fmt.Printf("Tool Calls:      %d\n", stats.CallCount)
 	fmt.Printf("Blocked Calls:   %d\n", stats.BlockedCount)
+	fmt.Printf("Tool Errors:     %d\n", stats.ErrorCount)
 	fmt.Println("\nRisk Breakdown:")
 	if len(stats.RiskBreakdown) == 0 {
 		fmt.Println("  None")
@@ -161,6 +273,9 @@ func printPoolMetric(name string, hits, misses uint64) {
 	fmt.Printf("%-12s | Hits: %-5d | Misses: %-5d | Efficiency: %.1f%%\n", name, hits, misses, rate)
 }
 
+// maxRiskEvents bounds the events listed by the risk command.
+const maxRiskEvents = 10000
+
 func RiskCommand() {
 	db, err := store.NewDB("logryph.db")
 	if err != nil {
@@ -177,15 +292,28 @@ func RiskCommand() {
 		log.Fatalf("Failed to get risky events: %v", err)
 	}
 
-	if len(risky) == 0 {
-		fmt.Println("[OK] No high-risk events detected")
-		return
+	errorEvents, err := db.GetErrorEvents()
+	if err := assert.Check(err == nil, "failed to get error events: %v", err); err != nil {
+		log.Fatalf("Failed to get error events: %v", err)
 	}
-	const maxRiskEvents = 10000
+
 	if err := assert.Check(len(risky) <= maxRiskEvents, "risky events exceed max: %d", len(risky)); err != nil {
 		log.Fatalf("Risky events exceed max: %v", err)
 	}
+	if err := assert.Check(len(errorEvents) <= maxRiskEvents, "error events exceed max: %d", len(errorEvents)); err != nil {
+		log.Fatalf("Error events exceed max: %v", err)
+	}
+
+	printRiskEvents(risky)
+	printErrorEvents(errorEvents)
+}
 
+// printRiskEvents lists the events tagged with a high or critical risk level.
+func printRiskEvents(risky []models.Event) {
+	if len(risky) == 0 {
+		fmt.Println("[OK] No high-risk events detected")
+		return
+	}
 	fmt.Printf("High-Risk Events Found: %d\n", len(risky))
 	fmt.Println("==========================")
 	for i := 0; i < maxRiskEvents; i++ {
@@ -199,3 +327,22 @@ func RiskCommand() {
 		}
 	}
 }
+
+// printErrorEvents lists tool_error events with their JSON-RPC code and message.
+func printErrorEvents(errorEvents []models.Event) {
+	if len(errorEvents) == 0 {
+		return
+	}
+	fmt.Printf("\nTool Errors Found: %d\n", len(errorEvents))
+	fmt.Println("=====================")
+	for i := 0; i < maxRiskEvents; i++ {
+		if i >= len(errorEvents) {
+			break
+		}
+		e := errorEvents[i]
+		fmt.Printf("[%v] %-8s | %s | %v\n", e.Response["code"], e.ID[:8], e.Method, e.Response["message"])
+		if e.ParentID != "" {
+			fmt.Printf("    Call: %s\n", e.ParentID)
+		}
+	}
+}
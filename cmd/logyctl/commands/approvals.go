@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/approval"
+)
+
+// ApprovalsCommand lists calls held by require_approval rules, or approves or
+// rejects one, through the admin API of a running proxy.
+func ApprovalsCommand() {
+	if len(os.Args) < 3 {
+		printApprovalsUsage()
+		os.Exit(1)
+	}
+
+	flags := flag.NewFlagSet("approvals", flag.ExitOnError)
+	adminURL := flags.String("admin", "http://localhost:9998", "Logryph admin API URL")
+	approver := flags.String("by", os.Getenv("USER"), "Name noted with the decision (unverified; the approver is the credential used)")
+	reason := flags.String("reason", "", "Reason recorded with the decision")
+
+	switch sub := os.Args[2]; sub {
+	case "list":
+		_ = flags.Parse(os.Args[3:])
+		listApprovals(*adminURL)
+	case "approve", "reject":
+		if len(os.Args) < 4 {
+			fmt.Printf("Error: approvals %s requires an approval ID\n", sub)
+			printApprovalsUsage()
+			os.Exit(1)
+		}
+		_ = flags.Parse(os.Args[4:])
+		decideApproval(*adminURL, os.Args[3], sub, *approver, *reason)
+	default:
+		fmt.Printf("Unknown approvals command: %s\n", sub)
+		printApprovalsUsage()
+		os.Exit(1)
+	}
+}
+
+func printApprovalsUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  logyctl approvals list")
+	fmt.Println("  logyctl approvals approve <id> [--by NAME] [--reason TEXT]")
+	fmt.Println("  logyctl approvals reject <id> [--by NAME] [--reason TEXT]")
+	fmt.Println("Flags: --admin URL (default http://localhost:9998); LOGRYPH_ADMIN_TOKEN is sent if set")
+}
+
+func listApprovals(adminURL string) {
+	body := adminRequest(http.MethodGet, adminURL+"/api/approvals", nil)
+
+	var pending []approval.Request
+	if err := json.Unmarshal(body, &pending); err != nil {
+		log.Fatalf("Invalid approvals response: %v", err)
+	}
+	if len(pending) == 0 {
+		fmt.Println("No calls awaiting approval.")
+		return
+	}
+
+	fmt.Printf("Pending Approvals (%d)\n", len(pending))
+	fmt.Println("=====================")
+	for i := 0; i < approval.MaxPending; i++ {
+		if i >= len(pending) {
+			break
+		}
+		p := pending[i]
+		fmt.Printf("%s | %s | policy %s", p.ID, p.Action, p.PolicyID)
+		if p.RiskLevel != "" {
+			fmt.Printf(" (%s)", p.RiskLevel)
+		}
+		fmt.Println()
+		fmt.Printf("    Waiting %s, expires in %s\n",
+			time.Since(p.RequestedAt).Round(time.Second), time.Until(p.Deadline).Round(time.Second))
+		if p.SessionID != "" {
+			fmt.Printf("    Session: %s\n", p.SessionID)
+		}
+		if p.Upstream != "" {
+			fmt.Printf("    Upstream: %s\n", p.Upstream)
+		}
+		if len(p.Params) > 0 {
+			fmt.Printf("    Params: %s\n", p.Params)
+		}
+	}
+}
+
+func decideApproval(adminURL, id, decision, approver, reason string) {
+	payload, err := json.Marshal(map[string]string{"approver": approver, "reason": reason})
+	if err != nil {
+		log.Fatalf("Failed to encode decision: %v", err)
+	}
+	adminRequest(http.MethodPost, adminURL+"/api/approvals/"+id+"/"+decision, payload)
+	if decision == "approve" {
+		fmt.Printf("[OK] %s approved\n", id)
+		return
+	}
+	fmt.Printf("[OK] %s rejected\n", id)
+}
+
+// adminRequest calls the admin API and returns the body of a 2xx response,
+// exiting with the server's message otherwise.
+func adminRequest(method, url string, payload []byte) []byte {
+	req, err := http.NewRequest(method, url, bytes.NewReader(payload))
+	if err != nil {
+		log.Fatalf("Invalid admin URL: %v", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := os.Getenv("LOGRYPH_ADMIN_TOKEN"); token != "" {
+		req.Header.Set("X-Admin-Token", token)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Fatalf("Failed to contact Logryph API: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Printf("Failed to close response: %v", err)
+		}
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16*1024*1024))
+	if err != nil {
+		log.Fatalf("Failed to read response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		fmt.Printf("Error: %s: %s\n", resp.Status, bytes.TrimSpace(body))
+		os.Exit(1)
+	}
+	return body
+}
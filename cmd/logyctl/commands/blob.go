@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/slyt3/Logryph/internal/blob"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// BlobCommand writes a payload that events reference by digest (an offloaded
+// request body or a response value) from the blob store, after checking its hash.
+func BlobCommand() {
+	if len(os.Args) < 4 || os.Args[2] != "get" {
+		fmt.Println("Usage: logyctl blob get <sha256> [-o FILE]")
+		os.Exit(1)
+	}
+	flags := flag.NewFlagSet("blob", flag.ExitOnError)
+	output := flags.String("o", "", "Write the blob to FILE instead of stdout")
+	_ = flags.Parse(os.Args[4:])
+
+	digest, err := blob.ParseRef(os.Args[3])
+	if err != nil {
+		log.Fatalf("Invalid digest: %v", err)
+	}
+	store := blob.NewStore(blob.DefaultDir)
+	if err := store.Verify(digest); err != nil {
+		log.Fatalf("Blob unavailable: %v", err)
+	}
+	src, err := store.Open(digest)
+	if err != nil {
+		log.Fatalf("Blob unavailable: %v", err)
+	}
+	defer func() {
+		if err := src.Close(); err != nil {
+			log.Printf("Failed to close blob: %v", err)
+		}
+	}()
+
+	dst := os.Stdout
+	if *output != "" {
+		dst, err = os.Create(*output)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *output, err)
+		}
+		defer func() {
+			if err := dst.Close(); err != nil {
+				log.Printf("Failed to close %s: %v", *output, err)
+			}
+		}()
+	}
+	n, err := io.Copy(dst, src)
+	if err != nil {
+		log.Fatalf("Failed to write blob: %v", err)
+	}
+	if *output != "" {
+		fmt.Printf("[OK] Wrote %d bytes to %s\n", n, *output)
+	}
+}
+
+// eventBlobRefs returns the sorted digests of the blobs that events reference:
+// offloaded request bodies and the response values each event's BlobRefs lists.
+// Payloads are not scanned, since an upstream can send reference-shaped values.
+func eventBlobRefs(events []models.Event) []string {
+	refs := make(map[string]bool)
+	for i := 0; i < len(events); i++ {
+		e := &events[i]
+		if e.BodyStorage == "offloaded" && blob.ValidDigest(e.BodySHA256) {
+			refs[e.BodySHA256] = true
+		}
+		for j := 0; j < len(e.BlobRefs); j++ {
+			if blob.ValidDigest(e.BlobRefs[j]) {
+				refs[e.BlobRefs[j]] = true
+			}
+		}
+	}
+	digests := make([]string, 0, len(refs))
+	for digest := range refs {
+		digests = append(digests, digest)
+	}
+	sort.Strings(digests)
+	return digests
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/slyt3/Logryph/internal/blob"
 	"github.com/slyt3/Logryph/internal/ledger"
 	"github.com/slyt3/Logryph/internal/ledger/store"
 )
@@ -20,6 +21,8 @@ type EvidenceManifest struct {
 	RunStats      *ledger.RunStats       `json:"run_stats"`
 	GenesisAnchor map[string]interface{} `json:"genesis_anchor"`
 	LastHash      string                 `json:"last_hash"`
+	Blobs         []string               `json:"blobs,omitempty"`         // Digests included under blobs/
+	MissingBlobs  []string               `json:"missing_blobs,omitempty"` // Referenced but absent or corrupt
 }
 
 func ExportCommand() {
@@ -42,7 +45,6 @@ func ExportCommand() {
 }
 
 func ExportEvidenceBag(zipPath, targetRunID string) error {
-	// 1. Open DB
 	db, err := store.NewDB("logryph.db")
 	if err != nil {
 		return fmt.Errorf("opening db: %w", err)
@@ -53,7 +55,6 @@ func ExportEvidenceBag(zipPath, targetRunID string) error {
 		}
 	}()
 
-	// 2. Identify Run ID
 	runID := targetRunID
 	if runID == "" {
 		runID, err = db.GetRunID()
@@ -65,15 +66,28 @@ func ExportEvidenceBag(zipPath, targetRunID string) error {
 		return fmt.Errorf("no runs found")
 	}
 
-	// 3. Gather Data
-	stats, err := db.GetRunStats(runID)
+	blobs := blob.NewStore(blob.DefaultDir)
+	manifest, err := buildManifest(db, runID, blobs)
 	if err != nil {
-		return fmt.Errorf("getting stats: %w", err)
+		return err
 	}
+	return writeEvidenceBag(zipPath, manifest, blobs)
+}
 
+// buildManifest gathers the run's stats, last hash and the blobs its events
+// reference. Blobs that are absent or fail verification are listed as missing.
+func buildManifest(db *store.DB, runID string, blobs *blob.Store) (EvidenceManifest, error) {
+	stats, err := db.GetRunStats(runID)
+	if err != nil {
+		return EvidenceManifest{}, fmt.Errorf("getting stats: %w", err)
+	}
 	_, lastHash, err := db.GetLastEvent(runID)
 	if err != nil {
-		return fmt.Errorf("getting last hash: %w", err)
+		return EvidenceManifest{}, fmt.Errorf("getting last hash: %w", err)
+	}
+	events, err := db.GetAllEvents(runID)
+	if err != nil {
+		return EvidenceManifest{}, fmt.Errorf("getting events: %w", err)
 	}
 
 	manifest := EvidenceManifest{
@@ -83,8 +97,20 @@ func ExportEvidenceBag(zipPath, targetRunID string) error {
 		RunStats:   stats,
 		LastHash:   lastHash,
 	}
+	for _, digest := range eventBlobRefs(events) {
+		if err := blobs.Verify(digest); err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] %v\n", err)
+			manifest.MissingBlobs = append(manifest.MissingBlobs, digest)
+			continue
+		}
+		manifest.Blobs = append(manifest.Blobs, digest)
+	}
+	return manifest, nil
+}
 
-	// 4. Create Zip
+// writeEvidenceBag writes the manifest, the raw database and the manifest's
+// blobs, each once under blobs/<sha256>, to a new zip file.
+func writeEvidenceBag(zipPath string, manifest EvidenceManifest, blobs *blob.Store) error {
 	f, err := os.Create(zipPath)
 	if err != nil {
 		return fmt.Errorf("creating zip file: %w", err)
@@ -102,7 +128,6 @@ func ExportEvidenceBag(zipPath, targetRunID string) error {
 		}
 	}()
 
-	// 5. Add Manifest
 	manFile, err := w.Create("manifest.json")
 	if err != nil {
 		return err
@@ -113,12 +138,23 @@ func ExportEvidenceBag(zipPath, targetRunID string) error {
 		return err
 	}
 
-	// 6. Add DB (Raw)
-	// We allow reading the DB even if locked by WAL, usually.
-	dbFile, err := os.Open("logryph.db")
+	if err := addDatabase(w, "logryph.db"); err != nil {
+		return err
+	}
+	for _, digest := range manifest.Blobs {
+		if err := addBlob(w, blobs, digest); err != nil {
+			return fmt.Errorf("adding blob %s: %w", digest, err)
+		}
+	}
+	return nil
+}
+
+// addDatabase copies the raw database file into the bag. Reading it usually
+// works even while the proxy holds it open in WAL mode.
+func addDatabase(w *zip.Writer, path string) error {
+	dbFile, err := os.Open(path)
 	if err != nil {
-		// Try to read generic way if locked
-		return fmt.Errorf("opening logryph.db: %w", err)
+		return fmt.Errorf("opening %s: %w", path, err)
 	}
 	defer func() {
 		if err := dbFile.Close(); err != nil {
@@ -126,13 +162,28 @@ func ExportEvidenceBag(zipPath, targetRunID string) error {
 		}
 	}()
 
-	destFile, err := w.Create("logryph.db")
+	destFile, err := w.Create(path)
 	if err != nil {
 		return err
 	}
-	if _, err := io.Copy(destFile, dbFile); err != nil {
+	_, err = io.Copy(destFile, dbFile)
+	return err
+}
+
+func addBlob(w *zip.Writer, blobs *blob.Store, digest string) error {
+	src, err := blobs.Open(digest)
+	if err != nil {
 		return err
 	}
-
-	return nil
+	defer func() {
+		if err := src.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to close blob: %v\n", err)
+		}
+	}()
+	dest, err := w.Create("blobs/" + digest)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dest, src)
+	return err
 }
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/mirror"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// MirrorCommand reports how a shadow tool server compared with the primary on
+// mirrored calls: a count per outcome, then the calls whose results diverged
+// (or, with --all, every mirrored call).
+func MirrorCommand() {
+	flags := flag.NewFlagSet("mirror", flag.ExitOnError)
+	all := flags.Bool("all", false, "List every mirrored call, not only divergences")
+	limit := flags.Int("limit", 50, "Maximum number of calls to list")
+	_ = flags.Parse(os.Args[2:])
+
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+
+	events, err := db.GetShadowResponses()
+	if err := assert.Check(err == nil, "failed to get shadow responses: %v", err); err != nil {
+		log.Fatalf("Failed to get shadow responses: %v", err)
+	}
+	if len(events) == 0 {
+		fmt.Println("No mirrored calls recorded in ledger.")
+		return
+	}
+
+	printOutcomeCounts(events)
+	printMirroredCalls(events, *all, *limit)
+}
+
+// printOutcomeCounts prints how many mirrored calls had each outcome.
+func printOutcomeCounts(events []models.Event) {
+	counts := make(map[string]int, len(mirror.Outcomes))
+	for i := 0; i < len(events); i++ {
+		outcome, _ := events[i].Params["outcome"].(string)
+		counts[outcome]++
+	}
+	fmt.Printf("Mirrored Calls (%d)\n", len(events))
+	fmt.Println("===================")
+	for i := 0; i < len(mirror.Outcomes); i++ {
+		fmt.Printf("  %-16s %d\n", mirror.Outcomes[i], counts[mirror.Outcomes[i]])
+	}
+}
+
+// printMirroredCalls lists up to limit shadow responses, newest first: the
+// divergent ones, or every one with all.
+func printMirroredCalls(events []models.Event, all bool, limit int) {
+	title := "Divergences"
+	if all {
+		title = "Calls"
+	}
+	fmt.Printf("\n%s (newest first):\n", title)
+	shown := 0
+	for i := 0; i < len(events) && shown < limit; i++ {
+		e := events[i]
+		outcome, _ := e.Params["outcome"].(string)
+		if !all && outcome == mirror.OutcomeMatch {
+			continue
+		}
+		shown++
+		fmt.Printf("[%d] %s | call %s | %-15s | %s | %dms\n", e.SeqIndex, e.ID, e.ParentID, outcome, e.Method, e.LatencyMs)
+		if msg, ok := e.Params["error"].(string); ok {
+			fmt.Printf("    Error: %s\n", msg)
+		}
+		if outcome == mirror.OutcomeDiverged {
+			fmt.Printf("    Primary sha256 %v, shadow sha256 %v\n", e.Params["primary_sha256"], e.Params["shadow_sha256"])
+		}
+	}
+	if shown == 0 {
+		fmt.Println("  none")
+	}
+}
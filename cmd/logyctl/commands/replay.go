@@ -46,6 +46,9 @@ func ReplayCommand() {
 
 	fmt.Printf("Replaying Event: %s (%s)\n", event.ID, event.Method)
 	fmt.Printf("Target URL:     %s\n", targetURL)
+	if event.Upstream != "" {
+		fmt.Printf("Recorded Via:   %s\n", event.Upstream)
+	}
 	fmt.Println("------------------------------------------------------------")
 
 	// 1. Prepare Request
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+)
+
+// maxSessionEvents bounds the sessions listed and the events shown per session.
+const maxSessionEvents = 100000
+
+// SessionCommand lists recorded MCP sessions, or the events of one session.
+func SessionCommand() {
+	db, err := store.NewDB("logryph.db")
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+
+	if len(os.Args) < 3 {
+		listSessions(db)
+		return
+	}
+	printSession(db, os.Args[2])
+}
+
+// listSessions prints the session_started event of every recorded session.
+func listSessions(db *store.DB) {
+	starts, err := db.GetSessionStarts()
+	if err := assert.Check(err == nil, "failed to get sessions: %v", err); err != nil {
+		log.Fatalf("Failed to get sessions: %v", err)
+	}
+	if len(starts) == 0 {
+		fmt.Println("No MCP sessions recorded in ledger.")
+		return
+	}
+	if err := assert.Check(len(starts) <= maxSessionEvents, "sessions exceed max: %d", len(starts)); err != nil {
+		log.Fatalf("Sessions exceed max: %v", err)
+	}
+	fmt.Println("Recorded MCP Sessions:")
+	fmt.Println("======================")
+	for i := 0; i < maxSessionEvents; i++ {
+		if i >= len(starts) {
+			break
+		}
+		e := starts[i]
+		fmt.Printf("%s | %s | protocol %v | client %v | server %v\n", e.SessionID, e.Timestamp.Format(time.RFC3339),
+			e.Params["protocol_version"], infoName(e.Params["client_info"]), infoName(e.Params["server_info"]))
+	}
+	fmt.Println("\nUsage: logyctl session <session-id>")
+}
+
+// printSession prints the events of one session in ledger order.
+func printSession(db *store.DB, sessionID string) {
+	events, err := db.GetEventsBySessionID(sessionID)
+	if err := assert.Check(err == nil, "failed to get session events: %v", err); err != nil {
+		log.Fatalf("Failed to get session events: %v", err)
+	}
+	if len(events) == 0 {
+		fmt.Printf("No events found for session %s\n", sessionID)
+		return
+	}
+	if err := assert.Check(len(events) <= maxSessionEvents, "session events exceed max: %d", len(events)); err != nil {
+		log.Fatalf("Session events exceed max: %v", err)
+	}
+
+	fmt.Printf("Session %s (%d events)\n", sessionID, len(events))
+	fmt.Println("===========================")
+	for i := 0; i < maxSessionEvents; i++ {
+		if i >= len(events) {
+			break
+		}
+		e := events[i]
+		fmt.Printf("[%d] %s | %-15s | %s\n", e.SeqIndex, e.ID[:8], e.EventType, e.Method)
+		if e.EventType == "session_ended" {
+			fmt.Printf("    Reason: %v (%vms)\n", e.Params["reason"], e.Params["duration_ms"])
+		}
+	}
+}
+
+// infoName renders an MCP Implementation object ({name, version}) compactly.
+func infoName(info interface{}) string {
+	m, ok := info.(map[string]interface{})
+	if !ok {
+		return "unknown"
+	}
+	return fmt.Sprintf("%v/%v", m["name"], m["version"])
+}
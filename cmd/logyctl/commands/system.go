@@ -2,7 +2,6 @@ package commands
 
 import (
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
@@ -50,21 +49,7 @@ func StatusCommand() {
 }
 
 func RekeyCommand() {
-	resp, err := http.Post("http://localhost:9998/api/rekey", "application/json", nil)
-	if err != nil {
-		log.Fatalf("Failed to contact Logryph API: %v", err)
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("Failed to close rekey response: %v", err)
-		}
-	}()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("Error: Failed to read response body: %v\n", err)
-		os.Exit(1)
-	}
+	body := adminRequest(http.MethodPost, "http://localhost:9998/api/rekey", nil)
 	fmt.Println(string(body))
 }
 
@@ -163,6 +163,9 @@ func printTraceTree(roots []models.Event, childrenMap map[string][]models.Event,
 		if e.EventType == "tool_response" {
 			statusSym = "[x]" // Response
 		}
+		if e.EventType == "tool_error" {
+			statusSym = "[E]" // Error response
+		}
 		if e.WasBlocked {
 			statusSym = "[X]" // Blocked
 		}
@@ -173,7 +176,11 @@ func printTraceTree(roots []models.Event, childrenMap map[string][]models.Event,
 		// Calculate delta from start
 		delta := e.Timestamp.Sub(startTime)
 
-		fmt.Printf("%s%s%s %-15s [%s] (+%v)\n", prefix, marker, statusSym, e.Method, e.ID[:6], delta.Truncate(time.Millisecond))
+		latency := ""
+		if (e.EventType == "tool_response" || e.EventType == "tool_error") && e.ParentID != "" {
+			latency = fmt.Sprintf(" took %dms", e.LatencyMs)
+		}
+		fmt.Printf("%s%s%s %-15s [%s] (+%v)%s\n", prefix, marker, statusSym, e.Method, e.ID[:6], delta.Truncate(time.Millisecond), latency)
 
 		// New Prefix for children
 		newPrefix := prefix
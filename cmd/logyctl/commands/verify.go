@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/blob"
 	"github.com/slyt3/Logryph/internal/crypto"
 	"github.com/slyt3/Logryph/internal/ledger/audit"
 	"github.com/slyt3/Logryph/internal/ledger/store"
@@ -64,6 +65,7 @@ func VerifyCommand() {
 		}
 		os.Exit(1)
 	}
+	verifyBlobs(db, runID)
 
 	if *skipLive {
 		return
@@ -81,3 +83,29 @@ func VerifyCommand() {
 		os.Exit(1)
 	}
 }
+
+// verifyBlobs checks that every blob the run's events reference is present and
+// still hashes to the digest recorded in the signed events.
+func verifyBlobs(db *store.DB, runID string) {
+	events, err := db.GetAllEvents(runID)
+	if err != nil {
+		log.Fatalf("Failed to load events: %v", err)
+	}
+	digests := eventBlobRefs(events)
+	if len(digests) == 0 {
+		return
+	}
+	blobs := blob.NewStore(blob.DefaultDir)
+	failed := 0
+	for _, digest := range digests {
+		if err := blobs.Verify(digest); err != nil {
+			fmt.Printf("[FAILED] %v\n", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("[FAILED] %d of %d referenced blobs missing or corrupt\n", failed, len(digests))
+		os.Exit(1)
+	}
+	fmt.Printf("[OK] Referenced blobs intact (%d blobs verified)\n", len(digests))
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/crypto"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/audit"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const maxReceiptInput = 8192
+
+// VerifyReceiptCommand checks a receipt from an X-Logryph-Receipt header against
+// the ledger: it must be signed by the run's ledger key, and the event it names
+// must be in the run at the committed position, with a valid chain behind it.
+func VerifyReceiptCommand() {
+	if len(os.Args) < 3 {
+		fmt.Println("Usage: logyctl verify-receipt <receipt|->")
+		os.Exit(1)
+	}
+	receipt := readReceipt(os.Args[2])
+
+	db, err := store.NewDB("logryph.db")
+	if err := assert.Check(err == nil, "failed to open database: %v", err); err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Failed to close database: %v", err)
+		}
+	}()
+	signer, err := crypto.NewSigner(".logryph_key")
+	if err := assert.Check(err == nil, "failed to load signer: %v", err); err != nil {
+		log.Fatalf("Failed to load signer: %v", err)
+	}
+
+	_, _, runKey, err := db.GetRunInfo(receipt.RunID)
+	if err != nil {
+		receiptFailed("run %s not found in ledger: %v", receipt.RunID, err)
+	}
+	if receipt.PublicKey != runKey && receipt.PublicKey != signer.GetPublicKey() {
+		receiptFailed("receipt was not signed by the ledger key of run %s", receipt.RunID)
+	}
+	fmt.Printf("[OK] Receipt signature valid (%s, run %s)\n", receipt.Kind, receipt.RunID)
+
+	event := receiptEvent(db, receipt)
+	if err := audit.VerifyEvent(event, signer); err != nil {
+		receiptFailed("event %s: %v", event.ID, err)
+	}
+	fmt.Printf("[OK] Event %s recorded at seq %d\n", event.ID, event.SeqIndex)
+
+	result, err := audit.VerifyChain(db, receipt.RunID, signer)
+	if err != nil {
+		log.Fatalf("Verification error: %v", err)
+	}
+	if !result.Valid {
+		receiptFailed("chain verification failed at seq %d: %s", result.FailedAtSeq, result.ErrorMessage)
+	}
+	fmt.Printf("[OK] Chain is valid (%d events verified)\n", result.TotalEvents)
+}
+
+// readReceipt decodes the receipt given on the command line, or on stdin for
+// "-", and checks its signature.
+func readReceipt(arg string) *ledger.Receipt {
+	encoded := arg
+	if encoded == "-" {
+		raw, err := io.ReadAll(io.LimitReader(os.Stdin, maxReceiptInput))
+		if err != nil {
+			log.Fatalf("Failed to read receipt: %v", err)
+		}
+		encoded = string(raw)
+	}
+	receipt, err := ledger.DecodeReceipt(strings.TrimSpace(encoded))
+	if err != nil {
+		log.Fatalf("Invalid receipt: %v", err)
+	}
+	if err := receipt.VerifySignature(); err != nil {
+		receiptFailed("%v", err)
+	}
+	return receipt
+}
+
+// receiptEvent loads the event a receipt names and checks it against the
+// receipt: same run and body digest and, for a commitment, the committed seq and
+// hash.
+func receiptEvent(db *store.DB, receipt *ledger.Receipt) *models.Event {
+	event, err := db.GetEventByID(receipt.EventID)
+	if err != nil || event == nil {
+		receiptFailed("event %s is not in the ledger", receipt.EventID)
+	}
+	if event.RunID != receipt.RunID {
+		receiptFailed("event %s belongs to run %s, not %s", event.ID, event.RunID, receipt.RunID)
+	}
+	if receipt.BodySHA256 != "" && event.BodySHA256 != "" && receipt.BodySHA256 != event.BodySHA256 {
+		receiptFailed("event %s records body %s, receipt promised %s", event.ID, event.BodySHA256, receipt.BodySHA256)
+	}
+	if receipt.Kind == ledger.ReceiptCommitment {
+		if event.SeqIndex != receipt.Seq || event.CurrentHash != receipt.EventHash {
+			receiptFailed("event %s is at seq %d with hash %s, receipt committed to seq %d with hash %s",
+				event.ID, event.SeqIndex, event.CurrentHash, receipt.Seq, receipt.EventHash)
+		}
+	}
+	return event
+}
+
+func receiptFailed(format string, args ...interface{}) {
+	fmt.Printf("[FAILED] "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -18,6 +18,8 @@ func main() {
 	switch command {
 	case "verify":
 		commands.VerifyCommand()
+	case "verify-receipt":
+		commands.VerifyReceiptCommand()
 	case "status":
 		commands.StatusCommand()
 	case "events":
@@ -46,6 +48,16 @@ func main() {
 		commands.TraceCommand()
 	case "replay":
 		commands.ReplayCommand()
+	case "batch":
+		commands.BatchCommand()
+	case "session":
+		commands.SessionCommand()
+	case "approvals":
+		commands.ApprovalsCommand()
+	case "blob":
+		commands.BlobCommand()
+	case "mirror":
+		commands.MirrorCommand()
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
 		printUsage()
@@ -58,6 +70,7 @@ func printUsage() {
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  logyctl verify                    Validate the entire hash chain")
+	fmt.Println("  logyctl verify-receipt <receipt|-> Check a response receipt against the ledger")
 	fmt.Println("  logyctl status                    Show current run information")
 	fmt.Println("  logyctl events [--limit N]        List recent events (default: 10)")
 	fmt.Println("  logyctl stats                     Show detailed run and global statistics")
@@ -65,9 +78,15 @@ func printUsage() {
 	fmt.Println("  logyctl export <file.zip>         Export the current run as an Evidence Bag (ZIP)")
 	fmt.Println("  logyctl trace <task-id>           Visualize the forensic timeline of a task")
 	fmt.Println("  logyctl replay <id>               Re-execute a tool call to reproduce an incident")
+	fmt.Println("  logyctl batch <batch-id>          List the events of one JSON-RPC batch")
+	fmt.Println("  logyctl session [session-id]      List MCP sessions or the events of one session")
+	fmt.Println("  logyctl approvals list            List calls awaiting human approval")
+	fmt.Println("  logyctl approvals approve|reject <id> [--by NAME] [--reason TEXT]")
+	fmt.Println("  logyctl blob get <sha256> [-o FILE] Write a blob referenced by an event")
+	fmt.Println("  logyctl mirror [--all]            Compare shadow and primary results of mirrored calls")
 	fmt.Println()
 	fmt.Println("Key Management:")
-	fmt.Println("  logyctl rekey                     Rotate the Ed25519 signing keys")
+	fmt.Println("  logyctl rekey                     Rotate the Ed25519 signing keys (sends LOGRYPH_ADMIN_TOKEN)")
 	fmt.Println("  logyctl backup-key                Create timestamped backup of signing key")
 	fmt.Println("  logyctl restore-key <file>        Restore signing key from backup")
 	fmt.Println("  logyctl list-backups              List available key backups")
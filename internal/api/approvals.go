@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/approval"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+const maxDecisionBodyBytes = 4096
+
+// decisionRequest is the optional JSON body of an approve/reject call. Approver
+// is only kept as an unverified note: the recorded approver comes from the
+// caller's credential (see approverOf).
+type decisionRequest struct {
+	Approver string `json:"approver"`
+	Reason   string `json:"reason"`
+}
+
+// Credentials an approver authenticates with, recorded on decisions.
+const (
+	credentialMTLS       = "mtls"
+	credentialAdminToken = "admin_token"
+)
+
+// approverOf names the operator behind an authorized request: the subject of a
+// client certificate verified by --admin-tls-client-ca, or, when only the shared
+// admin token was presented, the token, which does not name anyone.
+func approverOf(r *http.Request) (approver, credential string) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.PeerCertificates) == 0 {
+		return credentialAdminToken, credentialAdminToken
+	}
+	subject := r.TLS.PeerCertificates[0].Subject
+	if subject.CommonName != "" {
+		return subject.CommonName, credentialMTLS
+	}
+	return subject.String(), credentialMTLS
+}
+
+// HandleApprovals lists calls held by require_approval rules, oldest first.
+// Requires GET and an approver credential (see authorized).
+func (h *Handlers) HandleApprovals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r) {
+		http.Error(w, "Unauthorized: set LOGRYPH_ADMIN_TOKEN or --admin-tls-client-ca to manage approvals", http.StatusUnauthorized)
+		return
+	}
+	if err := assert.NotNil(h.Core, "core"); err != nil {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	pending := h.Core.Approvals.Pending()
+	if pending == nil {
+		pending = []approval.Request{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pending); err != nil {
+		logging.Error("approvals_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
+
+// HandleApprovalDecision approves or rejects a held call:
+// POST /api/approvals/<id>/approve or /api/approvals/<id>/reject, with an optional
+// {"approver": "...", "reason": "..."} body. The approver recorded is the caller's
+// credential; a body approver is kept as a note. Returns 404 if the call is no
+// longer pending. Requires an approver credential (see authorized).
+func (h *Handlers) HandleApprovalDecision(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r) {
+		http.Error(w, "Unauthorized: set LOGRYPH_ADMIN_TOKEN or --admin-tls-client-ca to manage approvals", http.StatusUnauthorized)
+		return
+	}
+	if err := assert.NotNil(h.Core, "core"); err != nil {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/approvals/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.Error(w, "expected /api/approvals/<id>/approve or /reject", http.StatusNotFound)
+		return
+	}
+	var decision approval.Decision
+	switch parts[1] {
+	case "approve":
+		decision = approval.Approved
+	case "reject":
+		decision = approval.Rejected
+	default:
+		http.Error(w, "expected /api/approvals/<id>/approve or /reject", http.StatusNotFound)
+		return
+	}
+
+	var body decisionRequest
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxDecisionBodyBytes))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &body); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	}
+	approver, credential := approverOf(r)
+
+	verdict := approval.Verdict{Decision: decision, Approver: approver, Credential: credential, Note: body.Approver, Reason: body.Reason}
+	if err := h.Core.Approvals.Decide(parts[0], verdict); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, approval.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	logging.Info("approval_decision_received", logging.Fields{Component: "api", EventID: parts[0]})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(verdict); err != nil {
+		logging.Error("approval_decision_encode_failed", logging.Fields{Component: "api", Error: err.Error()})
+	}
+}
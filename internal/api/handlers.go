@@ -1,16 +1,21 @@
 package api
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/slyt3/Logryph/internal/assert"
 	"github.com/slyt3/Logryph/internal/core"
 	"github.com/slyt3/Logryph/internal/ledger"
 	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/mirror"
+	"github.com/slyt3/Logryph/internal/observer"
 	"github.com/slyt3/Logryph/internal/pool"
 )
 
@@ -30,19 +35,16 @@ func NewHandlers(engine *core.Engine) *Handlers {
 }
 
 // HandleRekey rotates the Ed25519 signing key and returns the new public key.
-// Requires POST method and X-Admin-Token header if LOGRYPH_ADMIN_TOKEN is set.
-// Returns 405 for non-POST, 401 for missing/invalid token, 500 on rotation failure.
+// Requires POST method and an admin credential (see authorized).
+// Returns 405 for non-POST, 401 for a missing/invalid credential, 500 on rotation failure.
 func (h *Handlers) HandleRekey(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	adminToken := os.Getenv("LOGRYPH_ADMIN_TOKEN")
-	if adminToken != "" {
-		if r.Header.Get("X-Admin-Token") != adminToken {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
+	if !authorized(r) {
+		http.Error(w, "Unauthorized: set LOGRYPH_ADMIN_TOKEN or --admin-tls-client-ca to rotate keys", http.StatusUnauthorized)
+		return
 	}
 	oldPubKey, newPubKey, err := h.Core.Worker.GetSigner().RotateKey(".logryph_key")
 	if err != nil {
@@ -55,6 +57,16 @@ func (h *Handlers) HandleRekey(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// authorized guards the rekey and approval endpoints. It never admits
+// anonymous callers: it needs X-Admin-Token matching LOGRYPH_ADMIN_TOKEN, or,
+// when no token is set, a client certificate verified by --admin-tls-client-ca.
+func authorized(r *http.Request) bool {
+	if token := os.Getenv("LOGRYPH_ADMIN_TOKEN"); token != "" {
+		return subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Admin-Token")), []byte(token)) == 1
+	}
+	return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+}
+
 // HandleStats returns pool metrics (event/buffer hits and misses) as JSON.
 // Always returns 200 OK with pool statistics.
 func (h *Handlers) HandleStats(w http.ResponseWriter, r *http.Request) {
@@ -139,10 +151,14 @@ type prometheusMetrics struct {
 	EventsDropped    uint64
 	EventsBlocked    uint64
 	BackpressureMode string
+	LedgerHealthy    bool
 	ActiveTasks      int
 	QueueDepth       int
 	QueueCapacity    int
 	LatencyMetrics   LatencySnapshot
+	RateWindows      []observer.RateWindow
+	RateExceeded     map[string]uint64
+	Mirror           *mirror.Stats // nil when mirroring is off
 }
 
 // collectMetrics gathers all metrics from the system
@@ -160,18 +176,33 @@ func (h *Handlers) collectMetrics() *prometheusMetrics {
 	poolMetrics := pool.GetMetrics()
 	proc, drop := h.Core.Worker.Stats()
 	queueDepth, queueCap := h.Core.Worker.QueueDepth()
-	latency := h.Core.Worker.LatencyMetrics()
-	blocked := h.Core.Worker.BlockedSubmits()
-	mode := h.Core.Worker.BackpressureMode()
 	modeLabel := "drop"
-	if mode == ledger.BackpressureBlock {
+	if h.Core.Worker.BackpressureMode() == ledger.BackpressureBlock {
 		modeLabel = "block"
 	}
-
 	if err := assert.Check(queueCap >= 0, "queue capacity must be non-negative"); err != nil {
 		logging.Warn("queue_capacity_invalid", logging.Fields{Component: "api", Error: err.Error()})
 	}
 
+	m := &prometheusMetrics{
+		PoolEventHits:    poolMetrics.EventHits,
+		PoolEventMisses:  poolMetrics.EventMisses,
+		EventsProcessed:  proc,
+		EventsDropped:    drop,
+		EventsBlocked:    h.Core.Worker.BlockedSubmits(),
+		BackpressureMode: modeLabel,
+		LedgerHealthy:    h.Core.Worker.IsHealthy(),
+		ActiveTasks:      h.countActiveTasks(),
+		QueueDepth:       queueDepth,
+		QueueCapacity:    queueCap,
+		LatencyMetrics:   h.Core.Worker.LatencyMetrics(),
+	}
+	h.collectPolicyMetrics(m)
+	return m
+}
+
+// countActiveTasks counts the tracked SEP-1686 tasks, up to maxActiveTasks.
+func (h *Handlers) countActiveTasks() int {
 	tasks := 0
 	const maxActiveTasks = 10000
 	h.Core.ActiveTasks.Range(func(_, _ interface{}) bool {
@@ -184,30 +215,49 @@ func (h *Handlers) collectMetrics() *prometheusMetrics {
 	if err := assert.Check(tasks <= maxActiveTasks, "active tasks exceeded cap: %d", tasks); err != nil {
 		logging.Warn("active_tasks_exceeded", logging.Fields{Component: "api", Error: err.Error()})
 	}
-
-	return &prometheusMetrics{
-		PoolEventHits:    poolMetrics.EventHits,
-		PoolEventMisses:  poolMetrics.EventMisses,
-		EventsProcessed:  proc,
-		EventsDropped:    drop,
-		EventsBlocked:    blocked,
-		BackpressureMode: modeLabel,
-		ActiveTasks:      tasks,
-		QueueDepth:       queueDepth,
-		QueueCapacity:    queueCap,
-		LatencyMetrics:   latency,
-	}
+	return tasks
 }
 
-// formatPrometheusText writes metrics in Prometheus text format
-func (h *Handlers) formatPrometheusText(w http.ResponseWriter, m *prometheusMetrics) {
-	if err := assert.NotNil(m, "metrics"); err != nil {
+// collectPolicyMetrics adds the rate_limit counters and, when mirroring is on,
+// the mirror counters.
+func (h *Handlers) collectPolicyMetrics(m *prometheusMetrics) {
+	if h.Core.Observer == nil {
 		return
 	}
-	if err := assert.NotNil(w, "response writer"); err != nil {
-		return
+	m.RateWindows, m.RateExceeded = h.Core.Observer.RateWindows()
+	if h.Core.Mirror != nil && h.Core.Observer.GetMirror().Enabled() {
+		stats := h.Core.Mirror.Stats()
+		m.Mirror = &stats
 	}
+}
+
+// metricFamily describes one Prometheus metric family: its HELP and TYPE lines
+// and its samples.
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string // "counter" or "gauge"
+	samples []metricSample
+}
+
+// metricSample is one line of a family: its labels (without braces, empty for
+// none) and formatted value.
+type metricSample struct {
+	labels string
+	value  string
+}
+
+// maxFamilySamples bounds the samples written per family.
+const maxFamilySamples = 4096
+
+// sample returns an unlabelled sample of v.
+func sample(v interface{}) []metricSample {
+	return []metricSample{{value: fmt.Sprint(v)}}
+}
 
+// writeFamilies writes metric families in Prometheus text format. Returns false
+// once a write fails.
+func writeFamilies(w http.ResponseWriter, families []metricFamily) bool {
 	writef := func(format string, args ...interface{}) bool {
 		if _, err := fmt.Fprintf(w, format, args...); err != nil {
 			logging.Error("prometheus_write_failed", logging.Fields{Component: "api", Error: err.Error()})
@@ -215,98 +265,57 @@ func (h *Handlers) formatPrometheusText(w http.ResponseWriter, m *prometheusMetr
 		}
 		return true
 	}
-
-	if !writef("# HELP logryph_pool_event_hits_total Total hits on the event pool\n") {
-		return
-	}
-	if !writef("# TYPE logryph_pool_event_hits_total counter\n") {
-		return
-	}
-	if !writef("logryph_pool_event_hits_total %d\n", m.PoolEventHits) {
-		return
-	}
-
-	if !writef("# HELP logryph_pool_event_misses_total Total misses (allocations) in the event pool\n") {
-		return
-	}
-	if !writef("# TYPE logryph_pool_event_misses_total counter\n") {
-		return
-	}
-	if !writef("logryph_pool_event_misses_total %d\n", m.PoolEventMisses) {
-		return
-	}
-
-	if !writef("# HELP logryph_ledger_events_processed_total Total events successfully written to the ledger\n") {
-		return
-	}
-	if !writef("# TYPE logryph_ledger_events_processed_total counter\n") {
-		return
-	}
-	if !writef("logryph_ledger_events_processed_total %d\n", m.EventsProcessed) {
-		return
-	}
-
-	if !writef("# HELP logryph_ledger_events_dropped_total Total events dropped due to backpressure\n") {
-		return
-	}
-	if !writef("# TYPE logryph_ledger_events_dropped_total counter\n") {
-		return
-	}
-	if !writef("logryph_ledger_events_dropped_total %d\n", m.EventsDropped) {
-		return
-	}
-
-	if !writef("# HELP logryph_ledger_events_blocked_total Total submit attempts blocked by backpressure\n") {
-		return
-	}
-	if !writef("# TYPE logryph_ledger_events_blocked_total counter\n") {
-		return
-	}
-	if !writef("logryph_ledger_events_blocked_total %d\n", m.EventsBlocked) {
-		return
-	}
-
-	if !writef("# HELP logryph_ledger_backpressure_mode Current backpressure mode (drop|block)\n") {
-		return
-	}
-	if !writef("# TYPE logryph_ledger_backpressure_mode gauge\n") {
-		return
-	}
-	if !writef("logryph_ledger_backpressure_mode{mode=\"%s\"} 1\n", m.BackpressureMode) {
-		return
+	for i := 0; i < len(families); i++ {
+		f := families[i]
+		if !writef("# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind) {
+			return false
+		}
+		for j := 0; j < len(f.samples) && j < maxFamilySamples; j++ {
+			labels := ""
+			if f.samples[j].labels != "" {
+				labels = "{" + f.samples[j].labels + "}"
+			}
+			if !writef("%s%s %s\n", f.name, labels, f.samples[j].value) {
+				return false
+			}
+		}
 	}
+	return true
+}
 
-	if !writef("# HELP logryph_engine_active_tasks_total Number of currently active causal tasks\n") {
-		return
-	}
-	if !writef("# TYPE logryph_engine_active_tasks_total gauge\n") {
+// formatPrometheusText writes metrics in Prometheus text format
+func (h *Handlers) formatPrometheusText(w http.ResponseWriter, m *prometheusMetrics) {
+	if err := assert.NotNil(m, "metrics"); err != nil {
 		return
 	}
-	if !writef("logryph_engine_active_tasks_total %d\n", m.ActiveTasks) {
+	if err := assert.NotNil(w, "response writer"); err != nil {
 		return
 	}
 
-	if !writef("# HELP logryph_ledger_queue_depth Current queue depth\n") {
-		return
+	healthy := 0
+	if m.LedgerHealthy {
+		healthy = 1
 	}
-	if !writef("# TYPE logryph_ledger_queue_depth gauge\n") {
-		return
-	}
-	if !writef("logryph_ledger_queue_depth %d\n", m.QueueDepth) {
-		return
-	}
-
-	if !writef("# HELP logryph_ledger_queue_capacity Queue capacity\n") {
-		return
+	families := []metricFamily{
+		{"logryph_pool_event_hits_total", "Total hits on the event pool", "counter", sample(m.PoolEventHits)},
+		{"logryph_pool_event_misses_total", "Total misses (allocations) in the event pool", "counter", sample(m.PoolEventMisses)},
+		{"logryph_ledger_events_processed_total", "Total events successfully written to the ledger", "counter", sample(m.EventsProcessed)},
+		{"logryph_ledger_events_dropped_total", "Total events dropped due to backpressure", "counter", sample(m.EventsDropped)},
+		{"logryph_ledger_events_blocked_total", "Total submit attempts blocked by backpressure", "counter", sample(m.EventsBlocked)},
+		{"logryph_ledger_backpressure_mode", "Current backpressure mode (drop|block)", "gauge", []metricSample{{labels: fmt.Sprintf("mode=\"%s\"", m.BackpressureMode), value: "1"}}},
+		{"logryph_ledger_healthy", "Whether the last ledger write succeeded (1) or the ledger is unavailable (0)", "gauge", sample(healthy)},
+		{"logryph_engine_active_tasks_total", "Number of currently active causal tasks", "gauge", sample(m.ActiveTasks)},
+		{"logryph_ledger_queue_depth", "Current queue depth", "gauge", sample(m.QueueDepth)},
+		{"logryph_ledger_queue_capacity", "Queue capacity", "gauge", sample(m.QueueCapacity)},
 	}
-	if !writef("# TYPE logryph_ledger_queue_capacity gauge\n") {
+	if !writeFamilies(w, families) {
 		return
 	}
-	if !writef("logryph_ledger_queue_capacity %d\n", m.QueueCapacity) {
+	h.formatLatencyHistogram(w, &m.LatencyMetrics)
+	if !writeFamilies(w, rateLimitFamilies(m)) {
 		return
 	}
-
-	h.formatLatencyHistogram(w, &m.LatencyMetrics)
+	writeFamilies(w, mirrorFamilies(m))
 }
 
 // formatLatencyHistogram writes the latency histogram in Prometheus format
@@ -356,3 +365,99 @@ func (h *Handlers) formatLatencyHistogram(w http.ResponseWriter, latency *Latenc
 		return
 	}
 }
+
+// Window keys are chosen by agents (tool names, actors), so each policy exports
+// at most maxRateKeySeries of them as labels; the rest are summed under
+// rateOtherKey.
+const (
+	maxRateKeySeries = 20
+	rateOtherKey     = "(other)"
+)
+
+// rateWindowSeries bounds the label cardinality of rate windows, which arrive
+// sorted by policy: per policy the busiest maxRateKeySeries keys are kept and
+// the remaining calls are summed into one rateOtherKey series.
+func rateWindowSeries(windows []observer.RateWindow) []observer.RateWindow {
+	series := make([]observer.RateWindow, 0, len(windows))
+	for start := 0; start < len(windows); {
+		end := start + 1
+		for end < len(windows) && windows[end].PolicyID == windows[start].PolicyID {
+			end++
+		}
+		group := append([]observer.RateWindow(nil), windows[start:end]...)
+		sort.SliceStable(group, func(a, b int) bool { return group[a].Calls > group[b].Calls })
+		if len(group) > maxRateKeySeries {
+			other := observer.RateWindow{PolicyID: group[0].PolicyID, Per: group[0].Per, Key: rateOtherKey, Limit: group[0].Limit}
+			for j := maxRateKeySeries; j < len(group); j++ {
+				other.Calls += group[j].Calls
+			}
+			group = append(group[:maxRateKeySeries], other)
+		}
+		series = append(series, group...)
+		start = end
+	}
+	return series
+}
+
+// rateLimitFamilies returns the sliding-window counters of rate_limit policies:
+// the estimated calls per window key, each policy's limit, and calls over it.
+// None are returned when no rate_limit policy has counted a call.
+func rateLimitFamilies(m *prometheusMetrics) []metricFamily {
+	if len(m.RateWindows) == 0 && len(m.RateExceeded) == 0 {
+		return nil
+	}
+	series := rateWindowSeries(m.RateWindows)
+	calls := make([]metricSample, 0, len(series))
+	for i := 0; i < len(series) && i < maxFamilySamples; i++ {
+		rw := series[i]
+		calls = append(calls, metricSample{
+			labels: fmt.Sprintf("policy=\"%s\",per=\"%s\",key=\"%s\"", promLabel(rw.PolicyID), promLabel(rw.Per), promLabel(rw.Key)),
+			value:  fmt.Sprintf("%.2f", rw.Calls),
+		})
+	}
+	limits := make([]metricSample, 0, len(m.RateWindows))
+	for i := 0; i < len(m.RateWindows) && i < maxFamilySamples; i++ {
+		rw := m.RateWindows[i]
+		if i > 0 && m.RateWindows[i-1].PolicyID == rw.PolicyID {
+			continue // windows are sorted by policy
+		}
+		limits = append(limits, metricSample{labels: fmt.Sprintf("policy=\"%s\"", promLabel(rw.PolicyID)), value: fmt.Sprint(rw.Limit)})
+	}
+	policies := make([]string, 0, len(m.RateExceeded))
+	for id := range m.RateExceeded {
+		policies = append(policies, id)
+	}
+	sort.Strings(policies)
+	exceeded := make([]metricSample, 0, len(policies))
+	for i := 0; i < len(policies) && i < maxFamilySamples; i++ {
+		exceeded = append(exceeded, metricSample{labels: fmt.Sprintf("policy=\"%s\"", promLabel(policies[i])), value: fmt.Sprint(m.RateExceeded[policies[i]])})
+	}
+	return []metricFamily{
+		{"logryph_policy_rate_window_calls", "Calls in the current sliding window of a rate_limit policy", "gauge", calls},
+		{"logryph_policy_rate_limit", "Max calls per window of a rate_limit policy", "gauge", limits},
+		{"logryph_policy_rate_exceeded_total", "Calls that exceeded a rate_limit policy", "counter", exceeded},
+	}
+}
+
+// mirrorFamilies returns the traffic mirroring counters, or none when mirroring
+// is off.
+func mirrorFamilies(m *prometheusMetrics) []metricFamily {
+	if m.Mirror == nil {
+		return nil
+	}
+	outcomes := make([]metricSample, 0, len(mirror.Outcomes))
+	for i := 0; i < len(mirror.Outcomes); i++ {
+		outcome := mirror.Outcomes[i]
+		outcomes = append(outcomes, metricSample{labels: fmt.Sprintf("outcome=\"%s\"", outcome), value: fmt.Sprint(m.Mirror.Outcomes[outcome])})
+	}
+	return []metricFamily{
+		{"logryph_mirror_requests_total", "Mirrored calls by comparison of shadow and primary results", "counter", outcomes},
+		{"logryph_mirror_skipped_total", "Selected calls not mirrored because too many were in flight", "counter", sample(m.Mirror.Skipped)},
+		{"logryph_mirror_in_flight", "Shadow calls awaiting comparison", "gauge", sample(m.Mirror.InFlight)},
+	}
+}
+
+// promLabel escapes a Prometheus label value.
+func promLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
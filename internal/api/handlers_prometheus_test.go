@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
@@ -13,6 +14,7 @@ import (
 	"github.com/slyt3/Logryph/internal/core"
 	"github.com/slyt3/Logryph/internal/ledger"
 	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/observer"
 	"github.com/slyt3/Logryph/internal/pool"
 )
 
@@ -41,6 +43,9 @@ func TestHandlePrometheusIncludesQueueAndLatency(t *testing.T) {
 	if !strings.Contains(body, "logryph_ledger_backpressure_mode") {
 		t.Fatalf("missing backpressure mode metric")
 	}
+	if !strings.Contains(body, "logryph_ledger_healthy 1") {
+		t.Fatalf("missing ledger health metric")
+	}
 }
 
 func TestHandlePrometheusLatencyCountAndSum(t *testing.T) {
@@ -166,3 +171,24 @@ func waitForProcessed(t *testing.T, worker *ledger.Worker, min uint64, timeout t
 	processed, _ := worker.Stats()
 	t.Fatalf("timeout waiting for processed events: %d", processed)
 }
+
+func TestRateWindowSeriesBoundsKeys(t *testing.T) {
+	var windows []observer.RateWindow
+	for i := 0; i < maxRateKeySeries+5; i++ {
+		windows = append(windows, observer.RateWindow{PolicyID: "a", Per: "method", Key: fmt.Sprintf("tool-%02d", i), Calls: float64(i + 1), Limit: 10})
+	}
+	windows = append(windows, observer.RateWindow{PolicyID: "b", Key: "", Calls: 3, Limit: 5})
+
+	series := rateWindowSeries(windows)
+	if len(series) != maxRateKeySeries+2 {
+		t.Fatalf("expected %d series, got %d", maxRateKeySeries+2, len(series))
+	}
+	other := series[maxRateKeySeries]
+	// The five quietest keys (1..5 calls) are summed.
+	if other.PolicyID != "a" || other.Key != rateOtherKey || other.Calls != 15 {
+		t.Errorf("unexpected aggregate series: %+v", other)
+	}
+	if series[0].Calls != float64(maxRateKeySeries+5) || series[len(series)-1].PolicyID != "b" {
+		t.Errorf("expected busiest keys first and every policy kept, got %+v", series)
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/approval"
+	"github.com/slyt3/Logryph/internal/core"
+)
+
+func TestAuthorizedRequiresCredential(t *testing.T) {
+	t.Setenv("LOGRYPH_ADMIN_TOKEN", "")
+	r := httptest.NewRequest("GET", "/api/approvals", nil)
+	if authorized(r) {
+		t.Fatalf("anonymous caller admitted with no token configured")
+	}
+
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	if !authorized(r) {
+		t.Fatalf("verified client certificate rejected")
+	}
+
+	t.Setenv("LOGRYPH_ADMIN_TOKEN", "secret")
+	r = httptest.NewRequest("GET", "/api/approvals", nil)
+	if authorized(r) {
+		t.Fatalf("missing token admitted")
+	}
+	r.Header.Set("X-Admin-Token", "secret")
+	if !authorized(r) {
+		t.Fatalf("matching token rejected")
+	}
+}
+
+func TestRekeyRefusesAnonymousCaller(t *testing.T) {
+	t.Setenv("LOGRYPH_ADMIN_TOKEN", "")
+	w := httptest.NewRecorder()
+	(&Handlers{}).HandleRekey(w, httptest.NewRequest("POST", "/api/rekey", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestApprovalDecisionRecordsCredentialAsApprover(t *testing.T) {
+	t.Setenv("LOGRYPH_ADMIN_TOKEN", "secret")
+	h := &Handlers{Core: &core.Engine{Approvals: approval.NewQueue()}}
+
+	decide := func(id string, r *http.Request) approval.Verdict {
+		t.Helper()
+		verdicts, err := h.Core.Approvals.Hold(approval.Request{ID: id, RequestedAt: time.Now()})
+		if err != nil {
+			t.Fatalf("hold: %v", err)
+		}
+		r.Header.Set("X-Admin-Token", "secret")
+		w := httptest.NewRecorder()
+		h.HandleApprovalDecision(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+		}
+		return <-verdicts
+	}
+
+	r := httptest.NewRequest("POST", "/api/approvals/a/approve", strings.NewReader(`{"approver":"alice"}`))
+	if v := decide("a", r); v.Approver != credentialAdminToken || v.Credential != credentialAdminToken || v.Note != "alice" {
+		t.Errorf("expected the token recorded as the credential and alice as a note, got %+v", v)
+	}
+
+	r = httptest.NewRequest("POST", "/api/approvals/b/reject", strings.NewReader(`{"approver":"mallory"}`))
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "bob"}}
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+	if v := decide("b", r); v.Approver != "bob" || v.Credential != credentialMTLS || v.Note != "mallory" {
+		t.Errorf("expected the certificate subject recorded as the approver, got %+v", v)
+	}
+}
@@ -0,0 +1,171 @@
+package approval
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+)
+
+// MaxPending bounds the number of calls held at once; further holds are refused.
+const MaxPending = 256
+
+// Decision is the outcome of an approval request.
+type Decision string
+
+const (
+	Approved Decision = "approved"
+	Rejected Decision = "rejected"
+)
+
+var (
+	// ErrNotFound is returned when deciding on an ID that is not pending
+	// (unknown, already decided or timed out).
+	ErrNotFound = errors.New("approval request not found")
+	// ErrQueueFull is returned by Hold when MaxPending calls are already held.
+	ErrQueueFull = errors.New("approval queue full")
+	// ErrClosed is returned by Hold after Close.
+	ErrClosed = errors.New("approval queue closed")
+)
+
+// Request is a tool call held until an operator decides. ID is the event ID of
+// the recorded call; Params is its JSON-encoded params as forwarded, after redaction.
+type Request struct {
+	ID          string          `json:"id"`
+	Method      string          `json:"method"`
+	Action      string          `json:"action"`
+	PolicyID    string          `json:"policy_id"`
+	RiskLevel   string          `json:"risk_level,omitempty"`
+	SessionID   string          `json:"session_id,omitempty"`
+	Upstream    string          `json:"upstream,omitempty"`
+	Params      json.RawMessage `json:"params,omitempty"`
+	RequestedAt time.Time       `json:"requested_at"`
+	Deadline    time.Time       `json:"deadline"`
+}
+
+// Verdict is a decision on a held call. Approver is empty when Logryph itself
+// decided (timeout, shutdown) rather than a human operator; otherwise it is
+// taken from the credential the operator authenticated with, named by
+// Credential. Note is a name the operator gave for themselves, unverified.
+type Verdict struct {
+	Decision   Decision `json:"decision"`
+	Approver   string   `json:"approver,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+	Note       string   `json:"approver_note,omitempty"`
+	Reason     string   `json:"reason,omitempty"`
+}
+
+type entry struct {
+	req     Request
+	verdict chan Verdict
+}
+
+// Queue holds calls awaiting approval. Holders wait on the channel returned by
+// Hold; operators list requests with Pending and answer them with Decide.
+// Safe for concurrent use.
+type Queue struct {
+	mu      sync.Mutex
+	pending map[string]*entry
+	closed  bool
+}
+
+// NewQueue returns an empty approval queue.
+func NewQueue() *Queue {
+	return &Queue{pending: make(map[string]*entry)}
+}
+
+// Hold registers a request and returns the channel its verdict is delivered on.
+func (q *Queue) Hold(req Request) (<-chan Verdict, error) {
+	if err := assert.NotNil(q, "approval queue"); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(req.ID != "", "approval request ID must not be empty"); err != nil {
+		return nil, err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil, ErrClosed
+	}
+	if len(q.pending) >= MaxPending {
+		return nil, ErrQueueFull
+	}
+	if _, exists := q.pending[req.ID]; exists {
+		return nil, errors.New("approval request already pending: " + req.ID)
+	}
+	e := &entry{req: req, verdict: make(chan Verdict, 1)}
+	q.pending[req.ID] = e
+	return e.verdict, nil
+}
+
+// Pending returns the held requests, oldest first.
+func (q *Queue) Pending() []Request {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	reqs := make([]Request, 0, len(q.pending))
+	for _, e := range q.pending {
+		reqs = append(reqs, e.req)
+	}
+	q.mu.Unlock()
+
+	sort.Slice(reqs, func(a, b int) bool { return reqs[a].RequestedAt.Before(reqs[b].RequestedAt) })
+	return reqs
+}
+
+// Decide delivers a verdict to the holder of id and removes it from the queue.
+func (q *Queue) Decide(id string, v Verdict) error {
+	if err := assert.NotNil(q, "approval queue"); err != nil {
+		return err
+	}
+	if v.Decision != Approved && v.Decision != Rejected {
+		return errors.New("decision must be approved or rejected")
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e, ok := q.pending[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(q.pending, id)
+	e.verdict <- v
+	return nil
+}
+
+// Release removes id without a verdict, e.g. when its holder timed out. It
+// reports whether the request was still pending.
+func (q *Queue) Release(id string) bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.pending[id]; !ok {
+		return false
+	}
+	delete(q.pending, id)
+	return true
+}
+
+// Close delivers v to every pending request and refuses further holds.
+// Safe to call multiple times.
+func (q *Queue) Close(v Verdict) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	for id, e := range q.pending {
+		delete(q.pending, id)
+		e.verdict <- v
+	}
+}
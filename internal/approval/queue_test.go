@@ -0,0 +1,62 @@
+package approval
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueueDecide(t *testing.T) {
+	q := NewQueue()
+	verdicts, err := q.Hold(Request{ID: "a", RequestedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Hold failed: %v", err)
+	}
+	if pending := q.Pending(); len(pending) != 1 || pending[0].ID != "a" {
+		t.Fatalf("expected a pending, got %+v", pending)
+	}
+
+	if err := q.Decide("a", Verdict{Decision: Approved, Approver: "alice"}); err != nil {
+		t.Fatalf("Decide failed: %v", err)
+	}
+	if v := <-verdicts; v.Decision != Approved || v.Approver != "alice" {
+		t.Errorf("unexpected verdict %+v", v)
+	}
+	if err := q.Decide("a", Verdict{Decision: Rejected}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound deciding twice, got %v", err)
+	}
+	if len(q.Pending()) != 0 {
+		t.Error("expected queue to be empty")
+	}
+}
+
+func TestQueueReleaseAndClose(t *testing.T) {
+	q := NewQueue()
+	if _, err := q.Hold(Request{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if !q.Release("a") || q.Release("a") {
+		t.Error("expected Release to report pending only once")
+	}
+
+	verdicts, err := q.Hold(Request{ID: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Close(Verdict{Decision: Rejected, Reason: "shutdown"})
+	if v := <-verdicts; v.Decision != Rejected {
+		t.Errorf("expected rejection on close, got %+v", v)
+	}
+	if _, err := q.Hold(Request{ID: "c"}); err != ErrClosed {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestQueueRejectsInvalidDecision(t *testing.T) {
+	q := NewQueue()
+	if _, err := q.Hold(Request{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Decide("a", Verdict{Decision: "maybe"}); err == nil {
+		t.Error("expected invalid decision to be rejected")
+	}
+}
@@ -0,0 +1,37 @@
+package blob
+
+import "strings"
+
+// RefKey marks a payload value moved to the store. In the event it is replaced by
+// {"logryph_blob": "sha256:<hex>", "size": <bytes>}, plus "encoding": "base64" when
+// the value was base64 text and its decoded bytes were stored. An upstream can
+// send the same shape, so references are never collected from payloads: the
+// event's signed BlobRefs lists the digests Logryph stored, and the chain covers
+// each blob through it.
+const RefKey = "logryph_blob"
+
+// EncodingBase64 is the encoding of a value whose decoded bytes were stored.
+const EncodingBase64 = "base64"
+
+// NewRef returns the reference that replaces a stored value.
+func NewRef(digest string, size int, encoding string) map[string]interface{} {
+	ref := map[string]interface{}{RefKey: RefPrefix + digest, "size": size}
+	if encoding != "" {
+		ref["encoding"] = encoding
+	}
+	return ref
+}
+
+// RefDigest returns the digest of a reference, or false when v is not one.
+func RefDigest(v interface{}) (string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	ref, ok := m[RefKey].(string)
+	if !ok || !strings.HasPrefix(ref, RefPrefix) {
+		return "", false
+	}
+	digest, err := ParseRef(ref)
+	return digest, err == nil
+}
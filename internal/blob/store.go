@@ -0,0 +1,187 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/assert"
+)
+
+// DefaultDir is where the proxy keeps blobs, next to logryph.db.
+const DefaultDir = "blobs"
+
+// RefPrefix starts every blob reference stored in the ledger: "sha256:<hex>".
+const RefPrefix = "sha256:"
+
+// ErrNotFound is returned when no blob has the requested digest.
+var ErrNotFound = errors.New("blob not found")
+
+// Store is a content-addressed blob store on disk. Each blob is a file named by
+// the hex SHA-256 of its bytes under a two-character fan-out directory, so
+// identical payloads are stored once however many events reference them.
+// Safe for concurrent use: blobs are written under a temporary name and renamed.
+type Store struct {
+	dir string
+}
+
+// NewStore returns a store rooted at dir. The directory is created on first write.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the root directory of the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// ValidDigest reports whether digest is a lowercase hex SHA-256.
+func ValidDigest(digest string) bool {
+	if len(digest) != sha256.Size*2 {
+		return false
+	}
+	for i := 0; i < len(digest); i++ {
+		c := digest[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseRef returns the digest of a "sha256:<hex>" reference, or of a bare digest.
+func ParseRef(ref string) (string, error) {
+	digest := strings.ToLower(strings.TrimPrefix(ref, RefPrefix))
+	if !ValidDigest(digest) {
+		return "", fmt.Errorf("invalid blob reference %q", ref)
+	}
+	return digest, nil
+}
+
+// Path returns the file of a blob.
+func (s *Store) Path(digest string) (string, error) {
+	if err := assert.Check(s != nil && s.dir != "", "blob store dir must be set"); err != nil {
+		return "", err
+	}
+	if !ValidDigest(digest) {
+		return "", fmt.Errorf("invalid blob digest %q", digest)
+	}
+	return filepath.Join(s.dir, digest[:2], digest), nil
+}
+
+// Put stores data and returns its digest.
+func (s *Store) Put(data []byte) (string, error) {
+	w, err := s.Create()
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Abort()
+		return "", err
+	}
+	return w.Commit()
+}
+
+// Has reports whether a blob is stored.
+func (s *Store) Has(digest string) bool {
+	path, err := s.Path(digest)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// Open returns a reader over a stored blob.
+func (s *Store) Open(digest string) (*os.File, error) {
+	path, err := s.Path(digest)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, digest)
+	}
+	return f, err
+}
+
+// Verify re-hashes a stored blob and fails if its bytes no longer match its name.
+func (s *Store) Verify(digest string) error {
+	f, err := s.Open(digest)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("reading blob %s: %w", digest, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != digest {
+		return fmt.Errorf("blob %s is corrupt: content hashes to %s", digest, got)
+	}
+	return nil
+}
+
+// Writer streams a blob into the store; its digest is known once committed.
+type Writer struct {
+	store *Store
+	f     *os.File
+	hash  hash.Hash
+}
+
+// Create starts a new blob.
+func (s *Store) Create() (*Writer, error) {
+	if err := assert.Check(s != nil && s.dir != "", "blob store dir must be set"); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating blob dir: %w", err)
+	}
+	f, err := os.CreateTemp(s.dir, ".blob-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating blob file: %w", err)
+	}
+	return &Writer{store: s, f: f, hash: sha256.New()}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.hash.Write(p)
+	return w.f.Write(p)
+}
+
+// Commit files the blob under its digest and returns it. A blob that is
+// already stored is kept as is.
+func (w *Writer) Commit() (string, error) {
+	digest := hex.EncodeToString(w.hash.Sum(nil))
+	if err := w.f.Close(); err != nil {
+		_ = os.Remove(w.f.Name())
+		return "", fmt.Errorf("closing blob file: %w", err)
+	}
+	path, err := w.store.Path(digest)
+	if err != nil {
+		_ = os.Remove(w.f.Name())
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		_ = os.Remove(w.f.Name())
+		return "", fmt.Errorf("creating blob dir: %w", err)
+	}
+	if err := os.Rename(w.f.Name(), path); err != nil {
+		_ = os.Remove(w.f.Name())
+		return "", fmt.Errorf("storing blob: %w", err)
+	}
+	return digest, nil
+}
+
+// Abort discards a blob that will not be committed.
+func (w *Writer) Abort() {
+	_ = w.f.Close()
+	_ = os.Remove(w.f.Name())
+}
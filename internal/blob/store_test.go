@@ -0,0 +1,67 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestPutIsContentAddressed(t *testing.T) {
+	s := NewStore(t.TempDir())
+	data := []byte("screenshot bytes")
+	digest, err := s.Put(data)
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	if digest != hex.EncodeToString(sum[:]) {
+		t.Fatalf("expected digest of the content, got %s", digest)
+	}
+	again, err := s.Put(data)
+	if err != nil || again != digest {
+		t.Fatalf("expected identical content to map to the same blob: %s %v", again, err)
+	}
+	if !s.Has(digest) {
+		t.Fatal("expected blob to be stored")
+	}
+	if err := s.Verify(digest); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+}
+
+func TestVerifyDetectsCorruptionAndMissingBlobs(t *testing.T) {
+	s := NewStore(t.TempDir())
+	digest, err := s.Put([]byte("original"))
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	path, _ := s.Path(digest)
+	if err := os.WriteFile(path, []byte("tampered"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify(digest); err == nil {
+		t.Error("expected tampered blob to fail verification")
+	}
+	missing := hex.EncodeToString(make([]byte, sha256.Size))
+	if err := s.Verify(missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.Path("../../etc/passwd"); err == nil {
+		t.Error("expected invalid digest to be rejected")
+	}
+}
+
+func TestRefDigest(t *testing.T) {
+	a := hex.EncodeToString(make([]byte, sha256.Size))
+	if digest, ok := RefDigest(NewRef(a, 10, EncodingBase64)); !ok || digest != a {
+		t.Errorf("RefDigest: %s %v", digest, ok)
+	}
+	if _, ok := RefDigest(map[string]interface{}{"logryph_blob": "md5:abc"}); ok {
+		t.Error("expected a non-sha256 reference to be rejected")
+	}
+	if digest, err := ParseRef("sha256:" + a); err != nil || digest != a {
+		t.Errorf("ParseRef: %s %v", digest, err)
+	}
+}
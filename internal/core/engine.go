@@ -3,7 +3,10 @@ package core
 import (
 	"sync"
 
+	"github.com/slyt3/Logryph/internal/approval"
+	"github.com/slyt3/Logryph/internal/blob"
 	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/mirror"
 	"github.com/slyt3/Logryph/internal/observer"
 )
 
@@ -12,7 +15,10 @@ type Engine struct {
 	Worker          *ledger.Worker
 	ActiveTasks     *sync.Map // task_id -> state
 	Observer        *observer.ObserverEngine
-	LastEventByTask *sync.Map // task_id -> last_event_id
+	LastEventByTask *sync.Map       // task_id -> last_event_id
+	Approvals       *approval.Queue // calls held by require_approval rules
+	Blobs           *blob.Store     // payloads referenced from events by digest
+	Mirror          *mirror.Tracker // calls mirrored to the shadow server
 }
 
 // NewEngine creates a new core state engine
@@ -22,5 +28,8 @@ func NewEngine(worker *ledger.Worker, obs *observer.ObserverEngine) *Engine {
 		Observer:        obs,
 		ActiveTasks:     &sync.Map{},
 		LastEventByTask: &sync.Map{},
+		Approvals:       approval.NewQueue(),
+		Blobs:           blob.NewStore(blob.DefaultDir),
+		Mirror:          mirror.NewTracker(),
 	}
 }
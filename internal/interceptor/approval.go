@@ -0,0 +1,182 @@
+package interceptor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/approval"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/mcp"
+	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// Reasons recorded on approval decisions Logryph takes itself.
+const (
+	approvalReasonTimeout   = "timeout"
+	approvalReasonAbandoned = "client_gone"
+	approvalReasonShutdown  = "shutdown"
+	approvalReasonQueueFull = "queue_full"
+)
+
+// observeHeldRequest records a call matched by a require_approval rule, parks it in
+// the approval queue and waits for an operator's verdict, the approval timeout (which
+// applies the configured default decision) or the agent going away. The decision is
+// recorded as an approval_decision event whose ParentID is the held call. Returns the
+// body to forward when approved, or a blocked requestError carrying the JSON-RPC error.
+// Over stdio the relay reads no further agent messages while a call is held.
+func (i *Interceptor) observeHeldRequest(body []byte, ex *exchange, requestID, taskID, batchID string, resolved observer.Action, mcpReq *mcp.MCPRequest, rule *observer.Rule, stored *requestBody) ([]byte, error) {
+	if err := assert.NotNil(rule, "approval rule"); err != nil {
+		return nil, err
+	}
+	if err := assert.NotNil(i.Core.Approvals, "approval queue"); err != nil {
+		return nil, err
+	}
+	forward, heldID, err := i.applyRedactionAndSubmit(rule, body, ex, requestID, taskID, batchID, resolved, mcpReq, stored)
+	if err != nil {
+		return nil, err
+	}
+	// Queue the params as forwarded, after the rule's redaction: the queue is
+	// shown to approvers over the admin API.
+	var forwarded struct {
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(forward, &forwarded); err != nil {
+		logging.Warn("approval_params_decode_failed", logging.Fields{Component: "interceptor", EventID: heldID, PolicyID: rule.ID, Error: err.Error()})
+	}
+	params := forwarded.Params
+	settings := i.Core.Observer.GetApprovals()
+	now := time.Now()
+	req := approval.Request{
+		ID:          heldID,
+		Method:      mcpReq.Method,
+		Action:      resolved.Name,
+		PolicyID:    rule.ID,
+		RiskLevel:   rule.RiskLevel,
+		SessionID:   i.sessions.lookup(ex.scope()),
+		Upstream:    ex.upstream,
+		Params:      params,
+		RequestedAt: now,
+		Deadline:    now.Add(settings.Timeout()),
+	}
+
+	verdict := i.awaitVerdict(ex, req, settings)
+	i.submitApprovalDecision(ex, req, verdict)
+	if verdict.Decision == approval.Approved {
+		return forward, nil
+	}
+
+	// The held call will never get a response; stop tracking it.
+	i.inflight.resolve(ex.scope(), rpcIDKey(mcpReq.ID))
+	reqErr := &requestError{status: http.StatusOK, code: codePolicyDenied, message: rejectionMessage(req, verdict), blocked: true}
+	if mcpReq.ID != nil {
+		reqErr.reply, _ = json.Marshal(&mcp.MCPResponse{JSONRPC: "2.0", ID: mcpReq.ID, Error: map[string]interface{}{
+			"code":    codePolicyDenied,
+			"message": reqErr.message,
+			"data":    map[string]interface{}{"policy_id": req.PolicyID, "event_id": req.ID},
+		}})
+	}
+	return nil, reqErr
+}
+
+// awaitVerdict blocks until the held call is decided. Logryph decides itself (empty
+// Approver) on timeout, when the agent disconnects, or when the queue is full.
+func (i *Interceptor) awaitVerdict(ex *exchange, req approval.Request, settings observer.Approvals) approval.Verdict {
+	verdicts, err := i.Core.Approvals.Hold(req)
+	if err != nil {
+		logging.Warn("approval_hold_failed", logging.Fields{Component: "interceptor", EventID: req.ID, PolicyID: req.PolicyID, Error: err.Error()})
+		reason := approvalReasonQueueFull
+		if errors.Is(err, approval.ErrClosed) {
+			reason = approvalReasonShutdown
+		}
+		return approval.Verdict{Decision: approval.Rejected, Reason: reason}
+	}
+	logging.Warn("approval_pending", logging.Fields{Component: "interceptor", Method: req.Action, EventID: req.ID, PolicyID: req.PolicyID, RiskLevel: req.RiskLevel})
+
+	var done <-chan struct{} // nil for stdio: never fires
+	if ex.ctx != nil {
+		done = ex.ctx.Done()
+	}
+	timer := time.NewTimer(time.Until(req.Deadline))
+	defer timer.Stop()
+
+	var fallback approval.Verdict
+	select {
+	case v := <-verdicts:
+		return v
+	case <-timer.C:
+		fallback = approval.Verdict{Decision: approval.Rejected, Reason: approvalReasonTimeout}
+		if settings.ApproveOnTimeout() {
+			fallback.Decision = approval.Approved
+		}
+	case <-done:
+		fallback = approval.Verdict{Decision: approval.Rejected, Reason: approvalReasonAbandoned}
+	}
+	if !i.Core.Approvals.Release(req.ID) {
+		// An operator decided just before we gave up; their verdict wins.
+		return <-verdicts
+	}
+	return fallback
+}
+
+// submitApprovalDecision records a verdict as a signed approval_decision event linked
+// to the held call. Operator decisions carry the user actor, Logryph's own the system actor.
+func (i *Interceptor) submitApprovalDecision(ex *exchange, req approval.Request, v approval.Verdict) {
+	if err := assert.Check(i.Core.Worker != nil, "worker must be initialized"); err != nil {
+		return
+	}
+	now := time.Now()
+	logging.Info("approval_"+string(v.Decision), logging.Fields{Component: "interceptor", Method: req.Action, EventID: req.ID, PolicyID: req.PolicyID})
+
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = now
+	event.Actor = "system"
+	event.EventType = "approval_decision"
+	event.Method = "logryph:approval"
+	event.Params = map[string]interface{}{
+		"decision":  string(v.Decision),
+		"action":    req.Action,
+		"waited_ms": now.Sub(req.RequestedAt).Milliseconds(),
+	}
+	if v.Approver != "" {
+		event.Actor = "user"
+		event.Params["approver"] = v.Approver
+		event.Params["credential"] = v.Credential
+	}
+	if v.Note != "" {
+		event.Params["approver_note"] = v.Note
+	}
+	if v.Reason != "" {
+		event.Params["reason"] = v.Reason
+	}
+	event.ParentID = req.ID
+	event.PolicyID = req.PolicyID
+	event.SessionID = req.SessionID
+	event.Upstream = ex.upstream
+	i.Core.Worker.Submit(event)
+}
+
+func rejectionMessage(req approval.Request, v approval.Verdict) string {
+	switch v.Reason {
+	case approvalReasonTimeout:
+		return fmt.Sprintf("Approval for policy %s timed out", req.PolicyID)
+	case approvalReasonShutdown, approvalReasonQueueFull, approvalReasonAbandoned:
+		return fmt.Sprintf("Approval for policy %s unavailable (%s)", req.PolicyID, v.Reason)
+	}
+	return fmt.Sprintf("Request rejected by approver (policy %s)", req.PolicyID)
+}
+
+// RejectPendingApprovals rejects every held call and refuses new holds, so that
+// proxy handlers waiting on approvals return before the servers shut down.
+func (i *Interceptor) RejectPendingApprovals() {
+	if i.Core == nil {
+		return
+	}
+	i.Core.Approvals.Close(approval.Verdict{Decision: approval.Rejected, Reason: approvalReasonShutdown})
+}
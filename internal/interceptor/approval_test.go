@@ -0,0 +1,135 @@
+package interceptor
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/approval"
+)
+
+const approvalPolicy = `
+version: "1.0"
+approvals:
+  timeout_seconds: 1
+policies:
+  - id: "financial-ops"
+    match_methods: ["stripe:*"]
+    action: "require_approval"
+    risk_level: "critical"
+`
+
+// decideWhenPending waits for one held call and answers it.
+func decideWhenPending(t *testing.T, i *Interceptor, v approval.Verdict) {
+	t.Helper()
+	go func() {
+		for n := 0; n < 200; n++ {
+			if pending := i.Core.Approvals.Pending(); len(pending) > 0 {
+				if err := i.Core.Approvals.Decide(pending[0].ID, v); err != nil {
+					t.Errorf("Decide failed: %v", err)
+				}
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Error("no call was held")
+	}()
+}
+
+func TestRequireApprovalForwardsApprovedCall(t *testing.T) {
+	i, drain := newTestInterceptor(t, approvalPolicy)
+	decideWhenPending(t, i, approval.Verdict{Decision: approval.Approved, Approver: "alice"})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewBufferString(toolCall(1, "stripe:refund")))
+	if forward := i.InterceptRequest(w, req); forward == nil {
+		t.Fatalf("expected approved call to be forwarded, got %s", w.Body.String())
+	}
+
+	var heldID string
+	var decision map[string]interface{}
+	var decisionActor, decisionParent string
+	for _, e := range drain() {
+		switch e.EventType {
+		case "tool_call":
+			heldID = e.ID
+		case "approval_decision":
+			decision, decisionActor, decisionParent = e.Params, e.Actor, e.ParentID
+		}
+	}
+	if decision == nil {
+		t.Fatal("expected an approval_decision event")
+	}
+	if decisionActor != "user" || decisionParent != heldID || decision["approver"] != "alice" || decision["decision"] != "approved" {
+		t.Errorf("unexpected decision event: actor=%s parent=%s (held %s) params=%v", decisionActor, decisionParent, heldID, decision)
+	}
+}
+
+func TestRequireApprovalTimesOutToReject(t *testing.T) {
+	i, drain := newTestInterceptor(t, approvalPolicy)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewBufferString(toolCall(7, "stripe:refund")))
+	if forward := i.InterceptRequest(w, req); forward != nil {
+		t.Fatal("expected timed-out call not to be forwarded")
+	}
+	var resp struct {
+		ID    int `json:"id"`
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.ID != 7 || resp.Error.Code != codePolicyDenied {
+		t.Fatalf("unexpected response %q", w.Body.String())
+	}
+
+	for _, e := range drain() {
+		if e.EventType == "approval_decision" {
+			if e.Actor != "system" || e.Params["reason"] != approvalReasonTimeout {
+				t.Errorf("unexpected timeout decision: actor=%s params=%v", e.Actor, e.Params)
+			}
+			return
+		}
+	}
+	t.Error("expected an approval_decision event")
+}
+
+func TestHeldCallQueuesRedactedParams(t *testing.T) {
+	i, drain := newTestInterceptor(t, `
+approvals:
+  timeout_seconds: 2
+policies:
+  - id: "refunds"
+    match_methods: ["stripe:*"]
+    action: "require_approval"
+    risk_level: "high"
+    redact: ["card_number"]
+`)
+	queued := make(chan json.RawMessage, 1)
+	go func() {
+		for n := 0; n < 200; n++ {
+			if pending := i.Core.Approvals.Pending(); len(pending) > 0 {
+				queued <- pending[0].Params
+				_ = i.Core.Approvals.Decide(pending[0].ID, approval.Verdict{Decision: approval.Rejected, Approver: "alice"})
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		close(queued)
+	}()
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"stripe:refund","arguments":{"card_number":"4242424242424242","amount":10}}}`
+	i.InterceptRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewBufferString(body)))
+	drain()
+
+	params, ok := <-queued
+	if !ok {
+		t.Fatal("no call was held")
+	}
+	if bytes.Contains(params, []byte("4242")) || !bytes.Contains(params, []byte(`"amount":10`)) {
+		t.Errorf("expected queued params with card_number redacted, got %s", params)
+	}
+}
@@ -0,0 +1,183 @@
+package interceptor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/mcp"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+const maxBatchSize = 256
+
+// isBatch reports whether a JSON-RPC body is a batch (a top-level array).
+func isBatch(body []byte) bool {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) == 0 {
+		return false
+	}
+	return trimmed[0] == '['
+}
+
+// isRequestMessage reports whether raw is a JSON-RPC 2.0 request or notification
+// (as opposed to a response or malformed input). It never asserts, so it is safe
+// to use as a filter before the strict request path.
+func isRequestMessage(raw []byte) bool {
+	if len(raw) == 0 || len(raw) > maxMessageBytes {
+		return false
+	}
+	var msg mcp.MCPMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return false
+	}
+	return msg.JSONRPC == "2.0" && msg.Method != ""
+}
+
+// isResponseMessage reports whether raw is a JSON-RPC 2.0 response, such as a
+// client's answer to a sampling or roots request. Like isRequestMessage it never
+// asserts. Responses are forwarded without policy evaluation.
+func isResponseMessage(raw []byte) bool {
+	if len(raw) == 0 || len(raw) > maxMessageBytes {
+		return false
+	}
+	var msg struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Method  *string         `json:"method"`
+		ID      interface{}     `json:"id"`
+		Result  json.RawMessage `json:"result"`
+		Error   json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return false
+	}
+	return msg.JSONRPC == "2.0" && msg.Method == nil && msg.ID != nil && (len(msg.Result) > 0 || len(msg.Error) > 0)
+}
+
+// observeBatchRequest evaluates policy and records a tool_call per batch member,
+// all sharing one batch ID. Client responses are forwarded untouched. The
+// original bytes are forwarded unless redaction changed a member. If any member
+// matches a deny rule the whole batch is rejected and nothing is forwarded. A
+// batch that cannot be checked (invalid JSON, too many members, or a member that
+// is neither a request nor a response) goes through observeUnchecked, and so
+// does a member that fails observation: refused (dropped, for a member) while
+// deny or approval rules are loaded, recorded and forwarded otherwise.
+func (i *Interceptor) observeBatchRequest(body []byte, ex *exchange) ([]byte, error) {
+	var members []json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil {
+		return i.observeUnchecked(body, ex, "Invalid JSON-RPC batch: "+err.Error())
+	}
+	if len(members) == 0 || len(members) > maxBatchSize {
+		return i.observeUnchecked(body, ex, fmt.Sprintf("Invalid JSON-RPC batch: %d members (max %d)", len(members), maxBatchSize))
+	}
+	for j := 0; j < len(members); j++ {
+		if !isRequestMessage(members[j]) && !isResponseMessage(members[j]) {
+			return i.observeUnchecked(body, ex, fmt.Sprintf("Invalid JSON-RPC batch: member %d is not a request or response", j))
+		}
+	}
+
+	batchID := uuid.New().String()[:8]
+	if rule := i.batchDenial(members, ex); rule != nil {
+		return nil, i.rejectBatch(members, ex, batchID, rule)
+	}
+
+	forward := make([]json.RawMessage, 0, len(members))
+	changed := false
+	for j := 0; j < maxBatchSize; j++ {
+		if j >= len(members) {
+			break
+		}
+		member := members[j]
+		if !isRequestMessage(member) {
+			forward = append(forward, member)
+			continue
+		}
+		out, err := i.observeRequest(member, ex, batchID)
+		var reqErr *requestError
+		if errors.As(err, &reqErr) && reqErr.blocked {
+			// A deny rule appeared after batchDenial (hot reload, concurrent
+			// calls filling a rate window): never forward.
+			changed = true
+			continue
+		}
+		if err != nil {
+			logging.Warn("batch_member_observe_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
+			if i.enforces() {
+				// Never forward a member that was not checked against policy.
+				changed = true
+				continue
+			}
+			out = member
+			i.submitUnparsedBody(member, ex, err.Error())
+		}
+		if !bytes.Equal(out, member) {
+			changed = true
+		}
+		forward = append(forward, out)
+	}
+
+	if !changed {
+		return body, nil
+	}
+	return json.Marshal(forward)
+}
+
+// batchDenial returns the first deny or require_approval rule matched by a request
+// in the batch, or nil. Members are vetted together so that calls earlier in the
+// batch count towards rate limits hit by later ones.
+func (i *Interceptor) batchDenial(members []json.RawMessage, ex *exchange) *observer.Rule {
+	if i.Core.Observer == nil {
+		return nil
+	}
+	actions := make([]observer.Action, 0, len(members))
+	for j := 0; j < maxBatchSize; j++ {
+		if j >= len(members) {
+			break
+		}
+		if !isRequestMessage(members[j]) {
+			continue
+		}
+		if action, ok := i.requestAction(members[j], ex); ok {
+			actions = append(actions, action)
+		}
+	}
+	rules := i.Core.Observer.Peek(actions)
+	for j := 0; j < maxBatchSize; j++ {
+		if j >= len(rules) {
+			break
+		}
+		if rules[j] != nil && rules[j].Effect() != observer.RuleActionTag {
+			return rules[j]
+		}
+	}
+	return nil
+}
+
+// observeServerBatch records every member of a server-to-agent batch under one batch ID.
+func (i *Interceptor) observeServerBatch(body []byte, ex *exchange) {
+	if err := assert.Check(len(body) > 0, "batch body must not be empty"); err != nil {
+		return
+	}
+
+	var members []mcp.MCPMessage
+	if err := json.Unmarshal(body, &members); err != nil {
+		logging.Warn("server_batch_invalid", logging.Fields{Component: "interceptor", Error: err.Error()})
+		return
+	}
+	if len(members) > maxBatchSize {
+		logging.Warn("server_batch_too_large", logging.Fields{Component: "interceptor"})
+		return
+	}
+
+	batchID := uuid.New().String()[:8]
+	for j := 0; j < maxBatchSize; j++ {
+		if j >= len(members) {
+			break
+		}
+		i.dispatchServerMessage(&members[j], ex, batchID)
+	}
+}
@@ -0,0 +1,342 @@
+package interceptor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/mcp"
+	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// codePolicyDenied is the JSON-RPC error code sent for requests stopped by an
+// action: deny rule (implementation-defined server error range).
+const codePolicyDenied = -32001
+
+// denyRequest records a blocked event for a request stopped by a deny rule and
+// returns the requestError carrying the JSON-RPC error to send the agent.
+// Notifications are blocked silently, since JSON-RPC forbids replying to them.
+func (i *Interceptor) denyRequest(ex *exchange, taskID, batchID string, resolved observer.Action, mcpReq *mcp.MCPRequest, rule *observer.Rule, stored *requestBody) *requestError {
+	reply := i.submitBlockedEvent(ex, taskID, batchID, resolved, mcpReq, rule, stored, denialMessage(rule))
+	return &requestError{status: http.StatusOK, code: codePolicyDenied, message: denialMessage(rule), blocked: true, reply: reply}
+}
+
+func denialMessage(rule *observer.Rule) string {
+	if rule.Message != "" {
+		return rule.Message
+	}
+	if rule.RateLimit != nil {
+		return fmt.Sprintf("Rate limit of policy %s exceeded (%d calls per %ds)", rule.ID, rule.RateLimit.MaxCalls, rule.RateLimit.WindowSeconds)
+	}
+	return fmt.Sprintf("Request blocked by policy %s", rule.ID)
+}
+
+// submitBlockedEvent records a request that was not forwarded as a signed blocked
+// event and returns the encoded JSON-RPC error response for it, or nil for
+// notifications. The error's data points at the blocked event so the agent's
+// operator can look it up.
+func (i *Interceptor) submitBlockedEvent(ex *exchange, taskID, batchID string, resolved observer.Action, mcpReq *mcp.MCPRequest, rule *observer.Rule, stored *requestBody, message string) json.RawMessage {
+	if err := assert.NotNil(mcpReq, "mcpReq"); err != nil {
+		return nil
+	}
+	if err := assert.NotNil(rule, "deny rule"); err != nil {
+		return nil
+	}
+	if err := assert.Check(i.Core.Worker != nil, "worker must be initialized"); err != nil {
+		return nil
+	}
+
+	eventID := uuid.New().String()[:8]
+	rpcErr := map[string]interface{}{
+		"code":    codePolicyDenied,
+		"message": message,
+		"data": map[string]interface{}{
+			"policy_id": rule.ID,
+			"event_id":  eventID,
+		},
+	}
+	logging.Warn("request_blocked", logging.Fields{Component: "interceptor", TaskID: taskID, Method: resolved.Name, EventID: eventID, PolicyID: rule.ID, RiskLevel: rule.RiskLevel})
+
+	// Encode before submitting: the event's maps are cleared when it returns to the pool.
+	var reply json.RawMessage
+	if mcpReq.ID != nil {
+		encoded, err := json.Marshal(&mcp.MCPResponse{JSONRPC: "2.0", ID: mcpReq.ID, Error: rpcErr})
+		if err != nil {
+			logging.Error("blocked_reply_encode_failed", logging.Fields{Component: "interceptor", EventID: eventID, Error: err.Error()})
+		} else {
+			reply = encoded
+		}
+	}
+
+	event := pool.GetEvent()
+	event.ID = eventID
+	event.Timestamp = time.Now()
+	event.EventType = "blocked"
+	event.Actor = ex.actor
+	event.Method = mcpReq.Method
+	event.Params = mcpReq.Params
+	stored.apply(event)
+	event.Response = rpcErr
+	event.WasBlocked = true
+	event.PolicyID = rule.ID
+	event.RiskLevel = rule.RiskLevel
+	event.TaskID = taskID
+	event.BatchID = batchID
+	event.Upstream = ex.upstream
+	event.HTTP = ex.http
+	event.TraceID, event.SpanID = ex.trace.traceID, ex.trace.spanID
+	event.SessionID = i.sessions.touch(ex.scope(), event.Timestamp)
+	i.submitRecorded(ex, event, stored)
+	return reply
+}
+
+// codeInvalidRequest is the JSON-RPC error code sent for request bodies that
+// cannot be validated or checked against policy (Invalid Request).
+const codeInvalidRequest = -32600
+
+// refuseInvalid stops a request body that cannot be validated or checked
+// against policy, such as malformed JSON-RPC or an oversized batch, so that
+// nothing reaches the upstream unchecked.
+func (i *Interceptor) refuseInvalid(body []byte, ex *exchange, message string) *requestError {
+	return i.refuseBody(body, ex, codeInvalidRequest, message)
+}
+
+// codeInternalError is the JSON-RPC error code sent for requests whose
+// observation failed unexpectedly while deny or approval rules are loaded.
+const codeInternalError = -32603
+
+const internalErrorMessage = "Internal error; request could not be checked against policy"
+
+// refuseFailed stops a request body whose observation panicked (a strict
+// assertion) while deny or approval rules are loaded: it was not checked
+// against them, so it must not reach the upstream. Recorded like refuseInvalid.
+func (i *Interceptor) refuseFailed(body []byte, ex *exchange) *requestError {
+	return i.refuseBody(body, ex, codeInternalError, internalErrorMessage)
+}
+
+// failedReply is the JSON-RPC error refuseFailed answers with, built from the
+// envelope in prefix without recording anything: none for notifications, a
+// null ID when the envelope is unreadable.
+func failedReply(prefix []byte) []byte {
+	env := sniffEnvelope(prefix[:min(len(prefix), maxEnvelopePrefix)])
+	if env.Method != "" && env.ID == nil {
+		return nil
+	}
+	return encodeRefusal(env.ID, map[string]interface{}{"code": codeInternalError, "message": internalErrorMessage})
+}
+
+// refuseBody records a buffered body refused before policy evaluation from its
+// envelope, with its size and SHA-256, and returns the requestError for it.
+func (i *Interceptor) refuseBody(body []byte, ex *exchange, code int, message string) *requestError {
+	sum := sha256.Sum256(body)
+	stored := &requestBody{size: int64(len(body)), sha256: hex.EncodeToString(sum[:]), storage: bodyOmitted}
+	env := sniffEnvelope(body[:min(len(body), maxEnvelopePrefix)])
+	return i.refuse(env, stored, ex, code, message)
+}
+
+// observeUnchecked handles a request body that cannot be checked against policy.
+// While deny or approval rules are loaded, which it would bypass, it is refused
+// like refuseInvalid. Otherwise the proxy stays passive: the body is recorded
+// from its envelope like an unparsed body and forwarded unchanged.
+// Returns the body to forward upstream.
+func (i *Interceptor) observeUnchecked(body []byte, ex *exchange, message string) ([]byte, error) {
+	if i.enforces() {
+		return nil, i.refuseInvalid(body, ex, message)
+	}
+	i.submitUnparsedBody(body, ex, message)
+	return body, nil
+}
+
+// refuse records a request body stopped before policy evaluation as a blocked
+// event, from its envelope and body record, and returns the requestError
+// carrying the JSON-RPC error for the agent: none for notifications, a null ID
+// when the envelope is unreadable (e.g. a batch).
+func (i *Interceptor) refuse(env mcp.MCPRequest, stored *requestBody, ex *exchange, code int, message string) *requestError {
+	rpcErr := map[string]interface{}{"code": code, "message": message}
+	reqErr := &requestError{status: http.StatusBadRequest, code: code, message: message, blocked: true}
+	if env.Method == "" || env.ID != nil {
+		reqErr.reply = encodeRefusal(env.ID, rpcErr)
+	}
+	if env.Method == "" {
+		env.Method = unparsedMethod
+	}
+	logging.Warn("request_refused", logging.Fields{Component: "interceptor", Method: env.Method, Error: message})
+	if err := assert.Check(i.Core.Worker != nil, "worker must be initialized"); err != nil {
+		return reqErr
+	}
+
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.EventType = "blocked"
+	event.Actor = ex.actor
+	event.Method = env.Method
+	stored.apply(event)
+	event.Response = rpcErr
+	event.WasBlocked = true
+	event.Upstream = ex.upstream
+	event.HTTP = ex.http
+	event.TraceID, event.SpanID = ex.trace.traceID, ex.trace.spanID
+	event.SessionID = i.sessions.touch(ex.scope(), event.Timestamp)
+	i.submitRecorded(ex, event, stored)
+	return reqErr
+}
+
+// requestAction resolves the action of a batch member for the batch pre-scan,
+// reporting false for members that fail validation; those are left to the
+// regular observation path.
+func (i *Interceptor) requestAction(member []byte, ex *exchange) (observer.Action, bool) {
+	mcpReq, taskID, method, err := i.extractTaskMetadata(member)
+	if err != nil {
+		return observer.Action{}, false
+	}
+	resolved := observer.ResolveAction(method, mcpReq.Params)
+	resolved.TaskID = taskID
+	resolved.Actor = ex.actor
+	return resolved, true
+}
+
+// batchRejection explains why a batch member was blocked. Calls needing approval
+// cannot be held inside a batch, so they are rejected too (fail-closed).
+func batchRejection(rule *observer.Rule) string {
+	if rule.Effect() == observer.RuleActionApprove {
+		return fmt.Sprintf("Policy %s requires approval, which is not supported inside a batch", rule.ID)
+	}
+	return denialMessage(rule)
+}
+
+// rejectBatch blocks every request of a batch in which some member matched a deny
+// rule, so that no part of it reaches the upstream. Members record their own deny
+// rule when they have one, else batchRule. Own rules are found with Peek, like in
+// batchDenial, so rejected members are not counted against rate limits again.
+// Returns the batch of JSON-RPC errors.
+func (i *Interceptor) rejectBatch(members []json.RawMessage, ex *exchange, batchID string, batchRule *observer.Rule) *requestError {
+	if err := assert.Check(len(members) <= maxBatchSize, "batch exceeds max: %d", len(members)); err != nil {
+		return &requestError{status: http.StatusBadRequest, code: -32600, message: err.Error()}
+	}
+	batchMessage := "Batch rejected: " + batchRejection(batchRule)
+
+	type rejected struct {
+		raw    json.RawMessage
+		req    *mcp.MCPRequest
+		taskID string
+	}
+	requests := make([]rejected, 0, len(members))
+	actions := make([]observer.Action, 0, len(members))
+	for j := 0; j < maxBatchSize; j++ {
+		if j >= len(members) {
+			break
+		}
+		if !isRequestMessage(members[j]) {
+			continue
+		}
+		mcpReq, taskID, method, err := i.extractTaskMetadata(members[j])
+		if err != nil {
+			continue
+		}
+		resolved := observer.ResolveAction(method, mcpReq.Params)
+		resolved.TaskID = taskID
+		resolved.Actor = ex.actor
+		requests = append(requests, rejected{raw: members[j], req: mcpReq, taskID: taskID})
+		actions = append(actions, resolved)
+	}
+	var own []*observer.Rule
+	if i.Core.Observer != nil {
+		own = i.Core.Observer.Peek(actions)
+	}
+
+	replies := make([]json.RawMessage, 0, len(requests))
+	for j := 0; j < maxBatchSize; j++ {
+		if j >= len(requests) {
+			break
+		}
+		rule, message := batchRule, batchMessage
+		if j < len(own) && own[j] != nil && own[j].Effect() != observer.RuleActionTag {
+			rule, message = own[j], batchRejection(own[j])
+		}
+		stored := i.prepareBody(requests[j].raw, requests[j].req.Params, rule)
+		if reply := i.submitBlockedEvent(ex, requests[j].taskID, batchID, actions[j], requests[j].req, rule, stored, message); reply != nil {
+			replies = append(replies, reply)
+		}
+	}
+
+	reqErr := &requestError{status: http.StatusOK, code: codePolicyDenied, message: batchMessage, blocked: true}
+	if len(replies) > 0 {
+		reqErr.reply, _ = json.Marshal(replies)
+	}
+	return reqErr
+}
+
+// codeLedgerUnavailable is the JSON-RPC error code sent for requests refused
+// because the ledger cannot record them (ledger_unavailable: reject).
+const codeLedgerUnavailable = -32002
+
+const ledgerUnavailableMessage = "Audit ledger unavailable; request refused"
+
+// ledgerUnavailable reports whether new requests must be refused: the worker
+// failed to persist an event and the policy sets ledger_unavailable: reject.
+func (i *Interceptor) ledgerUnavailable() bool {
+	if i.Core == nil || i.Core.Worker == nil || i.Core.Observer == nil {
+		return false
+	}
+	if i.Core.Worker.IsHealthy() {
+		return false
+	}
+	return i.Core.Observer.GetLedgerUnavailable() == observer.LedgerUnavailableReject
+}
+
+// refuseUnrecorded stops a request body while the ledger is unavailable. No
+// event is recorded, since nothing can be. Every request in the body gets the
+// JSON-RPC error; a body that cannot be decoded gets one with a null ID.
+func refuseUnrecorded(body []byte) *requestError {
+	rpcErr := map[string]interface{}{"code": codeLedgerUnavailable, "message": ledgerUnavailableMessage}
+	batch := isBatch(body)
+	var members []json.RawMessage
+	if !batch {
+		members = []json.RawMessage{body}
+	} else if err := json.Unmarshal(body, &members); err != nil || len(members) > maxBatchSize {
+		members = []json.RawMessage{nil}
+	}
+
+	replies := make([]json.RawMessage, 0, len(members))
+	for j := 0; j < maxBatchSize; j++ {
+		if j >= len(members) {
+			break
+		}
+		var msg mcp.MCPMessage
+		if len(members[j]) > maxMessageBytes || json.Unmarshal(members[j], &msg) != nil {
+			replies = append(replies, encodeRefusal(nil, rpcErr))
+			continue
+		}
+		if msg.Method == "" || msg.ID == nil {
+			continue // Client responses and notifications get no reply
+		}
+		logging.Warn("request_refused_ledger_unavailable", logging.Fields{Component: "interceptor", Method: msg.Method})
+		replies = append(replies, encodeRefusal(msg.ID, rpcErr))
+	}
+
+	reqErr := &requestError{status: http.StatusServiceUnavailable, code: codeLedgerUnavailable, message: ledgerUnavailableMessage, blocked: true}
+	switch {
+	case len(replies) == 1 && !batch:
+		reqErr.reply = replies[0]
+	case len(replies) > 0:
+		reqErr.reply, _ = json.Marshal(replies)
+	}
+	return reqErr
+}
+
+func encodeRefusal(id interface{}, rpcErr map[string]interface{}) json.RawMessage {
+	encoded, err := json.Marshal(&mcp.MCPResponse{JSONRPC: "2.0", ID: id, Error: rpcErr})
+	if err != nil {
+		// An ID that decoded from JSON always encodes again.
+		encoded, _ = json.Marshal(&mcp.MCPResponse{JSONRPC: "2.0", Error: rpcErr})
+	}
+	return encoded
+}
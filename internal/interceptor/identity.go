@@ -0,0 +1,78 @@
+package interceptor
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+// identify names the agent behind a request from the configured identity
+// sources (see observer.Identity), tried in order, falling back to
+// identity.default. req is nil for the stdio relay, where only the default
+// applies. Returns "" for unidentified agents.
+func (i *Interceptor) identify(req *http.Request) string {
+	if i.Core == nil || i.Core.Observer == nil {
+		return ""
+	}
+	cfg := i.Core.Observer.GetIdentity()
+	if req != nil {
+		sources := cfg.IdentitySources()
+		for j := 0; j < len(sources); j++ {
+			name := ""
+			switch sources[j] {
+			case observer.IdentityMTLS:
+				name = mtlsIdentity(req)
+			case observer.IdentityBearer:
+				name = bearerIdentity(req, &cfg)
+			case observer.IdentityHeader:
+				name = headerIdentity(req, &cfg)
+			}
+			if name != "" {
+				return name
+			}
+		}
+	}
+	return cfg.Default
+}
+
+// mtlsIdentity names the agent after its client certificate. Only certificates
+// the TLS listener verified against its client CA bundle are trusted.
+func mtlsIdentity(req *http.Request) string {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	subject := req.TLS.PeerCertificates[0].Subject
+	name := subject.CommonName
+	if name == "" {
+		name = subject.String()
+	}
+	if !observer.ValidActor(name) {
+		return ""
+	}
+	return name
+}
+
+// bearerIdentity names the agent after its bearer token. Unknown tokens yield no
+// identity.
+func bearerIdentity(req *http.Request, cfg *observer.Identity) string {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	name, _ := cfg.TokenName(strings.TrimSpace(token))
+	return name
+}
+
+// headerIdentity takes the agent name from the configured header, as set by a
+// gateway that authenticated the agent.
+func headerIdentity(req *http.Request, cfg *observer.Identity) string {
+	if cfg.Header == "" {
+		return ""
+	}
+	name := strings.TrimSpace(req.Header.Get(cfg.Header))
+	if !observer.ValidActor(name) {
+		return ""
+	}
+	return name
+}
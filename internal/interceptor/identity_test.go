@@ -0,0 +1,73 @@
+package interceptor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const identityPolicy = `
+identity:
+  bearer_tokens: {"tok-billing": "billing-bot"}
+  header: "X-Agent-Name"
+policies:
+  - id: "ci-read-only"
+    match_methods: ["fs:write*"]
+    match_actors: ["ci-*"]
+    action: "deny"
+`
+
+func TestActorFromIdentitySources(t *testing.T) {
+	i, drain := newTestInterceptor(t, identityPolicy)
+
+	send := func(id int, tool string, prepare func(*http.Request)) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(toolCall(id, tool)))
+		prepare(req)
+		return i.InterceptRequest(httptest.NewRecorder(), req)
+	}
+	// mTLS wins over the header; unknown tokens and reserved names identify nobody.
+	send(1, "fs:read_file", func(req *http.Request) {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "agent-7"}}
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+		req.Header.Set("X-Agent-Name", "spoofed")
+	})
+	send(2, "fs:read_file", func(req *http.Request) { req.Header.Set("Authorization", "Bearer tok-billing") })
+	send(3, "fs:read_file", func(req *http.Request) { req.Header.Set("Authorization", "Bearer nope") })
+	send(4, "fs:read_file", func(req *http.Request) { req.Header.Set("X-Agent-Name", "system") })
+	if forward := send(5, "fs:write_file", func(req *http.Request) { req.Header.Set("X-Agent-Name", "ci-runner") }); forward != nil {
+		t.Error("expected match_actors deny rule to block ci-runner")
+	}
+	if forward := send(6, "fs:write_file", func(req *http.Request) { req.Header.Set("X-Agent-Name", "billing-bot") }); forward == nil {
+		t.Error("expected billing-bot write to be forwarded")
+	}
+
+	want := []string{"agent-7", "billing-bot", "", "", "ci-runner", "billing-bot"}
+	var actors []string
+	for _, e := range drain() {
+		if e.EventType == "tool_call" || e.EventType == "blocked" {
+			actors = append(actors, e.Actor)
+			if e.EventType == "blocked" && e.Actor != "ci-runner" {
+				t.Errorf("unexpected blocked event for %q", e.Actor)
+			}
+		}
+	}
+	if strings.Join(actors, ",") != strings.Join(want, ",") {
+		t.Errorf("expected actors %q, got %q", want, actors)
+	}
+}
+
+func TestDefaultIdentityNamesStdioAgent(t *testing.T) {
+	i, _ := newTestInterceptor(t, "identity:\n  default: \"local-agent\"\npolicies: []\n")
+	if got := i.identify(nil); got != "local-agent" {
+		t.Errorf("expected default identity, got %q", got)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "unverified"}}}}
+	if got := i.identify(req); got != "local-agent" {
+		t.Errorf("expected unverified certificate to be ignored, got %q", got)
+	}
+}
@@ -0,0 +1,149 @@
+package interceptor
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+const (
+	maxInflightCalls = 4096
+	inflightTTL      = 30 * time.Minute
+	stdioSession     = "stdio"
+)
+
+// exchange describes the transport context a message was observed in.
+// session scopes JSON-RPC IDs: the Mcp-Session-Id header when present, else the
+// client connection, or stdioSession for the stdio relay. upstream names the routed
+// tool server (empty without a routing table). httpStatus is the upstream status
+// code of the carrying HTTP response (0 for requests and stdio) and http the
+// captured transport metadata (nil for stdio). assignedSession is the
+// Mcp-Session-Id a server returned on a response, if any. ctx is the HTTP
+// request's context (nil for stdio); it ends a held call when the agent goes away.
+// actor is the identity of the agent that sent a request ("" when unidentified)
+// and recorded the events its request produced, for receipts. trace is the W3C
+// trace context of an HTTP exchange (zero for stdio); mirror is set when the
+// request is to be mirrored to the shadow server.
+type exchange struct {
+	ctx             context.Context
+	session         string
+	assignedSession string
+	upstream        string
+	httpStatus      int
+	http            *models.HTTPMetadata
+	actor           string
+	recorded        []recordedEvent
+	trace           traceContext
+	mirror          *mirrorCall
+}
+
+// scope keys in-flight calls and sessions: IDs are only unique per session and upstream.
+func (e *exchange) scope() string {
+	return e.upstream + "\x00" + e.session
+}
+
+// sessionScope is the scope later requests of a session opened by this response
+// will have: the server-assigned Mcp-Session-Id, else the current scope.
+func (e *exchange) sessionScope() string {
+	if e.assignedSession == "" {
+		return e.scope()
+	}
+	return e.upstream + "\x00" + e.assignedSession
+}
+
+// inflightCall is a recorded tool_call awaiting its response. action is the
+// resolved policy action (see observer.ResolveAction). params is kept only for
+// initialize, whose client info goes into session_started.
+type inflightCall struct {
+	eventID string
+	method  string
+	action  string
+	taskID  string
+	actor   string
+	started time.Time
+	params  map[string]interface{}
+}
+
+// inflightTracker pairs responses with the requests that produced them, keyed by
+// session and JSON-RPC ID. Calls that never get a response expire after inflightTTL.
+type inflightTracker struct {
+	mu    sync.Mutex
+	calls map[string]inflightCall
+}
+
+func newInflightTracker() *inflightTracker {
+	return &inflightTracker{calls: make(map[string]inflightCall)}
+}
+
+// rpcIDKey renders a JSON-RPC ID so that 1 and "1" stay distinct.
+// Returns "" for notifications (nil ID) or unencodable IDs.
+func rpcIDKey(id interface{}) string {
+	if id == nil {
+		return ""
+	}
+	b, err := json.Marshal(id)
+	if err != nil || len(b) > 256 {
+		return ""
+	}
+	return string(b)
+}
+
+func inflightKey(session, rpcID string) string {
+	return session + "\x00" + rpcID
+}
+
+// track registers a call. When the table is full, expired entries are swept
+// first; if it is still full the call is left uncorrelated.
+func (t *inflightTracker) track(session, rpcID string, call inflightCall) {
+	if t == nil || rpcID == "" {
+		return
+	}
+	if err := assert.Check(call.eventID != "", "inflight call must have an event ID"); err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.calls) >= maxInflightCalls {
+		t.sweepLocked(call.started)
+	}
+	if len(t.calls) >= maxInflightCalls {
+		logging.Warn("inflight_table_full", logging.Fields{Component: "interceptor", Method: call.method})
+		return
+	}
+	t.calls[inflightKey(session, rpcID)] = call
+}
+
+// resolve removes and returns the call matching a response, if any.
+func (t *inflightTracker) resolve(session, rpcID string) (inflightCall, bool) {
+	if t == nil || rpcID == "" {
+		return inflightCall{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := inflightKey(session, rpcID)
+	call, ok := t.calls[key]
+	if ok {
+		delete(t.calls, key)
+	}
+	return call, ok
+}
+
+func (t *inflightTracker) sweepLocked(now time.Time) {
+	swept := 0
+	for key, call := range t.calls {
+		if now.Sub(call.started) > inflightTTL {
+			delete(t.calls, key)
+			swept++
+		}
+	}
+	if swept > 0 {
+		logging.Warn("inflight_calls_expired", logging.Fields{Component: "interceptor"})
+	}
+}
@@ -0,0 +1,50 @@
+package interceptor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInflightTrackerResolvesPerSession(t *testing.T) {
+	tr := newInflightTracker()
+	started := time.Now()
+	tr.track("sess-a", rpcIDKey(float64(1)), inflightCall{eventID: "call-a", method: "tools/call", started: started})
+	tr.track("sess-b", rpcIDKey(float64(1)), inflightCall{eventID: "call-b", method: "tools/list", started: started})
+
+	call, ok := tr.resolve("sess-b", rpcIDKey(float64(1)))
+	if !ok || call.eventID != "call-b" || call.method != "tools/list" {
+		t.Fatalf("expected call-b, got %+v (ok=%v)", call, ok)
+	}
+	if _, ok := tr.resolve("sess-b", rpcIDKey(float64(1))); ok {
+		t.Fatal("expected call to be removed after resolve")
+	}
+	if _, ok := tr.resolve("sess-a", rpcIDKey("1")); ok {
+		t.Fatal("string ID \"1\" must not match numeric ID 1")
+	}
+	if call, ok := tr.resolve("sess-a", rpcIDKey(float64(1))); !ok || call.eventID != "call-a" {
+		t.Fatalf("expected call-a, got %+v (ok=%v)", call, ok)
+	}
+}
+
+func TestInflightTrackerIgnoresNotifications(t *testing.T) {
+	tr := newInflightTracker()
+	tr.track("s", rpcIDKey(nil), inflightCall{eventID: "n", started: time.Now()})
+	if len(tr.calls) != 0 {
+		t.Fatalf("notifications must not be tracked, got %d entries", len(tr.calls))
+	}
+}
+
+func TestInflightTrackerSweepsExpiredWhenFull(t *testing.T) {
+	tr := newInflightTracker()
+	old := time.Now().Add(-2 * inflightTTL)
+	for j := 0; j < maxInflightCalls; j++ {
+		tr.track("s", rpcIDKey(j), inflightCall{eventID: "old", started: old})
+	}
+	tr.track("s", rpcIDKey("fresh"), inflightCall{eventID: "fresh", started: time.Now()})
+	if len(tr.calls) != 1 {
+		t.Fatalf("expected expired calls to be swept, got %d entries", len(tr.calls))
+	}
+	if _, ok := tr.resolve("s", rpcIDKey("fresh")); !ok {
+		t.Fatal("expected fresh call to be tracked")
+	}
+}
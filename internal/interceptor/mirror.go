@@ -0,0 +1,256 @@
+package interceptor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/mcp"
+	"github.com/slyt3/Logryph/internal/mirror"
+	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+// headerMirror marks requests sent to the shadow server.
+const headerMirror = "X-Logryph-Mirror"
+
+// Request headers copied to the shadow server. Credentials and Mcp-Session-Id
+// belong to the primary server and are not sent.
+var mirroredHeaders = []string{"Content-Type", "Accept", "Mcp-Protocol-Version", headerTraceparent}
+
+// mirrorCall is a forwarded request selected for mirroring: the recorded
+// tool_call, its JSON-RPC ID, resolved action and caller, and the body as
+// forwarded (after redaction).
+type mirrorCall struct {
+	eventID   string
+	requestID interface{}
+	method    string // Resolved action name
+	rpcMethod string
+	actor     string
+	body      []byte
+}
+
+// selectMirror marks a single forwarded HTTP request for mirroring when the
+// mirror settings select its action. Batches and notifications are not mirrored.
+func (i *Interceptor) selectMirror(ex *exchange, resolved observer.Action, eventID string, requestID interface{}, body []byte) {
+	if ex.http == nil || eventID == "" || requestID == nil || i.Core.Mirror == nil {
+		return
+	}
+	if !i.Core.Observer.GetMirror().Selects(resolved, ex.upstream) {
+		return
+	}
+	ex.mirror = &mirrorCall{eventID: eventID, requestID: requestID, method: resolved.Name, rpcMethod: resolved.Method, actor: resolved.Actor, body: body}
+}
+
+type mirrorKey struct{}
+
+// withMirror carries the request's mirror selection to Mirror.
+func withMirror(req *http.Request, call *mirrorCall) *http.Request {
+	if call == nil {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), mirrorKey{}, call))
+}
+
+// Mirror sends a copy of a forwarded request that the mirror settings selected
+// to the shadow server, in the background, and records the shadow response as a
+// shadow_response event linked to the call. Call it before forwarding req, so
+// the call is registered before the primary response can arrive.
+func (i *Interceptor) Mirror(req *http.Request) {
+	if req == nil || i.Core == nil || i.Core.Mirror == nil {
+		return
+	}
+	call, _ := req.Context().Value(mirrorKey{}).(*mirrorCall)
+	if call == nil {
+		return
+	}
+	settings := i.Core.Observer.GetMirror()
+	if !settings.Enabled() {
+		return
+	}
+	if !i.Core.Mirror.Begin(call.eventID) {
+		logging.Warn("mirror_skipped", logging.Fields{Component: "mirror", EventID: call.eventID, Error: "too many shadow calls in flight"})
+		return
+	}
+	header := make(http.Header, len(mirroredHeaders)+2)
+	for j := 0; j < len(mirroredHeaders); j++ {
+		if v := req.Header.Get(mirroredHeaders[j]); v != "" {
+			header.Set(mirroredHeaders[j], v)
+		}
+	}
+	header.Set(headerEventID, call.eventID)
+	header.Set(headerMirror, "1")
+	ex := requestExchange(req)
+	path := req.URL.Path
+
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				logging.Critical("mirror_panic", logging.Fields{Component: "mirror", Error: fmt.Sprint(rec)})
+			}
+		}()
+		i.runMirror(settings, call, header, path, ex)
+	}()
+}
+
+// shadowReply is what came back from the shadow server.
+type shadowReply struct {
+	msg     *mcp.MCPMessage
+	status  int
+	latency time.Duration
+	err     error
+}
+
+// runMirror sends the shadow request, waits for the primary response and
+// records the comparison.
+func (i *Interceptor) runMirror(settings observer.Mirror, call *mirrorCall, header http.Header, path string, ex *exchange) {
+	reply := i.sendShadow(settings, call, header, path)
+	shadowDigest := ""
+	if reply.err == nil {
+		shadowDigest = mirror.Digest(reply.msg.Result, reply.msg.Error)
+	}
+	primaryDigest, primaryOK := i.Core.Mirror.Await(call.eventID, settings.Timeout())
+	outcome := mirror.Compare(primaryDigest, shadowDigest, primaryOK)
+
+	fields := logging.Fields{Component: "mirror", EventID: call.eventID, Method: call.method}
+	if reply.err != nil {
+		fields.Error = reply.err.Error()
+	}
+	logging.Info("mirror_"+outcome, fields)
+	i.submitShadowEvent(settings.Target, call, reply, outcome, primaryDigest, shadowDigest, ex)
+	i.Core.Mirror.Record(outcome)
+}
+
+// sendShadow posts the forwarded body to the shadow server and decodes the
+// JSON-RPC response to the call, from a JSON or text/event-stream body.
+func (i *Interceptor) sendShadow(settings observer.Mirror, call *mirrorCall, header http.Header, path string) shadowReply {
+	target, err := url.Parse(settings.Target)
+	if err != nil {
+		return shadowReply{err: err}
+	}
+	if target.Path == "" || target.Path == "/" {
+		target.Path = path
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), settings.Timeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(call.body))
+	if err != nil {
+		return shadowReply{err: err}
+	}
+	req.Header = header
+
+	started := time.Now()
+	resp, err := i.shadow.Do(req)
+	if err != nil {
+		return shadowReply{err: err, latency: time.Since(started)}
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logging.Warn("mirror_body_close_failed", logging.Fields{Component: "mirror", Error: err.Error()})
+		}
+	}()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageBytes+1))
+	reply := shadowReply{status: resp.StatusCode, latency: time.Since(started)}
+	switch {
+	case err != nil:
+		reply.err = fmt.Errorf("reading shadow response: %w", err)
+	case len(body) > maxMessageBytes:
+		reply.err = fmt.Errorf("shadow response exceeds %d bytes", maxMessageBytes)
+	default:
+		reply.msg, reply.err = shadowMessage(body, isEventStream(resp.Header.Get("Content-Type")), call.requestID)
+	}
+	if reply.err != nil && resp.StatusCode >= http.StatusBadRequest {
+		reply.err = fmt.Errorf("shadow returned HTTP %d: %w", resp.StatusCode, reply.err)
+	}
+	return reply
+}
+
+// shadowMessage finds the response to requestID in a shadow response body.
+func shadowMessage(body []byte, eventStream bool, requestID interface{}) (*mcp.MCPMessage, error) {
+	payloads := [][]byte{body}
+	if eventStream {
+		var parser sseParser
+		payloads = nil
+		for start := 0; start < len(body); start += maxSSEChunkBytes {
+			end := start + maxSSEChunkBytes
+			if end > len(body) {
+				end = len(body)
+			}
+			payloads = append(payloads, parser.feed(body[start:end])...)
+		}
+		payloads = append(payloads, parser.feed([]byte("\n\n"))...)
+	}
+	want := rpcIDKey(requestID)
+	for j := 0; j < len(payloads); j++ {
+		var msg mcp.MCPMessage
+		if err := json.Unmarshal(payloads[j], &msg); err != nil {
+			continue
+		}
+		if msg.Method == "" && rpcIDKey(msg.ID) == want {
+			return &msg, nil
+		}
+	}
+	return nil, fmt.Errorf("no JSON-RPC response with id %s", want)
+}
+
+// submitShadowEvent records a shadow response. Its ParentID is the mirrored
+// tool_call; params carry the outcome and both digests, so divergence can be
+// reported from the ledger alone. The response goes through the same
+// response-side rules as the primary's, so it is tagged and redacted alike.
+func (i *Interceptor) submitShadowEvent(target string, call *mirrorCall, reply shadowReply, outcome, primaryDigest, shadowDigest string, ex *exchange) {
+	if err := assert.Check(i.Core.Worker != nil, "worker must be initialized"); err != nil {
+		return
+	}
+	if !i.Core.Worker.IsHealthy() {
+		return
+	}
+	params := map[string]interface{}{
+		"target":  target,
+		"outcome": outcome,
+	}
+	if primaryDigest != "" {
+		params["primary_sha256"] = primaryDigest
+	}
+	if shadowDigest != "" {
+		params["shadow_sha256"] = shadowDigest
+	}
+	if reply.err != nil {
+		params["error"] = reply.err.Error()
+	}
+
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.Actor = "system"
+	event.EventType = "shadow_response"
+	event.Method = call.method
+	event.Params = params
+	event.ParentID = call.eventID
+	event.LatencyMs = reply.latency.Milliseconds()
+	event.HTTPStatus = reply.status
+	event.Upstream = ex.upstream
+	event.TraceID, event.SpanID = ex.trace.traceID, ex.trace.spanID
+	if reply.msg != nil {
+		isError := reply.msg.Error != nil
+		if isError {
+			event.Response = reply.msg.Error
+			params["shadow_error"] = true
+		} else {
+			event.Response = reply.msg.Result
+		}
+		// Before blob references replace large values, as for tool_response.
+		i.tagResponseEvent(event, inflightCall{method: call.rpcMethod, action: call.method, actor: call.actor}, isError)
+		if !isError {
+			event.BlobRefs = i.storeResultBlobs(reply.msg.Result)
+		}
+	}
+	i.Core.Worker.Submit(event)
+}
@@ -0,0 +1,537 @@
+package interceptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/blob"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/mcp"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+// Values of Event.BodyStorage. Empty means the params are stored complete.
+const (
+	bodyTruncated = "truncated" // Params truncated to the inline limit
+	bodyOffloaded = "offloaded" // Params truncated (or omitted); full body in the blob store under BodySHA256
+	bodyOmitted   = "omitted"   // Body too large to parse; only its envelope is recorded
+)
+
+const (
+	maxTruncatedValueBytes = 1024
+	maxTruncatedItems      = 64
+	maxTruncateDepth       = 32
+	maxWalkContainers      = 1 << 16 // Maps and arrays visited by one walkContainers
+	maxEnvelopePrefix      = 64 * 1024
+	maxEnvelopeMembers     = 64
+	truncationMarker       = "...[truncated]"
+
+	// unparsedMethod stands in for the method of a body whose envelope could not be read.
+	unparsedMethod = "logryph:unparsed"
+)
+
+// requestBody is what the ledger keeps of a request body: the size and SHA-256 of
+// the complete body, how it was stored, and the params recorded on the event.
+type requestBody struct {
+	size    int64
+	sha256  string
+	storage string
+	params  map[string]interface{}
+}
+
+// apply copies the body record onto an event.
+func (b *requestBody) apply(event *models.Event) {
+	if b == nil {
+		return
+	}
+	event.Params = b.params
+	event.BodySize = b.size
+	event.BodySHA256 = b.sha256
+	event.BodyStorage = b.storage
+}
+
+func (i *Interceptor) payloadSettings() observer.Payloads {
+	if i.Core == nil || i.Core.Observer == nil {
+		return observer.Payloads{}
+	}
+	return i.Core.Observer.GetPayloads()
+}
+
+// prepareBody hashes a parsed request body and decides what of its params is
+// stored. Bodies over payloads.max_inline_bytes keep a truncated copy of their
+// params and, with oversize "offload", are put whole in the blob store, unless
+// rule (the rule the request matched, if any) redacts keys: the blob would keep
+// them verbatim, so such bodies are only truncated.
+func (i *Interceptor) prepareBody(raw []byte, params map[string]interface{}, rule *observer.Rule) *requestBody {
+	sum := sha256.Sum256(raw)
+	body := &requestBody{size: int64(len(raw)), sha256: hex.EncodeToString(sum[:]), params: params}
+	settings := i.payloadSettings()
+	if len(raw) <= settings.InlineLimit() {
+		return body
+	}
+
+	body.params = truncateParams(params, settings.InlineLimit())
+	body.storage = bodyTruncated
+	if settings.Offload() && (rule == nil || len(rule.Redact) == 0) && i.offloadBody(body.sha256, raw) {
+		body.storage = bodyOffloaded
+	}
+	return body
+}
+
+// truncateParams returns a copy of params small enough to store inline: strings
+// are cut to maxTruncatedValueBytes, objects and arrays to maxTruncatedItems
+// members and nesting to maxTruncateDepth. If that is still over limit only
+// top-level scalars are kept, and if those are too, only a marker: the event's
+// body size and SHA-256 still identify the complete body.
+func truncateParams(params map[string]interface{}, limit int) map[string]interface{} {
+	cut, _ := truncateValue(params).(map[string]interface{})
+	if encoded, err := json.Marshal(cut); err == nil && len(encoded) <= limit {
+		return cut
+	}
+	scalars := make(map[string]interface{}, len(cut))
+	n := 0
+	for k, v := range cut {
+		if n >= maxTruncatedItems+1 {
+			break
+		}
+		n++
+		if !isContainer(v) {
+			scalars[k] = v
+		}
+	}
+	if encoded, err := json.Marshal(scalars); err == nil && len(encoded) <= limit {
+		return scalars
+	}
+	return map[string]interface{}{truncationMarker: "params omitted"}
+}
+
+// walkItem is a map or array queued by walkContainers: the container, the copy
+// being built from it (if any), and its nesting depth.
+type walkItem struct {
+	src, dst interface{}
+	depth    int
+}
+
+// walkContainers visits the maps and arrays of a decoded JSON value breadth
+// first, from a work queue instead of recursion. visit handles one container
+// and pushes the nested containers to visit. At most maxWalkContainers are
+// visited; reports whether the walk completed.
+func walkContainers(root walkItem, visit func(item walkItem, push func(walkItem))) bool {
+	queue := []walkItem{root}
+	push := func(item walkItem) { queue = append(queue, item) }
+	for n := 0; n < maxWalkContainers; n++ {
+		if len(queue) == 0 {
+			return true
+		}
+		item := queue[0]
+		queue = queue[1:]
+		visit(item, push)
+	}
+	return len(queue) == 0
+}
+
+// isContainer reports whether v is a decoded JSON object or array.
+func isContainer(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+// truncateValue returns a copy of v with strings cut to maxTruncatedValueBytes,
+// objects and arrays to maxTruncatedItems members and nesting to
+// maxTruncateDepth. Dropped object members are counted under a truncationMarker
+// key. Containers past maxWalkContainers are left empty.
+func truncateValue(v interface{}) interface{} {
+	out, nested := truncateShallow(v, 0)
+	if !nested {
+		return out
+	}
+	complete := walkContainers(walkItem{src: v, dst: out}, func(item walkItem, push func(walkItem)) {
+		switch src := item.src.(type) {
+		case map[string]interface{}:
+			dst := item.dst.(map[string]interface{})
+			kept := 0
+			for k, x := range src {
+				if kept >= maxTruncatedItems {
+					break
+				}
+				kept++
+				cut, nested := truncateShallow(x, item.depth+1)
+				dst[k] = cut
+				if nested {
+					push(walkItem{src: x, dst: cut, depth: item.depth + 1})
+				}
+			}
+			if len(src) > kept {
+				dst[truncationMarker] = fmt.Sprintf("%d more keys", len(src)-kept)
+			}
+		case []interface{}:
+			dst := item.dst.([]interface{})
+			for j := 0; j < maxTruncatedItems; j++ {
+				if j >= len(src) {
+					break
+				}
+				cut, nested := truncateShallow(src[j], item.depth+1)
+				dst[j] = cut
+				if nested {
+					push(walkItem{src: src[j], dst: cut, depth: item.depth + 1})
+				}
+			}
+		}
+	})
+	if !complete {
+		logging.Warn("payload_walk_incomplete", logging.Fields{Component: "interceptor", Error: fmt.Sprintf("truncated params exceed %d containers", maxWalkContainers)})
+	}
+	return out
+}
+
+// truncateShallow returns the truncated form of v at the given depth: a cut
+// string, a truncation marker for containers nested too deep, or an empty copy
+// of a container to be filled, in which case nested is true.
+func truncateShallow(v interface{}, depth int) (cut interface{}, nested bool) {
+	switch val := v.(type) {
+	case string:
+		if len(val) <= maxTruncatedValueBytes {
+			return val, false
+		}
+		return strings.ToValidUTF8(val[:maxTruncatedValueBytes], "") + truncationMarker, false
+	case map[string]interface{}:
+		if depth >= maxTruncateDepth {
+			return truncationMarker, false
+		}
+		return make(map[string]interface{}, min(len(val), maxTruncatedItems+1)), true
+	case []interface{}:
+		if depth >= maxTruncateDepth {
+			return truncationMarker, false
+		}
+		n := min(len(val), maxTruncatedItems)
+		out := make([]interface{}, n, n+1)
+		if len(val) > n {
+			out = append(out, fmt.Sprintf("%s %d more items", truncationMarker, len(val)-n))
+		}
+		return out, true
+	}
+	return v, false
+}
+
+func (i *Interceptor) blobs() *blob.Store {
+	if i.Core == nil {
+		return nil
+	}
+	return i.Core.Blobs
+}
+
+// offloadBody puts a complete body in the blob store. Reports success.
+func (i *Interceptor) offloadBody(digest string, raw []byte) bool {
+	store := i.blobs()
+	if store == nil {
+		return false
+	}
+	stored, err := store.Put(raw)
+	if err != nil {
+		logging.Error("body_offload_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
+		return false
+	}
+	return assert.Check(stored == digest, "offloaded body digest mismatch: %s != %s", stored, digest) == nil
+}
+
+// storeResultBlobs moves string values of a response result of at least
+// payloads.blob_min_bytes into the blob store, replacing each in place with a
+// blob reference, and returns the sorted digests stored for the event's
+// BlobRefs. Values under "data" or "blob" (MCP image, audio and embedded
+// resource content) are stored base64-decoded, so the blob is the file itself.
+// Does nothing unless blob_min_bytes is set.
+func (i *Interceptor) storeResultBlobs(result map[string]interface{}) []string {
+	store := i.blobs()
+	threshold := i.payloadSettings().BlobThreshold()
+	if store == nil || threshold == 0 || result == nil {
+		return nil
+	}
+	refs := make(map[string]bool)
+	moveBlobs(store, result, threshold, refs)
+	if len(refs) == 0 {
+		return nil
+	}
+	digests := make([]string, 0, len(refs))
+	for digest := range refs {
+		digests = append(digests, digest)
+	}
+	sort.Strings(digests)
+	return digests
+}
+
+// moveBlobs replaces large strings in v with blob references, walking at most
+// maxTruncateDepth levels deep with walkContainers.
+func moveBlobs(store *blob.Store, v interface{}, threshold int, refs map[string]bool) {
+	complete := walkContainers(walkItem{src: v}, func(item walkItem, push func(walkItem)) {
+		if item.depth >= maxTruncateDepth {
+			return
+		}
+		switch val := item.src.(type) {
+		case map[string]interface{}:
+			for k, x := range val {
+				if s, ok := x.(string); ok && len(s) >= threshold {
+					if ref := putBlob(store, k, s, refs); ref != nil {
+						val[k] = ref
+					}
+				} else if isContainer(x) {
+					push(walkItem{src: x, depth: item.depth + 1})
+				}
+			}
+		case []interface{}:
+			for j := 0; j < len(val); j++ {
+				if s, ok := val[j].(string); ok && len(s) >= threshold {
+					if ref := putBlob(store, "", s, refs); ref != nil {
+						val[j] = ref
+					}
+				} else if isContainer(val[j]) {
+					push(walkItem{src: val[j], depth: item.depth + 1})
+				}
+			}
+		}
+	})
+	if !complete {
+		logging.Warn("payload_walk_incomplete", logging.Fields{Component: "interceptor", Error: fmt.Sprintf("blob extraction stopped after %d containers", maxWalkContainers)})
+	}
+}
+
+// putBlob stores a value, adds its digest to refs and returns its reference, or
+// nil if it could not be stored.
+func putBlob(store *blob.Store, key, value string, refs map[string]bool) map[string]interface{} {
+	data, encoding := []byte(value), ""
+	if key == "data" || key == "blob" {
+		if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+			data, encoding = decoded, blob.EncodingBase64
+		}
+	}
+	digest, err := store.Put(data)
+	if err != nil {
+		logging.Error("blob_store_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
+		return nil
+	}
+	refs[digest] = true
+	return blob.NewRef(digest, len(data), encoding)
+}
+
+// unparsedBody accumulates a request body too large to parse (over maxMessageBytes)
+// as it goes by: its size and SHA-256, the prefix its envelope is sniffed from,
+// and, when offloading is configured, a copy streamed into the blob store.
+type unparsedBody struct {
+	hash    hash.Hash
+	size    int64
+	prefix  []byte
+	offload *blob.Writer
+}
+
+// newUnparsedBody starts an unparsedBody. It is not offloaded while request
+// rules with redact keys are loaded, since no rule can be matched to scrub it.
+func (i *Interceptor) newUnparsedBody() *unparsedBody {
+	body := &unparsedBody{hash: sha256.New()}
+	redacts := i.Core != nil && i.Core.Observer != nil && i.Core.Observer.Redacts()
+	if store := i.blobs(); store != nil && i.payloadSettings().Offload() && !redacts {
+		file, err := store.Create()
+		if err != nil {
+			logging.Error("body_offload_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
+		} else {
+			body.offload = file
+		}
+	}
+	return body
+}
+
+// Write never fails: an offload error only drops the offloaded copy.
+func (u *unparsedBody) Write(p []byte) (int, error) {
+	u.hash.Write(p)
+	u.size += int64(len(p))
+	if room := maxEnvelopePrefix - len(u.prefix); room > 0 {
+		u.prefix = append(u.prefix, p[:min(room, len(p))]...)
+	}
+	if u.offload != nil {
+		if _, err := u.offload.Write(p); err != nil {
+			logging.Error("body_offload_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
+			u.offload.Abort()
+			u.offload = nil
+		}
+	}
+	return len(p), nil
+}
+
+// finish returns the body record: no params, and the offloaded copy committed.
+func (u *unparsedBody) finish() *requestBody {
+	body := &requestBody{size: u.size, sha256: hex.EncodeToString(u.hash.Sum(nil)), storage: bodyOmitted}
+	if u.offload != nil {
+		if digest, err := u.offload.Commit(); err != nil {
+			logging.Error("body_offload_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
+		} else if assert.Check(digest == body.sha256, "offloaded body digest mismatch: %s != %s", digest, body.sha256) == nil {
+			body.storage = bodyOffloaded
+		}
+		u.offload = nil
+	}
+	return body
+}
+
+// submitUnparsedRequest records a request that was not parsed (too large, or
+// not valid JSON-RPC) from its envelope alone: method and ID are sniffed from
+// the body's prefix, policies are not evaluated, and the event carries the
+// complete body's size and SHA-256. reason says why it was not parsed.
+func (i *Interceptor) submitUnparsedRequest(u *unparsedBody, ex *exchange, reason string) {
+	if err := assert.NotNil(u, "unparsed body"); err != nil {
+		return
+	}
+	env := sniffEnvelope(u.prefix)
+	if env.Method == "" {
+		env.Method = unparsedMethod
+	}
+	body := u.finish()
+	logging.Warn("request_body_unparsed", logging.Fields{Component: "interceptor", Method: env.Method, Error: reason})
+	i.submitToolCallEvent(ex, "", "", env.Method, &env, nil, body)
+}
+
+// submitUnparsedBody records a buffered body with submitUnparsedRequest.
+func (i *Interceptor) submitUnparsedBody(body []byte, ex *exchange, reason string) {
+	unparsed := i.newUnparsedBody()
+	_, _ = unparsed.Write(body)
+	i.submitUnparsedRequest(unparsed, ex, reason)
+}
+
+// requestTap forwards an HTTP request body too large to parse without buffering
+// it: the head already read is replayed, then the rest of the agent's body is
+// streamed, every byte feeding an unparsedBody on the way. The request is
+// recorded with submitUnparsedRequest once the upstream has read the body to its
+// end, or closed it early. Its event is then too late for X-Logryph-Event-Id on
+// the forwarded request, but is still receipted on the response.
+type requestTap struct {
+	r           io.Reader
+	src         io.Closer
+	interceptor *Interceptor
+	exchange    *exchange
+
+	mu       sync.Mutex
+	body     *unparsedBody
+	recorded []recordedEvent
+}
+
+func (i *Interceptor) newRequestTap(head []byte, src io.ReadCloser, u *unparsedBody, ex *exchange) *requestTap {
+	return &requestTap{r: io.MultiReader(bytes.NewReader(head), src), src: src, interceptor: i, exchange: ex, body: u}
+}
+
+func (t *requestTap) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.mu.Lock()
+	if t.body != nil {
+		_, _ = t.body.Write(p[:n])
+	}
+	t.mu.Unlock()
+	if err == io.EOF {
+		t.finish("")
+	}
+	return n, err
+}
+
+func (t *requestTap) Close() error {
+	t.finish("closed before its end")
+	return t.src.Close()
+}
+
+// finish records the request once. cut says why the body was not read whole.
+func (t *requestTap) finish(cut string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.body == nil {
+		return
+	}
+	body := t.body
+	t.body = nil
+	reason := oversizeReason(body.size)
+	if cut != "" {
+		reason = fmt.Sprintf("body %s after %d bytes", cut, body.size)
+	}
+	if t.exchange.http != nil {
+		t.exchange.http.ContentLength = body.size
+	}
+	t.interceptor.submitUnparsedRequest(body, t.exchange, reason)
+	t.recorded = t.exchange.recorded
+}
+
+// recordedEvents returns the events recorded for the request so far.
+func (t *requestTap) recordedEvents() []recordedEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.recorded
+}
+
+// oversizeReason is the reason recorded for a body over the parse limit.
+func oversizeReason(size int64) string {
+	return fmt.Sprintf("%d bytes exceed the %d byte parse limit", size, maxMessageBytes)
+}
+
+// codeBodyTooLarge is the JSON-RPC error code sent for request bodies too large
+// to be policy-checked while enforcing rules are loaded.
+const codeBodyTooLarge = codeInvalidRequest
+
+// enforces reports whether the loaded policy has deny or require_approval rules.
+func (i *Interceptor) enforces() bool {
+	return i.Core != nil && i.Core.Observer != nil && i.Core.Observer.Enforces()
+}
+
+// refuseUnparsedRequest stops a request too large to parse while enforcing rules
+// are loaded: its policies cannot be evaluated, so forwarding it would bypass
+// them. It is recorded as a blocked event from its envelope, like
+// submitUnparsedRequest. Returns the requestError carrying the JSON-RPC error
+// for the agent: none for notifications, a null ID when the envelope is unreadable.
+func (i *Interceptor) refuseUnparsedRequest(u *unparsedBody, ex *exchange) *requestError {
+	message := fmt.Sprintf("Request body exceeds %d bytes and cannot be checked against policy", maxMessageBytes)
+	if err := assert.NotNil(u, "unparsed body"); err != nil {
+		return &requestError{status: http.StatusRequestEntityTooLarge, code: codeBodyTooLarge, message: message, blocked: true}
+	}
+	reqErr := i.refuse(sniffEnvelope(u.prefix), u.finish(), ex, codeBodyTooLarge, message)
+	reqErr.status = http.StatusRequestEntityTooLarge
+	return reqErr
+}
+
+// sniffEnvelope reads the jsonrpc, id and method members of a JSON-RPC object
+// without keeping the rest, stopping at the first value it cannot read (such as
+// the end of a truncated prefix). Batches yield an empty envelope.
+func sniffEnvelope(prefix []byte) mcp.MCPRequest {
+	var env mcp.MCPRequest
+	dec := json.NewDecoder(bytes.NewReader(prefix))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return env
+	}
+	for n := 0; n < maxEnvelopeMembers && dec.More(); n++ {
+		tok, err := dec.Token()
+		key, ok := tok.(string)
+		if err != nil || !ok {
+			return env
+		}
+		switch key {
+		case "jsonrpc":
+			err = dec.Decode(&env.JSONRPC)
+		case "id":
+			err = dec.Decode(&env.ID)
+		case "method":
+			err = dec.Decode(&env.Method)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return env
+		}
+	}
+	return env
+}
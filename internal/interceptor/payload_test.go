@@ -0,0 +1,341 @@
+package interceptor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/blob"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+func requestEvents(events []models.Event) []models.Event {
+	var calls []models.Event
+	for _, e := range events {
+		if e.EventType == "tool_call" || e.EventType == "notification" {
+			calls = append(calls, e)
+		}
+	}
+	return calls
+}
+
+func TestOversizeBodyIsTruncatedAndOffloaded(t *testing.T) {
+	i, drain := newTestInterceptor(t, `
+payloads:
+  max_inline_bytes: 4096
+  oversize: "offload"
+policies: []
+`)
+
+	content := strings.Repeat("x", 64*1024)
+	body := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"fs:write","arguments":{"path":"a.txt","content":%q}}}`, content)
+	forward := i.InterceptRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body)))
+	if forward == nil {
+		t.Fatal("expected oversized request to be forwarded")
+	}
+	sent, _ := io.ReadAll(forward.Body)
+	if string(sent) != body {
+		t.Fatal("expected the complete body to be forwarded")
+	}
+
+	calls := requestEvents(drain())
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 tool_call event, got %d", len(calls))
+	}
+	e := calls[0]
+	sum := sha256.Sum256([]byte(body))
+	digest := hex.EncodeToString(sum[:])
+	if e.BodySize != int64(len(body)) || e.BodySHA256 != digest || e.BodyStorage != bodyOffloaded {
+		t.Errorf("unexpected body record: size=%d sha=%s storage=%q", e.BodySize, e.BodySHA256, e.BodyStorage)
+	}
+	args, _ := e.Params["arguments"].(map[string]interface{})
+	stored, _ := args["content"].(string)
+	if e.Params["name"] != "fs:write" || args["path"] != "a.txt" || !strings.HasSuffix(stored, truncationMarker) || len(stored) > 2*maxTruncatedValueBytes {
+		t.Errorf("unexpected stored params: name=%v path=%v content=%d bytes", e.Params["name"], args["path"], len(stored))
+	}
+	path, _ := i.Core.Blobs.Path(digest)
+	offloaded, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(offloaded, []byte(body)) {
+		t.Errorf("expected the complete body offloaded under its digest: %v", err)
+	}
+}
+
+func TestRedactedBodyIsNotOffloaded(t *testing.T) {
+	i, drain := newTestInterceptor(t, `
+payloads:
+  max_inline_bytes: 4096
+  oversize: "offload"
+policies:
+  - id: "scrub-writes"
+    match_methods: ["fs:write"]
+    risk_level: "high"
+    redact: ["token"]
+`)
+
+	content := strings.Repeat("x", 64*1024)
+	body := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"fs:write","arguments":{"token":"s3cr3t-value","content":%q}}}`, content)
+	forward := i.InterceptRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body)))
+	if forward == nil {
+		t.Fatal("expected oversized request to be forwarded")
+	}
+	_, _ = io.ReadAll(forward.Body)
+
+	calls := requestEvents(drain())
+	if len(calls) != 1 || calls[0].BodyStorage != bodyTruncated {
+		t.Fatalf("expected 1 truncated tool_call event, got %d", len(calls))
+	}
+	err := filepath.WalkDir(i.Core.Blobs.Dir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err == nil && bytes.Contains(data, []byte("s3cr3t-value")) {
+			t.Errorf("redacted value reached the blob store in %s", path)
+		}
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func TestLargeResponseContentMovesToBlobStore(t *testing.T) {
+	i, drain := newTestInterceptor(t, `
+payloads:
+  blob_min_bytes: 1024
+policies: []
+`)
+	image := bytes.Repeat([]byte{0x89, 'P', 'N', 'G'}, 1024)
+	encoded := base64.StdEncoding.EncodeToString(image)
+	resp := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"image","mimeType":"image/png","data":%q},{"type":"text","text":"ok"}]}}`, encoded)
+	i.observeServerMessage([]byte(resp), &exchange{ctx: context.Background()})
+
+	var response *models.Event
+	events := drain()
+	for j := range events {
+		if events[j].EventType == "tool_response" {
+			response = &events[j]
+		}
+	}
+	if response == nil {
+		t.Fatal("expected a tool_response event")
+	}
+	content, _ := response.Response["content"].([]interface{})
+	if len(content) != 2 {
+		t.Fatalf("unexpected response content: %+v", response.Response)
+	}
+	item, _ := content[0].(map[string]interface{})
+	digest, ok := blob.RefDigest(item["data"])
+	if !ok {
+		t.Fatalf("expected image data replaced by a blob reference, got %T", item["data"])
+	}
+	if ref := item["data"].(map[string]interface{}); ref["encoding"] != blob.EncodingBase64 || item["mimeType"] != "image/png" {
+		t.Errorf("unexpected reference: %+v", item)
+	}
+	if text, _ := content[1].(map[string]interface{}); text["text"] != "ok" {
+		t.Errorf("expected small values kept inline, got %+v", content[1])
+	}
+	sum := sha256.Sum256(image)
+	if digest != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected the decoded image to be stored, got digest %s", digest)
+	}
+	if err := i.Core.Blobs.Verify(digest); err != nil {
+		t.Errorf("blob not stored intact: %v", err)
+	}
+	if len(response.BlobRefs) != 1 || response.BlobRefs[0] != digest {
+		t.Errorf("expected the stored digest in the event's blob refs, got %v", response.BlobRefs)
+	}
+}
+
+func TestResponseBlobsAreOptIn(t *testing.T) {
+	i, drain := newTestInterceptor(t, "policies: []\n")
+	large := strings.Repeat("x", 128*1024)
+	resp := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"text","text":%q}]}}`, large)
+	i.observeServerMessage([]byte(resp), &exchange{ctx: context.Background()})
+
+	for _, e := range drain() {
+		if e.EventType != "tool_response" {
+			continue
+		}
+		content, _ := e.Response["content"].([]interface{})
+		item, _ := content[0].(map[string]interface{})
+		if item["text"] != large || len(e.BlobRefs) != 0 {
+			t.Errorf("expected the response kept inline without blob_min_bytes, got refs %v", e.BlobRefs)
+		}
+		return
+	}
+	t.Fatal("expected a tool_response event")
+}
+
+func TestUpstreamBlobReferenceIsNotRecorded(t *testing.T) {
+	i, drain := newTestInterceptor(t, `
+payloads:
+  blob_min_bytes: 1024
+policies: []
+`)
+	forged := "sha256:" + strings.Repeat("ab", sha256.Size)
+	resp := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"image","data":{"logryph_blob":%q,"size":4}}]}}`, forged)
+	i.observeServerMessage([]byte(resp), &exchange{ctx: context.Background()})
+
+	for _, e := range drain() {
+		if e.EventType == "tool_response" {
+			if len(e.BlobRefs) != 0 {
+				t.Errorf("expected an upstream reference-shaped value not to be recorded as a blob, got %v", e.BlobRefs)
+			}
+			return
+		}
+	}
+	t.Fatal("expected a tool_response event")
+}
+
+func TestSmallBodyIsStoredWhole(t *testing.T) {
+	i, drain := newTestInterceptor(t, "policies: []\n")
+	body := toolCall(1, "fs:read_file")
+	if i.InterceptRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))) == nil {
+		t.Fatal("expected request to be forwarded")
+	}
+	calls := requestEvents(drain())
+	sum := sha256.Sum256([]byte(body))
+	if len(calls) != 1 || calls[0].BodyStorage != "" || calls[0].BodySHA256 != hex.EncodeToString(sum[:]) || calls[0].Params["name"] != "fs:read_file" {
+		t.Fatalf("unexpected events: %+v", calls)
+	}
+}
+
+func TestUnparsedBodyIsRecordedFromEnvelope(t *testing.T) {
+	i, drain := newTestInterceptor(t, "policies: []\n")
+
+	body := fmt.Sprintf(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"fs:write","arguments":{"content":%q}}}`,
+		strings.Repeat("y", maxMessageBytes))
+	forward := i.InterceptRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body)))
+	if forward == nil {
+		t.Fatal("expected unparsed request to be forwarded")
+	}
+	sent, _ := io.ReadAll(forward.Body)
+	if err := forward.Body.Close(); err != nil || string(sent) != body {
+		t.Fatalf("expected the complete body streamed upstream, got %d bytes: %v", len(sent), err)
+	}
+
+	calls := requestEvents(drain())
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 event for the unparsed body, got %d", len(calls))
+	}
+	sum := sha256.Sum256([]byte(body))
+	e := calls[0]
+	if e.Method != "tools/call" || e.BodyStorage != bodyOmitted || e.BodySize != int64(len(body)) || e.BodySHA256 != hex.EncodeToString(sum[:]) || len(e.Params) != 0 {
+		t.Errorf("unexpected event: method=%s storage=%q size=%d params=%d", e.Method, e.BodyStorage, e.BodySize, len(e.Params))
+	}
+}
+
+func TestUnparsedBodyIsRefusedUnderEnforcingRules(t *testing.T) {
+	i, drain := newTestInterceptor(t, denyPolicy)
+
+	body := fmt.Sprintf(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"fs:delete_file","arguments":{"pad":%q}}}`,
+		strings.Repeat("y", maxMessageBytes))
+	w := httptest.NewRecorder()
+	if i.InterceptRequest(w, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))) != nil {
+		t.Fatal("expected oversized request to be refused while deny rules are loaded")
+	}
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"id":7`) || !strings.Contains(w.Body.String(), fmt.Sprint(codeBodyTooLarge)) {
+		t.Errorf("unexpected reply: %s", w.Body.String())
+	}
+
+	events := drain()
+	var blocked []models.Event
+	for _, e := range events {
+		if e.EventType == "blocked" {
+			blocked = append(blocked, e)
+		}
+	}
+	if len(blocked) != 1 || len(requestEvents(events)) != 0 {
+		t.Fatalf("expected only a blocked event, got %+v", events)
+	}
+	sum := sha256.Sum256([]byte(body))
+	if e := blocked[0]; !e.WasBlocked || e.Method != "tools/call" || e.BodySHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected blocked event: %+v", e)
+	}
+}
+
+func TestSniffEnvelopeStopsAtTruncation(t *testing.T) {
+	env := sniffEnvelope([]byte(`{"jsonrpc":"2.0","method":"tools/call","id":"a","params":{"name":"x","arguments":{"data":"abc`))
+	if env.Method != "tools/call" || env.ID != "a" || env.JSONRPC != "2.0" {
+		t.Errorf("unexpected envelope: %+v", env)
+	}
+	if env := sniffEnvelope([]byte(`[{"method":"x"}]`)); env.Method != "" {
+		t.Errorf("expected empty envelope for a batch, got %+v", env)
+	}
+}
+
+func TestTruncateValueBoundsDepthItemsAndStrings(t *testing.T) {
+	items := make([]interface{}, maxTruncatedItems+10)
+	for j := range items {
+		items[j] = strings.Repeat("y", maxTruncatedValueBytes+1)
+	}
+	var deep interface{} = "leaf"
+	for j := 0; j < maxTruncateDepth+5; j++ {
+		deep = map[string]interface{}{"n": deep}
+	}
+	params := map[string]interface{}{"items": items, "deep": deep}
+
+	cut, _ := truncateValue(params).(map[string]interface{})
+	got, _ := cut["items"].([]interface{})
+	if len(got) != maxTruncatedItems+1 || got[maxTruncatedItems] != truncationMarker+" 10 more items" {
+		t.Fatalf("expected %d items plus a marker, got %d", maxTruncatedItems, len(got))
+	}
+	if s, _ := got[0].(string); !strings.HasSuffix(s, truncationMarker) || len(s) != maxTruncatedValueBytes+len(truncationMarker) {
+		t.Errorf("expected strings cut to %d bytes, got %d", maxTruncatedValueBytes, len(s))
+	}
+
+	level := cut["deep"]
+	depth := 1
+	for ; depth < 2*maxTruncateDepth; depth++ {
+		m, ok := level.(map[string]interface{})
+		if !ok {
+			break
+		}
+		level = m["n"]
+	}
+	if level != truncationMarker || depth != maxTruncateDepth {
+		t.Errorf("expected nesting cut at depth %d, got %v at %d", maxTruncateDepth, level, depth)
+	}
+	if len(items[0].(string)) != maxTruncatedValueBytes+1 {
+		t.Error("expected the original params left untouched")
+	}
+}
+
+func TestTruncateParamsBoundsObjectKeys(t *testing.T) {
+	args := make(map[string]interface{}, 20000)
+	params := map[string]interface{}{"name": "fs:write", "arguments": args}
+	for j := 0; j < 20000; j++ {
+		args[fmt.Sprintf("k%05d", j)] = "v"
+		params[fmt.Sprintf("p%05d", j)] = j
+	}
+
+	cut := truncateParams(params, 4096)
+	encoded, _ := json.Marshal(cut)
+	if len(encoded) > 4096 {
+		t.Fatalf("expected truncated params within the limit, got %d bytes", len(encoded))
+	}
+	if cut[truncationMarker] == nil {
+		t.Errorf("expected a truncation marker, got %v", cut)
+	}
+
+	kept, _ := truncateValue(args).(map[string]interface{})
+	if len(kept) != maxTruncatedItems+1 || kept[truncationMarker] != fmt.Sprintf("%d more keys", 20000-maxTruncatedItems) {
+		t.Errorf("expected %d keys plus a marker, got %d keys, marker %v", maxTruncatedItems, len(kept), kept[truncationMarker])
+	}
+}
@@ -0,0 +1,122 @@
+package interceptor
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// Response headers that hand the agent a receipt for each event its request
+// produced, one value per event in the order the events were recorded. Seq is
+// "pending" while the event is still queued; the receipt is then a signed
+// promise rather than a commitment (see ledger.Receipt). The event IDs are also
+// sent upstream, on the forwarded request.
+const (
+	headerEventID = "X-Logryph-Event-Id"
+	headerSeq     = "X-Logryph-Seq"
+	headerReceipt = "X-Logryph-Receipt"
+	seqPending    = "pending"
+
+	maxReceiptsPerResponse = 64
+)
+
+// recordedEvent is an event recorded for an agent request, kept until the
+// response so a receipt can be issued for it.
+type recordedEvent struct {
+	id         string
+	bodySHA256 string
+}
+
+// record notes an event recorded for the exchange's request.
+func (e *exchange) record(eventID string, stored *requestBody) {
+	if e == nil || len(e.recorded) >= maxReceiptsPerResponse {
+		return
+	}
+	r := recordedEvent{id: eventID}
+	if stored != nil {
+		r.bodySHA256 = stored.sha256
+	}
+	e.recorded = append(e.recorded, r)
+}
+
+// submitRecorded queues an event recorded for the exchange's request and notes
+// it for a receipt only if the worker accepted it: an event dropped on
+// backpressure or shutdown will never exist, so it must not be promised.
+func (i *Interceptor) submitRecorded(ex *exchange, event *models.Event, stored *requestBody) {
+	eventID := event.ID // The event returns to the pool once processed
+	if i.Core.Worker.Submit(event) {
+		ex.record(eventID, stored)
+		return
+	}
+	logging.Warn("receipt_withheld", logging.Fields{Component: "interceptor", EventID: eventID, Error: "event not queued"})
+}
+
+type recordedKey struct{}
+
+// withRecorded names the request's recorded events to the upstream in
+// X-Logryph-Event-Id and carries them to InterceptResponse.
+func withRecorded(req *http.Request, recorded []recordedEvent) *http.Request {
+	if len(recorded) == 0 {
+		return req
+	}
+	for j := 0; j < len(recorded); j++ {
+		req.Header.Add(headerEventID, recorded[j].id)
+	}
+	return req.WithContext(context.WithValue(req.Context(), recordedKey{}, recorded))
+}
+
+type tapKey struct{}
+
+// withTap carries a streamed request body to InterceptResponse, which receipts
+// the event recorded once the body was read.
+func withTap(req *http.Request, tap *requestTap) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), tapKey{}, tap))
+}
+
+func recordedFrom(req *http.Request) []recordedEvent {
+	if req == nil {
+		return nil
+	}
+	if tap, ok := req.Context().Value(tapKey{}).(*requestTap); ok {
+		return tap.recordedEvents()
+	}
+	recorded, _ := req.Context().Value(recordedKey{}).([]recordedEvent)
+	return recorded
+}
+
+// writeReceipts sets the receipt headers for recorded events on h, first
+// removing any the upstream sent so the agent never sees forged receipts. A
+// receipt that cannot be issued only drops its headers; the response is still served.
+func (i *Interceptor) writeReceipts(h http.Header, recorded []recordedEvent) {
+	h.Del(headerEventID)
+	h.Del(headerSeq)
+	h.Del(headerReceipt)
+	if len(recorded) == 0 || i.Core == nil || i.Core.Worker == nil {
+		return
+	}
+	for j := 0; j < maxReceiptsPerResponse; j++ {
+		if j >= len(recorded) {
+			break
+		}
+		receipt, err := i.Core.Worker.IssueReceipt(recorded[j].id, recorded[j].bodySHA256)
+		if err != nil {
+			logging.Warn("receipt_issue_failed", logging.Fields{Component: "interceptor", EventID: recorded[j].id, Error: err.Error()})
+			continue
+		}
+		encoded, err := receipt.Encode()
+		if err != nil {
+			logging.Warn("receipt_issue_failed", logging.Fields{Component: "interceptor", EventID: recorded[j].id, Error: err.Error()})
+			continue
+		}
+		seq := seqPending
+		if receipt.EventHash != "" {
+			seq = strconv.FormatUint(receipt.Seq, 10)
+		}
+		h.Add(headerEventID, receipt.EventID)
+		h.Add(headerSeq, seq)
+		h.Add(headerReceipt, encoded)
+	}
+}
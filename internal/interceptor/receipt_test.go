@@ -0,0 +1,167 @@
+package interceptor
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/core"
+	"github.com/slyt3/Logryph/internal/ledger"
+	"github.com/slyt3/Logryph/internal/ledger/store"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+func TestResponseCarriesReceipts(t *testing.T) {
+	i, drain := newTestInterceptor(t, denyPolicy)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewBufferString(toolCall(1, "fs:read_file")))
+	forward := i.InterceptRequest(w, req)
+	if forward == nil {
+		t.Fatal("expected request to be forwarded")
+	}
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"result":{}}`)),
+		Request:    forward,
+	}
+	if err := i.InterceptResponse(resp); err != nil {
+		t.Fatalf("intercept response: %v", err)
+	}
+	ids, seqs, receipts := resp.Header.Values(headerEventID), resp.Header.Values(headerSeq), resp.Header.Values(headerReceipt)
+	if len(ids) != 1 || len(seqs) != 1 || len(receipts) != 1 {
+		t.Fatalf("expected one receipt, got ids=%v seqs=%v receipts=%d", ids, seqs, len(receipts))
+	}
+	receipt, err := ledger.DecodeReceipt(receipts[0])
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if err := receipt.VerifySignature(); err != nil {
+		t.Fatalf("signature: %v", err)
+	}
+	if receipt.EventID != ids[0] || receipt.BodySHA256 == "" {
+		t.Errorf("unexpected receipt %+v for event %s", receipt, ids[0])
+	}
+	if receipt.Kind == ledger.ReceiptPromise && seqs[0] != seqPending {
+		t.Errorf("expected a promise to report seq %q, got %q", seqPending, seqs[0])
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewBufferString(toolCall(2, "fs:delete_file")))
+	if i.InterceptRequest(w, req) != nil {
+		t.Fatal("expected denied request not to be forwarded")
+	}
+	blockedID := w.Header().Get(headerEventID)
+	if blockedID == "" || w.Header().Get(headerReceipt) == "" {
+		t.Fatal("expected the denial to carry a receipt")
+	}
+
+	events := drain()
+	found := 0
+	for _, e := range events {
+		if e.ID == ids[0] || e.ID == blockedID {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("expected both receipted events in the ledger, found %d", found)
+	}
+}
+
+func TestUpstreamReceiptHeadersAreReplaced(t *testing.T) {
+	i, _ := newTestInterceptor(t, "policies: []\n")
+	forged := http.Header{
+		"Content-Type": []string{"application/json"},
+		headerEventID:  []string{"forged"},
+		headerSeq:      []string{"1"},
+		headerReceipt:  []string{"forged-receipt"},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewBufferString(toolCall(1, "fs:read_file")))
+	forward := i.InterceptRequest(httptest.NewRecorder(), req)
+	if forward == nil {
+		t.Fatal("expected request to be forwarded")
+	}
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     forged.Clone(),
+		Body:       io.NopCloser(bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"result":{}}`)),
+		Request:    forward,
+	}
+	if err := i.InterceptResponse(resp); err != nil {
+		t.Fatalf("intercept response: %v", err)
+	}
+	ids, receipts := resp.Header.Values(headerEventID), resp.Header.Values(headerReceipt)
+	if len(ids) != 1 || ids[0] == "forged" || len(receipts) != 1 || len(resp.Header.Values(headerSeq)) != 1 {
+		t.Fatalf("expected only Logryph's receipt, got ids=%v receipts=%d", ids, len(receipts))
+	}
+	if receipt, err := ledger.DecodeReceipt(receipts[0]); err != nil || receipt.EventID != ids[0] {
+		t.Errorf("unexpected receipt for %s: %v", ids[0], err)
+	}
+
+	// Responses to requests that recorded nothing carry no receipt at all.
+	get := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	resp = &http.Response{StatusCode: http.StatusOK, Header: forged.Clone(), Request: i.InterceptRequest(httptest.NewRecorder(), get)}
+	if err := i.InterceptResponse(resp); err != nil {
+		t.Fatalf("intercept response: %v", err)
+	}
+	if resp.Header.Get(headerEventID) != "" || resp.Header.Get(headerSeq) != "" || resp.Header.Get(headerReceipt) != "" {
+		t.Errorf("expected forged receipt headers removed, got %v", resp.Header)
+	}
+}
+
+func TestDroppedEventGetsNoReceipt(t *testing.T) {
+	dir := t.TempDir()
+	policyPath := filepath.Join(dir, "policy.yaml")
+	if err := os.WriteFile(policyPath, []byte("policies: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	obs, err := observer.NewObserverEngine(policyPath)
+	if err != nil {
+		t.Fatalf("observer: %v", err)
+	}
+	db, err := store.NewDB(filepath.Join(dir, "logryph.db"))
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	// Not started, so nothing drains the one-slot ring once it is filled.
+	worker, err := ledger.NewWorker(1, db, filepath.Join(dir, "test.key"))
+	if err != nil {
+		t.Fatalf("worker: %v", err)
+	}
+	if !worker.Submit(&models.Event{ID: "filler"}) {
+		t.Fatal("expected the first event queued")
+	}
+	i := NewInterceptor(core.NewEngine(worker, obs))
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewBufferString(toolCall(1, "fs:read_file")))
+	forward := i.InterceptRequest(httptest.NewRecorder(), req)
+	if forward == nil {
+		t.Fatal("expected request to be forwarded")
+	}
+	if forward.Header.Get(headerEventID) != "" {
+		t.Errorf("expected no event ID sent upstream for a dropped event, got %q", forward.Header.Get(headerEventID))
+	}
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"result":{}}`)),
+		Request:    forward,
+	}
+	if err := i.InterceptResponse(resp); err != nil {
+		t.Fatalf("intercept response: %v", err)
+	}
+	if resp.Header.Get(headerReceipt) != "" || resp.Header.Get(headerEventID) != "" {
+		t.Errorf("expected no receipt for a dropped event, got %v", resp.Header)
+	}
+	if _, dropped := worker.Stats(); dropped == 0 {
+		t.Error("expected the tool_call to be dropped")
+	}
+}
@@ -3,6 +3,7 @@ package interceptor
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,8 +14,11 @@ import (
 	"github.com/slyt3/Logryph/internal/core"
 	"github.com/slyt3/Logryph/internal/logging"
 	"github.com/slyt3/Logryph/internal/mcp"
+	"github.com/slyt3/Logryph/internal/mirror"
+	"github.com/slyt3/Logryph/internal/models"
 	"github.com/slyt3/Logryph/internal/observer"
 	"github.com/slyt3/Logryph/internal/pool"
+	"github.com/slyt3/Logryph/internal/router"
 )
 
 // PolicyAction defines the outcome of a policy check
@@ -24,54 +28,248 @@ const (
 	ActionAllow  PolicyAction = "allow"
 	ActionTag    PolicyAction = "tag"    // Renamed from Redact/Stall
 	ActionRedact PolicyAction = "redact" // Keep for hygiene, but not blocking
+	ActionDeny   PolicyAction = "deny"   // Opt-in enforcement (rule action: deny)
+
+	ActionRequireApproval PolicyAction = "require_approval" // Held until an operator decides
 )
 
 const (
 	maxPolicies   = 256
-	maxPatterns   = 128
-	maxConditions = 64
 	maxRedactKeys = 128
-	maxParams     = 256
+
+	maxMessageBytes = 16 * 1024 * 1024
 )
 
 // Interceptor handles HTTP proxy interception and MCP JSON-RPC request/response capture.
 // It evaluates policies, applies redaction rules, and submits events to the ledger
-// without blocking agent traffic (fail-open behavior).
+// without blocking agent traffic (fail-open behavior). Router, when set, selects the
+// upstream for each proxied request; nil means a single upstream.
 type Interceptor struct {
-	Core *core.Engine
+	Core     *core.Engine
+	Router   *router.Router
+	inflight *inflightTracker
+	sessions *sessionTracker
+	shadow   *http.Client // Sends mirrored requests to the shadow server
 }
 
 func NewInterceptor(engine *core.Engine) *Interceptor {
-	return &Interceptor{Core: engine}
+	return &Interceptor{Core: engine, inflight: newInflightTracker(), sessions: newSessionTracker(), shadow: &http.Client{}}
 }
 
-// InterceptRequest captures HTTP POST requests, extracts MCP metadata, evaluates policies,
-// applies redaction rules, and submits events to the async worker.
-// Returns immediately without blocking proxy traffic. Drops events on backpressure.
-func (i *Interceptor) InterceptRequest(req *http.Request) {
-	if req.Method != http.MethodPost {
-		return
-	}
+// requestError carries the HTTP status and JSON-RPC error code that a failed
+// request observation maps to. Only blocked errors (policy denials and requests
+// refused while the ledger is unavailable) stop the request; reply is then the
+// JSON-RPC error, or batch of errors, to send the agent instead (nil when only
+// notifications were blocked).
+type requestError struct {
+	status  int
+	code    int
+	message string
+	blocked bool
+	reply   []byte
+}
 
-	if req.Body == nil {
-		return
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// InterceptRequest captures HTTP POST requests, extracts MCP metadata, evaluates
+// policies, applies redaction rules, and submits events to the async worker.
+// Returns the request to forward, carrying the chosen upstream when a Router is
+// set. Returns nil when a deny rule matched, or the ledger is unavailable under
+// ledger_unavailable: reject, and the JSON-RPC error was written to w instead.
+// Otherwise never blocks proxy traffic. Drops events on backpressure.
+func (i *Interceptor) InterceptRequest(w http.ResponseWriter, req *http.Request) *http.Request {
+	// Event IDs sent upstream are set here only; never forward the agent's.
+	req.Header.Del(headerEventID)
+	req = withTrace(req)
+	if req.Method != http.MethodPost || req.Body == nil {
+		req = i.routeRequest(req, nil)
+		// Streamable HTTP clients end a session with DELETE + Mcp-Session-Id.
+		if req.Method == http.MethodDelete && req.Header.Get("Mcp-Session-Id") != "" {
+			i.endSession(requestExchange(req).scope(), sessionEndClientClosed)
+		}
+		return req
 	}
 
 	buf := pool.GetBuffer()
 	defer pool.PutBuffer(buf)
 
-	if _, err := buf.ReadFrom(req.Body); err != nil {
+	// Read one byte past the parse limit; larger bodies are streamed, not buffered.
+	if _, err := buf.ReadFrom(io.LimitReader(req.Body, maxMessageBytes+1)); err != nil {
 		logging.Error("request_body_read_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
-		return
+		return req
+	}
+	// Copy out of the pooled buffer: the proxy reads the body after we return.
+	bodyBytes := append([]byte(nil), buf.Bytes()...)
+	if len(bodyBytes) > maxMessageBytes {
+		return i.interceptOversized(w, req, bodyBytes)
+	}
+	req = i.routeRequest(req, bodyBytes)
+	req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+
+	ex := requestExchange(req)
+	ex.http = i.requestMetadata(req, len(bodyBytes))
+	ex.actor = i.identify(req)
+	forwardBody, err := i.observeRequestBody(bodyBytes, ex)
+	if err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) && reqErr.blocked {
+			i.writeReceipts(w.Header(), ex.recorded)
+			i.SendErrorResponse(w, reqErr)
+			return nil
+		}
+		return withRecorded(req, ex.recorded)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(forwardBody))
+	req.ContentLength = int64(len(forwardBody))
+	return withMirror(withRecorded(req, ex.recorded), ex.mirror)
+}
+
+// interceptOversized handles a request body over maxMessageBytes, of which head
+// has been read. Like observeRequestBody for oversized bodies it is refused while
+// the ledger is unavailable or deny or approval rules are loaded, the rest of the
+// body then being read only to hash it; otherwise it is forwarded through a
+// requestTap, which records it once streamed.
+func (i *Interceptor) interceptOversized(w http.ResponseWriter, req *http.Request, head []byte) *http.Request {
+	req = i.routeRequest(req, nil)
+	ex := requestExchange(req)
+	ex.http = i.requestMetadata(req, len(head))
+	ex.actor = i.identify(req)
+	if i.ledgerUnavailable() {
+		i.SendErrorResponse(w, refuseUnrecorded(head))
+		return nil
+	}
+
+	unparsed := i.newUnparsedBody()
+	if !i.enforces() {
+		tap := i.newRequestTap(head, req.Body, unparsed, ex)
+		req.Body = tap
+		return withTap(req, tap)
+	}
+	_, _ = unparsed.Write(head)
+	if _, err := io.Copy(unparsed, req.Body); err != nil {
+		logging.Warn("request_body_read_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
+	}
+	if ex.http != nil {
+		ex.http.ContentLength = unparsed.size
+	}
+	reqErr := i.refuseUnparsedRequest(unparsed, ex)
+	i.writeReceipts(w.Header(), ex.recorded)
+	i.SendErrorResponse(w, reqErr)
+	return nil
+}
+
+// routeRequest attaches the upstream selected by the Router to the request.
+// The body is only decoded when a route matches on tool names.
+func (i *Interceptor) routeRequest(req *http.Request, body []byte) *http.Request {
+	if i.Router == nil {
+		return req
+	}
+	toolName := ""
+	if len(body) > 0 && i.Router.HasToolRules() {
+		toolName = toolNameOf(body)
+	}
+	return router.WithTarget(req, i.Router.Resolve(req, toolName))
+}
+
+// toolNameOf returns params.name of a single tools/call request, or "".
+// It never asserts, since it runs before the request is validated.
+func toolNameOf(body []byte) string {
+	if isBatch(body) || len(body) > maxMessageBytes {
+		return ""
+	}
+	var msg mcp.MCPMessage
+	if err := json.Unmarshal(body, &msg); err != nil || msg.Method != "tools/call" {
+		return ""
+	}
+	name, _ := msg.Params["name"].(string)
+	return name
+}
+
+// requestExchange derives the JSON-RPC ID scope of an HTTP request: the MCP session
+// when the client sent one, otherwise the client connection, plus the routed upstream.
+func requestExchange(req *http.Request) *exchange {
+	if req == nil {
+		return &exchange{}
+	}
+	session := req.Header.Get("Mcp-Session-Id")
+	if session == "" {
+		session = req.RemoteAddr
+	}
+	ex := &exchange{session: session, ctx: req.Context(), trace: traceFrom(req.Context())}
+	if target, ok := router.TargetFrom(req.Context()); ok {
+		ex.upstream = target.Name
+	}
+	return ex
+}
+
+// responseExchange scopes a response like the request that produced it and
+// records the upstream HTTP status and any session ID the server assigned.
+func responseExchange(resp *http.Response) *exchange {
+	ex := requestExchange(resp.Request)
+	ex.httpStatus = resp.StatusCode
+	ex.assignedSession = resp.Header.Get("Mcp-Session-Id")
+	return ex
+}
+
+// observeRequestBody dispatches a request body to the single-message or batch path.
+// While the ledger is unavailable and ledger_unavailable is reject, the body is
+// refused without being recorded. Client responses are forwarded as they are.
+// Bodies that cannot be checked against policy (over maxMessageBytes, not valid
+// JSON-RPC requests, or failing observation) are recorded from their envelope
+// with the size and SHA-256 of the body and forwarded unchanged, unless deny or
+// approval rules are loaded, which they would bypass: they are then refused
+// and recorded as blocked. Returns the body to forward upstream.
+func (i *Interceptor) observeRequestBody(body []byte, ex *exchange) ([]byte, error) {
+	if err := assert.Check(len(body) > 0, "request body is empty"); err != nil {
+		return nil, &requestError{status: http.StatusBadRequest, code: -32600, message: err.Error()}
+	}
+	if i.ledgerUnavailable() {
+		return nil, refuseUnrecorded(body)
+	}
+	if len(body) > maxMessageBytes {
+		if i.enforces() {
+			unparsed := i.newUnparsedBody()
+			_, _ = unparsed.Write(body)
+			return nil, i.refuseUnparsedRequest(unparsed, ex)
+		}
+		i.submitUnparsedBody(body, ex, oversizeReason(int64(len(body))))
+		return body, nil
+	}
+	if isBatch(body) {
+		return i.observeBatchRequest(body, ex)
+	}
+	if !isRequestMessage(body) {
+		if isResponseMessage(body) {
+			return body, nil
+		}
+		return i.observeUnchecked(body, ex, "Invalid JSON-RPC request")
+	}
+	forward, err := i.observeRequest(body, ex, "")
+	var reqErr *requestError
+	if err != nil && !(errors.As(err, &reqErr) && reqErr.blocked) {
+		return i.observeUnchecked(body, ex, err.Error())
+	}
+	return forward, err
+}
+
+// observeRequest runs a single JSON-RPC request body through metadata extraction,
+// policy evaluation, redaction and event submission. It is transport-agnostic and
+// shared by the HTTP proxy and the stdio relay. batchID is empty outside a batch.
+// Returns the body to forward upstream.
+func (i *Interceptor) observeRequest(body []byte, ex *exchange, batchID string) ([]byte, error) {
+	if err := assert.NotNil(i.Core, "core engine"); err != nil {
+		return nil, err
+	}
+	if err := assert.NotNil(ex, "exchange"); err != nil {
+		return nil, err
 	}
-	bodyBytes := buf.Bytes()
-	req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
 	// 1. Extract Metadata
-	mcpReq, taskID, method, err := i.extractTaskMetadata(bodyBytes)
+	mcpReq, taskID, method, err := i.extractTaskMetadata(body)
 	if err != nil {
-		i.SendErrorResponse(req, http.StatusBadRequest, -32000, err.Error())
-		return
+		return nil, &requestError{status: http.StatusBadRequest, code: -32000, message: err.Error()}
 	}
 	requestID := ""
 	if mcpReq.ID != nil {
@@ -79,49 +277,60 @@ func (i *Interceptor) InterceptRequest(req *http.Request) {
 	}
 
 	// 2. Policy Evaluation
-	action, matchedRule, err := i.evaluatePolicy(method, mcpReq.Params)
+	resolved := observer.ResolveAction(method, mcpReq.Params)
+	resolved.TaskID = taskID
+	resolved.Actor = ex.actor
+	action, matchedRule, err := i.evaluatePolicy(resolved, resolved.Args, observer.MatchOnRequest, false)
 	if err != nil {
-		logging.Warn("policy_evaluation_failed", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: method, Error: err.Error()})
-		i.SendErrorResponse(req, http.StatusBadRequest, -32000, "Policy violation")
-		return
+		logging.Warn("policy_evaluation_failed", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: resolved.Name, Error: err.Error()})
+		return nil, &requestError{status: http.StatusBadRequest, code: -32000, message: "Policy violation"}
 	}
+	stored := i.prepareBody(body, mcpReq.Params, matchedRule)
 
-	// 3. Handle Stall (REMOVED - Phase 2 Lobotomy)
-	// We no longer block traffic. We only observe.
-	// if action == ActionStall { ... }
+	// 3. Opt-in enforcement: only rules with action: deny stop traffic.
+	if action == ActionDeny {
+		return nil, i.denyRequest(ex, taskID, batchID, resolved, mcpReq, matchedRule, stored)
+	}
 
-	// 4. Apply Redaction & Submit Event
-	if err := i.applyRedactionAndSubmit(req, action, matchedRule, bodyBytes, requestID, taskID, method, mcpReq); err != nil {
-		return
+	// 4. Human-in-the-loop: park the call until an operator decides.
+	if action == ActionRequireApproval {
+		return i.observeHeldRequest(body, ex, requestID, taskID, batchID, resolved, mcpReq, matchedRule, stored)
 	}
+
+	// 5. Apply Redaction & Submit Event
+	forward, eventID, err := i.applyRedactionAndSubmit(matchedRule, body, ex, requestID, taskID, batchID, resolved, mcpReq, stored)
+	if err == nil && batchID == "" {
+		i.selectMirror(ex, resolved, eventID, mcpReq.ID, forward)
+	}
+	return forward, err
 }
 
-// applyRedactionAndSubmit handles redaction and event submission
-func (i *Interceptor) applyRedactionAndSubmit(req *http.Request, action PolicyAction, matchedRule *observer.Rule, bodyBytes []byte, requestID, taskID, method string, mcpReq *mcp.MCPRequest) error {
+// applyRedactionAndSubmit handles redaction and event submission. Returns the
+// body to forward and the ID of the recorded event.
+func (i *Interceptor) applyRedactionAndSubmit(matchedRule *observer.Rule, bodyBytes []byte, ex *exchange, requestID, taskID, batchID string, resolved observer.Action, mcpReq *mcp.MCPRequest, stored *requestBody) ([]byte, string, error) {
 	if err := assert.Check(mcpReq != nil, "mcpReq must not be nil"); err != nil {
-		return err
+		return nil, "", err
 	}
-	if err := assert.Check(len(method) > 0, "method must not be empty"); err != nil {
-		return err
+	if err := assert.Check(len(resolved.Name) > 0, "action must not be empty"); err != nil {
+		return nil, "", err
 	}
+	method := resolved.Name
 
 	// Redaction (if needed)
-	if action == ActionRedact && matchedRule != nil {
+	if matchedRule != nil && len(matchedRule.Redact) > 0 {
 		scrubbedBody, err := i.redactSensitiveData(bodyBytes, matchedRule.Redact)
 		if err != nil {
 			logging.Error("redaction_failed", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: method, PolicyID: matchedRule.ID, RiskLevel: matchedRule.RiskLevel, Error: err.Error()})
-			i.SendErrorResponse(req, http.StatusInternalServerError, -32000, "Redaction failed")
-			return err
+			return nil, "", &requestError{status: http.StatusInternalServerError, code: -32000, message: "Redaction failed"}
 		}
 		bodyBytes = scrubbedBody
-		req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 	}
 
 	logging.Info("request_observed", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: method, PolicyID: policyIDOrEmpty(matchedRule), RiskLevel: riskLevelOrEmpty(matchedRule)})
 
 	// Submit Event & Forward
-	i.submitToolCallEvent(taskID, mcpReq, matchedRule)
-	return nil
+	eventID := i.submitToolCallEvent(ex, taskID, batchID, resolved.Name, mcpReq, matchedRule, stored)
+	return bodyBytes, eventID, nil
 }
 
 // extractTaskMetadata parses and validates the request
@@ -129,7 +338,7 @@ func (i *Interceptor) extractTaskMetadata(body []byte) (*mcp.MCPRequest, string,
 	if err := assert.Check(len(body) > 0, "request body is empty"); err != nil {
 		return nil, "", "", err
 	}
-	if err := assert.Check(len(body) < 1024*1024, "request body too large: size=%d", len(body)); err != nil {
+	if err := assert.Check(len(body) <= maxMessageBytes, "request body too large: size=%d", len(body)); err != nil {
 		return nil, "", "", err
 	}
 
@@ -149,96 +358,136 @@ func (i *Interceptor) extractTaskMetadata(body []byte) (*mcp.MCPRequest, string,
 	return &mcpReq, taskID, mcpReq.Method, nil
 }
 
-// evaluatePolicy determines the action for the request
-func (i *Interceptor) evaluatePolicy(method string, params map[string]interface{}) (PolicyAction, *observer.Rule, error) {
+// evaluatePolicy determines the action for a message. Rules are matched against the
+// resolved MCP action; matchOn restricts evaluation to rules targeting that kind of
+// message (observer.MatchOnRequest, MatchOnError or MatchOnResponse) and args is what their
+// conditions see. Shadow messages are not counted against rate_limit rules.
+func (i *Interceptor) evaluatePolicy(action observer.Action, args map[string]interface{}, matchOn string, shadow bool) (PolicyAction, *observer.Rule, error) {
 	if err := assert.Check(i.Core.Observer != nil, "observer engine missing"); err != nil {
 		return ActionAllow, nil, err
 	}
-	if err := assert.Check(method != "", "method name is non-empty"); err != nil {
+	if err := assert.Check(action.Name != "", "action name is non-empty"); err != nil {
 		return ActionAllow, nil, err
 	}
-	policies := i.Core.Observer.GetPolicies()
-	if err := assert.Check(len(policies) <= maxPolicies, "policy count exceeds max: %d", len(policies)); err != nil {
+	if err := assert.Check(i.Core.Observer.GetRuleCount() <= maxPolicies, "policy count exceeds max"); err != nil {
 		return ActionAllow, nil, err
 	}
 
-	// Use the new Observer engine
-	for i := 0; i < maxPolicies; i++ {
-		if i >= len(policies) {
-			break
-		}
-		rule := &policies[i]
-		if err := assert.Check(len(rule.MatchMethods) <= maxPatterns, "match_methods exceeds max in rule=%s", rule.ID); err != nil {
-			return ActionAllow, nil, err
-		}
-		if err := assert.Check(len(rule.MatchConditions) <= maxConditions, "conditions exceeds max in rule=%s", rule.ID); err != nil {
-			return ActionAllow, nil, err
-		}
-		for j := 0; j < maxPatterns; j++ {
-			if j >= len(rule.MatchMethods) {
-				break
-			}
-			pattern := rule.MatchMethods[j]
-			if observer.MatchPattern(pattern, method) {
-				if len(rule.MatchConditions) > 0 && !observer.CheckConditions(rule.MatchConditions, params) {
-					continue
-				}
-
-				action := ActionTag
-				if len(rule.Redact) > 0 {
-					action = ActionRedact
-				}
-
-				return action, rule, nil
-			}
-		}
+	var rule *observer.Rule
+	if shadow {
+		rule = i.Core.Observer.MatchUncounted(action, args, matchOn)
+	} else {
+		rule = i.Core.Observer.Match(action, args, matchOn)
 	}
-
-	return ActionAllow, nil, nil
+	if rule == nil {
+		return ActionAllow, nil, nil
+	}
+	switch rule.Effect() {
+	case observer.RuleActionDeny:
+		return ActionDeny, rule, nil
+	case observer.RuleActionApprove:
+		return ActionRequireApproval, rule, nil
+	}
+	if len(rule.Redact) > 0 {
+		return ActionRedact, rule, nil
+	}
+	return ActionTag, rule, nil
 }
 
 // handleStall was removed in Phase 2 (Lobotomy).
 //func (i *Interceptor) handleStall(...) error { ... }
 
-// submitToolCallEvent prepares and sends the tool_call event to the ledger
-func (i *Interceptor) submitToolCallEvent(taskID string, mcpReq *mcp.MCPRequest, matchedRule *observer.Rule) {
+// submitToolCallEvent prepares and sends the tool_call event to the ledger and
+// registers requests so their response can be correlated. Agent notifications
+// (no ID) are recorded as notification events; notifications/cancelled is linked
+// to the call it cancels. Returns the event ID.
+func (i *Interceptor) submitToolCallEvent(ex *exchange, taskID, batchID, action string, mcpReq *mcp.MCPRequest, matchedRule *observer.Rule, stored *requestBody) string {
 	if err := assert.Check(mcpReq != nil, "mcpReq must not be nil"); err != nil {
-		return
+		return ""
 	}
 	if err := assert.Check(i.Core.Worker != nil, "worker must be initialized"); err != nil {
-		return
+		return ""
 	}
 
 	event := pool.GetEvent()
 	event.ID = uuid.New().String()[:8]
 	event.Timestamp = time.Now()
 	event.EventType = "tool_call"
+	event.Actor = ex.actor
 	event.Method = mcpReq.Method
 	event.Params = mcpReq.Params
+	stored.apply(event)
 	event.TaskID = taskID
+	event.BatchID = batchID
+	event.Upstream = ex.upstream
+	event.HTTP = ex.http
+	event.TraceID, event.SpanID = ex.trace.traceID, ex.trace.spanID
+	event.SessionID = i.sessions.touch(ex.scope(), event.Timestamp)
+	if mcpReq.ID == nil {
+		event.EventType = "notification"
+	}
 
 	if matchedRule != nil {
 		event.PolicyID = matchedRule.ID
 		event.RiskLevel = matchedRule.RiskLevel
 	}
 
-	if taskID != "" {
-		if parentID, ok := i.Core.LastEventByTask.Load(taskID); ok {
-			if pid, ok := parentID.(string); ok {
-				event.ParentID = pid
-			} else {
-				if err := assert.Check(false, "parentID has unexpected type for taskID=%s", taskID); err != nil {
-					logging.Warn("parent_id_type_mismatch", logging.Fields{Component: "interceptor", TaskID: taskID})
-				}
+	i.linkTaskParent(event, taskID)
+	eventID := i.trackCall(ex, mcpReq, event, action, taskID)
+	i.submitRecorded(ex, event, stored)
+	return eventID
+}
+
+// linkTaskParent chains an event to the previous event of its SEP-1686 task and
+// makes it the task's latest.
+func (i *Interceptor) linkTaskParent(event *models.Event, taskID string) {
+	if taskID == "" {
+		return
+	}
+	if parentID, ok := i.Core.LastEventByTask.Load(taskID); ok {
+		if pid, ok := parentID.(string); ok {
+			event.ParentID = pid
+		} else {
+			if err := assert.Check(false, "parentID has unexpected type for taskID=%s", taskID); err != nil {
+				logging.Warn("parent_id_type_mismatch", logging.Fields{Component: "interceptor", TaskID: taskID})
 			}
 		}
-		i.Core.LastEventByTask.Store(taskID, event.ID)
+	}
+	i.Core.LastEventByTask.Store(taskID, event.ID)
+}
+
+// trackCall registers the request recorded by event so its response can be
+// correlated, and links a notifications/cancelled to the call it cancels.
+// Returns the event ID.
+func (i *Interceptor) trackCall(ex *exchange, mcpReq *mcp.MCPRequest, event *models.Event, action, taskID string) string {
+	if mcpReq.Method == "notifications/cancelled" {
+		// The cancelled call gets no response; stop tracking it.
+		if call, ok := i.inflight.resolve(ex.scope(), rpcIDKey(mcpReq.Params["requestId"])); ok {
+			event.ParentID = call.eventID
+		}
 	}
 
-	i.Core.Worker.Submit(event)
+	call := inflightCall{
+		eventID: event.ID,
+		method:  event.Method,
+		action:  action,
+		taskID:  taskID,
+		actor:   ex.actor,
+		started: event.Timestamp,
+	}
+	if mcpReq.Method == "initialize" {
+		// Copy: the event's Params map is cleared when it returns to the pool.
+		call.params = make(map[string]interface{}, len(mcpReq.Params))
+		for k, v := range mcpReq.Params {
+			call.params[k] = v
+		}
+	}
+	i.inflight.track(ex.scope(), rpcIDKey(mcpReq.ID), call)
+	return call.eventID
 }
 
-// redactSensitiveData scrubs PII based on policy (accepts and returns bytes)
+// redactSensitiveData scrubs PII from params and params.arguments based on policy
+// (accepts and returns bytes)
 func (i *Interceptor) redactSensitiveData(body []byte, keys []string) ([]byte, error) {
 	if err := assert.Check(len(body) > 0, "body must not be empty"); err != nil {
 		return nil, err
@@ -255,9 +504,9 @@ func (i *Interceptor) redactSensitiveData(body []byte, keys []string) ([]byte, e
 		return nil, err
 	}
 
-	if err := assert.Check(len(mcpReq.Params) <= maxParams, "excessive parameters in request: %d", len(mcpReq.Params)); err != nil {
-		return nil, err
-	}
+	// Tool and prompt arguments are scrubbed too (see observer.ResolveAction).
+	// Keys are looked up, so the agent's param count does not bound the work.
+	args, _ := mcpReq.Params["arguments"].(map[string]interface{})
 	for i := 0; i < maxRedactKeys; i++ {
 		if i >= len(keys) {
 			break
@@ -266,6 +515,9 @@ func (i *Interceptor) redactSensitiveData(body []byte, keys []string) ([]byte, e
 		if _, ok := mcpReq.Params[key]; ok {
 			mcpReq.Params[key] = "[REDACTED]"
 		}
+		if _, ok := args[key]; ok {
+			args[key] = "[REDACTED]"
+		}
 	}
 
 	return json.Marshal(mcpReq)
@@ -273,8 +525,27 @@ func (i *Interceptor) redactSensitiveData(body []byte, keys []string) ([]byte, e
 
 // InterceptResponse captures HTTP responses, extracts task_id and state from MCP results,
 // and submits tool_response events to the ledger. Returns nil on JSON parse errors
-// to maintain fail-open behavior.
+// to maintain fail-open behavior. SSE responses (MCP Streamable HTTP) are not
+// buffered: the body is wrapped so each JSON-RPC message is recorded as it streams.
 func (i *Interceptor) InterceptResponse(resp *http.Response) error {
+	if err := assert.NotNil(resp, "response"); err != nil {
+		return nil
+	}
+	i.writeReceipts(resp.Header, recordedFrom(resp.Request))
+	if resp.Body == nil {
+		return nil
+	}
+	ex := responseExchange(resp)
+	ex.http = i.responseMetadata(resp)
+	if resp.StatusCode == http.StatusNotFound && ex.session != "" && resp.Request != nil && resp.Request.Header.Get("Mcp-Session-Id") != "" {
+		// 404 on a session request means the server expired the session.
+		i.endSession(ex.scope(), sessionEndServerExpired)
+	}
+	if isEventStream(resp.Header.Get("Content-Type")) {
+		resp.Body = newSSETap(resp.Body, i, ex)
+		return nil
+	}
+
 	buf := pool.GetBuffer()
 	defer pool.PutBuffer(buf)
 
@@ -284,54 +555,257 @@ func (i *Interceptor) InterceptResponse(resp *http.Response) error {
 	bodyBytes := buf.Bytes()
 	resp.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
-	var mcpResp mcp.MCPResponse
-	if err := json.Unmarshal(bodyBytes, &mcpResp); err != nil {
+	if isBatch(bodyBytes) {
+		i.observeServerBatch(bodyBytes, ex)
 		return nil
 	}
+	var msg mcp.MCPMessage
+	if err := json.Unmarshal(bodyBytes, &msg); err != nil {
+		return nil
+	}
+	i.submitResponseEvent(&msg, ex, "")
+	return nil
+}
+
+// observeServerMessage records a single server-to-agent JSON-RPC message taken from an
+// SSE stream or a stdio line. Responses become tool_response events; server-initiated
+// requests and notifications (e.g. notifications/progress, sampling/createMessage) are
+// recorded under their own types.
+func (i *Interceptor) observeServerMessage(payload []byte, ex *exchange) {
+	if err := assert.Check(len(payload) > 0, "server message must not be empty"); err != nil {
+		return
+	}
+	if err := assert.Check(len(payload) <= maxMessageBytes, "server message too large: %d", len(payload)); err != nil {
+		return
+	}
+
+	if isBatch(payload) {
+		i.observeServerBatch(payload, ex)
+		return
+	}
+	var msg mcp.MCPMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		logging.Warn("server_message_invalid", logging.Fields{Component: "interceptor", Error: err.Error()})
+		return
+	}
+	i.dispatchServerMessage(&msg, ex, "")
+}
+
+// dispatchServerMessage routes a decoded server message to the response or
+// server-request/notification recorder.
+func (i *Interceptor) dispatchServerMessage(msg *mcp.MCPMessage, ex *exchange, batchID string) {
+	if err := assert.NotNil(msg, "message"); err != nil {
+		return
+	}
+	if msg.Method == "" {
+		i.submitResponseEvent(msg, ex, batchID)
+		return
+	}
+	i.submitServerMessageEvent(msg, ex, batchID)
+}
+
+// submitResponseEvent records a JSON-RPC response as a tool_response event, or a
+// tool_error event carrying the error object, and tracks SEP-1686 task state carried
+// in the result. Responses matching an in-flight call are linked to it via ParentID
+// and carry its method and round-trip latency.
+func (i *Interceptor) submitResponseEvent(msg *mcp.MCPMessage, ex *exchange, batchID string) {
+	if err := assert.NotNil(msg, "message"); err != nil {
+		return
+	}
+	if err := assert.NotNil(ex, "exchange"); err != nil {
+		return
+	}
+	if err := assert.Check(i.Core.Worker != nil, "worker must be initialized"); err != nil {
+		return
+	}
 
 	requestID := ""
-	if mcpResp.ID != nil {
-		requestID = fmt.Sprint(mcpResp.ID)
+	if msg.ID != nil {
+		requestID = fmt.Sprint(msg.ID)
 	}
+	call, correlated := i.inflight.resolve(ex.scope(), rpcIDKey(msg.ID))
+	now := time.Now()
 
 	if !i.Core.Worker.IsHealthy() {
-		return nil
+		return
+	}
+
+	taskID, taskState := i.responseTask(msg.Result)
+	if correlated && taskID == "" {
+		taskID = call.taskID
+	}
+
+	logging.Info("response_observed", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: call.method, EventID: call.eventID})
+	if correlated && i.Core.Mirror != nil && i.Core.Mirror.Wants(call.eventID) {
+		// Before blob references replace large values.
+		i.Core.Mirror.Primary(call.eventID, mirror.Digest(msg.Result, msg.Error))
+	}
+
+	event := newResponseEvent(msg, ex, batchID, now)
+	event.TaskID = taskID
+	event.TaskState = taskState
+	event.SessionID = i.sessions.touch(ex.scope(), now)
+	if correlated {
+		event.ParentID = call.eventID
+		event.Method = call.method
+		event.LatencyMs = now.Sub(call.started).Milliseconds()
+	}
+	// Before blob references replace large values, so that conditions see them
+	// and redacted values never reach the blob store.
+	i.tagResponseEvent(event, call, msg.Error != nil)
+	if msg.Error == nil {
+		// Before any other event shares the result's maps.
+		event.BlobRefs = i.storeResultBlobs(msg.Result)
+		if correlated && call.method == "initialize" && msg.Result != nil {
+			event.SessionID = i.startSession(ex.sessionScope(), ex, call, msg.Result)
+		}
 	}
 
-	var taskID string
-	var taskState string
+	i.Core.Worker.Submit(event)
+}
 
-	if result := mcpResp.Result; result != nil {
-		if tid, ok := result["task_id"].(string); ok {
-			taskID = tid
+// responseTask returns the SEP-1686 task ID and state carried in a result, and
+// records the state as the task's current one.
+func (i *Interceptor) responseTask(result map[string]interface{}) (taskID, taskState string) {
+	if result == nil {
+		return "", ""
+	}
+	taskID, _ = result["task_id"].(string)
+	if state, ok := result["state"].(string); ok {
+		taskState = state
+		if taskID != "" {
+			i.Core.ActiveTasks.Store(taskID, taskState)
 		}
-		if state, ok := result["state"].(string); ok {
-			taskState = state
-			if taskID != "" {
-				i.Core.ActiveTasks.Store(taskID, taskState)
-			}
+	}
+	return taskID, taskState
+}
+
+// newResponseEvent builds the tool_response (or, for an error, tool_error) event
+// of msg with the exchange's transport details.
+func newResponseEvent(msg *mcp.MCPMessage, ex *exchange, batchID string, now time.Time) *models.Event {
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = now
+	event.EventType = "tool_response"
+	event.Response = msg.Result
+	event.BatchID = batchID
+	event.HTTPStatus = ex.httpStatus
+	event.Upstream = ex.upstream
+	event.HTTP = ex.http
+	event.TraceID, event.SpanID = ex.trace.traceID, ex.trace.spanID
+	if msg.Error != nil {
+		event.EventType = "tool_error"
+		event.Response = msg.Error
+	}
+	return event
+}
+
+// tagResponseEvent applies response-side rules to the event recording a reply to
+// call (a tool_response, tool_error or shadow_response): match_on: response
+// rules, after match_on: error rules for errors. Rules match the call's method,
+// resolved action (e.g. its tool name) and actor, so uncorrelated responses are
+// left untagged. Conditions address the result or error object, from which the
+// matching rule's redact paths are scrubbed before the event is persisted (the
+// agent still gets the original). Shadow responses do not count towards
+// rate-limited error rules.
+func (i *Interceptor) tagResponseEvent(event *models.Event, call inflightCall, isError bool) {
+	if err := assert.NotNil(event, "event"); err != nil {
+		return
+	}
+	if call.method == "" || event.Response == nil {
+		return
+	}
+	resolved := observer.Action{Method: call.method, Name: call.action, Actor: call.actor, TaskID: call.taskID}
+	if resolved.Name == "" {
+		resolved.Name = call.method
+	}
+	targets := []string{observer.MatchOnResponse}
+	if isError {
+		targets = []string{observer.MatchOnError, observer.MatchOnResponse}
+	}
+	var rule *observer.Rule
+	for j := 0; j < len(targets) && rule == nil; j++ {
+		_, matched, err := i.evaluatePolicy(resolved, event.Response, targets[j], event.EventType == "shadow_response")
+		if err != nil {
+			logging.Warn("response_policy_evaluation_failed", logging.Fields{Component: "interceptor", Method: call.method, Error: err.Error()})
+			return
 		}
+		rule = matched
+	}
+	if rule != nil {
+		event.PolicyID = rule.ID
+		event.RiskLevel = rule.RiskLevel
+		rule.RedactPayload(event.Response)
+	}
+	fields := logging.Fields{Component: "interceptor", Method: call.method, EventID: event.ParentID, PolicyID: policyIDOrEmpty(rule), RiskLevel: riskLevelOrEmpty(rule)}
+	if event.EventType == "tool_error" {
+		logging.Info("tool_error_observed", fields)
+	} else if rule != nil {
+		logging.Info("response_policy_matched", fields)
 	}
+}
+
+// submitServerMessageEvent records a server-to-client request or notification.
+func (i *Interceptor) submitServerMessageEvent(msg *mcp.MCPMessage, ex *exchange, batchID string) {
+	if err := assert.NotNil(msg, "message"); err != nil {
+		return
+	}
+	if err := assert.Check(msg.Method != "", "server message method must not be empty"); err != nil {
+		return
+	}
+	if err := assert.NotNil(ex, "exchange"); err != nil {
+		return
+	}
+	if !i.Core.Worker.IsHealthy() {
+		return
+	}
+
+	eventType := "notification"
+	requestID := ""
+	if msg.ID != nil {
+		eventType = "server_request"
+		requestID = fmt.Sprint(msg.ID)
+	}
+	taskID, _ := msg.Params["task_id"].(string)
 
-	logging.Info("response_observed", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID})
+	logging.Info("server_message_observed", logging.Fields{Component: "interceptor", RequestID: requestID, TaskID: taskID, Method: msg.Method})
 
 	event := pool.GetEvent()
 	event.ID = uuid.New().String()[:8]
 	event.Timestamp = time.Now()
-	event.EventType = "tool_response"
-	event.Response = mcpResp.Result
+	event.EventType = eventType
+	event.Method = msg.Method
+	event.Params = msg.Params
 	event.TaskID = taskID
-	event.TaskState = taskState
+	event.BatchID = batchID
+	event.HTTPStatus = ex.httpStatus
+	event.Upstream = ex.upstream
+	event.HTTP = ex.http
+	event.TraceID, event.SpanID = ex.trace.traceID, ex.trace.spanID
+	event.SessionID = i.sessions.touch(ex.scope(), event.Timestamp)
 
 	i.Core.Worker.Submit(event)
-	return nil
 }
 
-// SendErrorResponse is a no-op stub retained for backwards compatibility.
-// Phase 2 (Lobotomy) removed request blocking - Logryph now operates as a passive recorder.
-// Does not send HTTP errors or block traffic.
-func (i *Interceptor) SendErrorResponse(req *http.Request, statusCode int, code int, message string) {
-	// Passive: We do not block. We just log the failure to record if needed.
+// SendErrorResponse answers a blocked request with its JSON-RPC error. Requests
+// that only carried notifications get 202 Accepted with no body, as for any
+// notification in MCP Streamable HTTP.
+func (i *Interceptor) SendErrorResponse(w http.ResponseWriter, reqErr *requestError) {
+	if err := assert.NotNil(w, "response writer"); err != nil {
+		return
+	}
+	if err := assert.NotNil(reqErr, "request error"); err != nil {
+		return
+	}
+	if len(reqErr.reply) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(reqErr.status)
+	if _, err := w.Write(reqErr.reply); err != nil {
+		logging.Warn("error_response_write_failed", logging.Fields{Component: "interceptor", Error: err.Error()})
+	}
 }
 
 func policyIDOrEmpty(rule *observer.Rule) string {
@@ -0,0 +1,274 @@
+package interceptor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+const (
+	maxSessions          = 1024
+	sessionIdleTimeout   = 30 * time.Minute
+	sessionSweepInterval = time.Minute
+)
+
+// Reasons recorded on session_ended events.
+const (
+	sessionEndClientClosed  = "client_closed"
+	sessionEndServerExpired = "server_expired"
+	sessionEndReinitialized = "reinitialized"
+	sessionEndIdleTimeout   = "idle_timeout"
+	sessionEndShutdown      = "shutdown"
+	sessionEndServerExited  = "server_exited"
+)
+
+// mcpSession is one MCP session, opened by a successful initialize exchange.
+// id is Logryph's own identifier; the transport's Mcp-Session-Id is only used as
+// a lookup key and never recorded, since it acts as a credential.
+type mcpSession struct {
+	id       string
+	upstream string
+	actor    string
+	started  time.Time
+	lastSeen time.Time
+	messages int
+}
+
+// sessionTracker maps transport scopes (see exchange.scope) to open sessions.
+type sessionTracker struct {
+	mu       sync.Mutex
+	sessions map[string]*mcpSession
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+func newSessionTracker() *sessionTracker {
+	return &sessionTracker{
+		sessions: make(map[string]*mcpSession),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// touch returns the ID of the session open on scope (or "") and marks it active.
+func (t *sessionTracker) touch(scope string, now time.Time) string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.sessions[scope]
+	if !ok {
+		return ""
+	}
+	s.lastSeen = now
+	s.messages++
+	return s.id
+}
+
+// lookup returns the ID of the session open on scope, or "", without marking it active.
+func (t *sessionTracker) lookup(scope string) string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if s, ok := t.sessions[scope]; ok {
+		return s.id
+	}
+	return ""
+}
+
+// open registers a new session on scope and returns it along with the session it
+// replaced, if the client re-initialized. Returns nil when the table is full.
+func (t *sessionTracker) open(scope, upstream, actor string, now time.Time) (opened, replaced *mcpSession) {
+	if t == nil {
+		return nil, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	replaced = t.sessions[scope]
+	if replaced == nil && len(t.sessions) >= maxSessions {
+		logging.Warn("session_table_full", logging.Fields{Component: "interceptor"})
+		return nil, nil
+	}
+	opened = &mcpSession{id: uuid.New().String()[:8], upstream: upstream, actor: actor, started: now, lastSeen: now, messages: 1}
+	t.sessions[scope] = opened
+	return opened, replaced
+}
+
+// close removes and returns the session open on scope, if any.
+func (t *sessionTracker) close(scope string) *mcpSession {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.sessions[scope]
+	if !ok {
+		return nil
+	}
+	delete(t.sessions, scope)
+	return s
+}
+
+// drain removes and returns sessions idle since before cutoff (all of them for a
+// zero cutoff).
+func (t *sessionTracker) drain(cutoff time.Time) []*mcpSession {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var ended []*mcpSession
+	for scope, s := range t.sessions {
+		if cutoff.IsZero() || s.lastSeen.Before(cutoff) {
+			ended = append(ended, s)
+			delete(t.sessions, scope)
+		}
+	}
+	return ended
+}
+
+// startSession opens a session after a successful initialize response and records
+// a session_started event with the negotiated protocol version, both parties' info
+// and capabilities, and the identity of the agent that sent initialize (call).
+func (i *Interceptor) startSession(scope string, ex *exchange, call inflightCall, result map[string]interface{}) string {
+	if err := assert.NotNil(ex, "exchange"); err != nil {
+		return ""
+	}
+	if err := assert.Check(i.Core.Worker != nil, "worker must be initialized"); err != nil {
+		return ""
+	}
+	now := time.Now()
+	s, replaced := i.sessions.open(scope, ex.upstream, call.actor, now)
+	if replaced != nil {
+		i.submitSessionEnded(replaced, sessionEndReinitialized)
+	}
+	if s == nil {
+		return ""
+	}
+
+	params := map[string]interface{}{
+		"protocol_version":    result["protocolVersion"],
+		"server_info":         result["serverInfo"],
+		"server_capabilities": result["capabilities"],
+	}
+	if clientParams := call.params; clientParams != nil {
+		params["client_protocol_version"] = clientParams["protocolVersion"]
+		params["client_info"] = clientParams["clientInfo"]
+		params["client_capabilities"] = clientParams["capabilities"]
+	}
+	if s.actor != "" {
+		params["actor"] = s.actor
+	}
+
+	logging.Info("session_started", logging.Fields{Component: "interceptor", EventID: s.id})
+
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = now
+	event.Actor = "system"
+	event.EventType = "session_started"
+	event.Method = "logryph:session"
+	event.Params = params
+	event.ParentID = call.eventID
+	event.SessionID = s.id
+	event.Upstream = ex.upstream
+	event.TraceID, event.SpanID = ex.trace.traceID, ex.trace.spanID
+	i.Core.Worker.Submit(event)
+	return s.id
+}
+
+// endSession closes the session open on scope, if any, with the given reason.
+func (i *Interceptor) endSession(scope, reason string) {
+	if s := i.sessions.close(scope); s != nil {
+		i.submitSessionEnded(s, reason)
+	}
+}
+
+func (i *Interceptor) submitSessionEnded(s *mcpSession, reason string) {
+	if err := assert.NotNil(s, "session"); err != nil {
+		return
+	}
+	if err := assert.Check(reason != "", "session end reason must not be empty"); err != nil {
+		return
+	}
+	if err := assert.Check(i.Core.Worker != nil, "worker must be initialized"); err != nil {
+		return
+	}
+	now := time.Now()
+	logging.Info("session_ended", logging.Fields{Component: "interceptor", EventID: s.id})
+
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = now
+	event.Actor = "system"
+	event.EventType = "session_ended"
+	event.Method = "logryph:session"
+	event.Params = map[string]interface{}{
+		"reason":      reason,
+		"duration_ms": now.Sub(s.started).Milliseconds(),
+		"messages":    s.messages,
+	}
+	if s.actor != "" {
+		event.Params["actor"] = s.actor
+	}
+	event.SessionID = s.id
+	event.Upstream = s.upstream
+	i.Core.Worker.Submit(event)
+}
+
+// WatchSessions starts a background goroutine that ends sessions idle for longer
+// than sessionIdleTimeout. Call Stop() to terminate it.
+func (i *Interceptor) WatchSessions() {
+	if err := assert.NotNil(i.sessions, "session tracker"); err != nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(sessionSweepInterval)
+		defer ticker.Stop()
+
+		const maxSweepTicks = 1 << 30
+		for n := 0; n < maxSweepTicks; n++ {
+			select {
+			case now := <-ticker.C:
+				i.endSessions(i.sessions.drain(now.Add(-sessionIdleTimeout)), sessionEndIdleTimeout)
+			case <-i.sessions.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+// Stop terminates the session watcher and records session_ended for every open
+// session. Call before shutting down the worker. Safe to call multiple times.
+func (i *Interceptor) Stop() {
+	if err := assert.NotNil(i.sessions, "session tracker"); err != nil {
+		return
+	}
+	i.sessions.stopOnce.Do(func() {
+		close(i.sessions.stopChan)
+	})
+	i.endSessions(i.sessions.drain(time.Time{}), sessionEndShutdown)
+}
+
+func (i *Interceptor) endSessions(ended []*mcpSession, reason string) {
+	if err := assert.Check(len(ended) <= maxSessions, "ended sessions exceed max: %d", len(ended)); err != nil {
+		return
+	}
+	for j := 0; j < maxSessions; j++ {
+		if j >= len(ended) {
+			break
+		}
+		i.submitSessionEnded(ended[j], reason)
+	}
+}
@@ -0,0 +1,51 @@
+package interceptor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionTrackerLifecycle(t *testing.T) {
+	tr := newSessionTracker()
+	now := time.Now()
+
+	if id := tr.touch("scope", now); id != "" {
+		t.Fatalf("expected no session before initialize, got %q", id)
+	}
+	first, replaced := tr.open("scope", "default", "", now)
+	if first == nil || replaced != nil {
+		t.Fatalf("expected a fresh session, got %+v (replaced %+v)", first, replaced)
+	}
+	if id := tr.touch("scope", now.Add(time.Second)); id != first.id {
+		t.Fatalf("expected session %s, got %q", first.id, id)
+	}
+
+	second, replaced := tr.open("scope", "default", "", now)
+	if replaced != first || second.id == first.id {
+		t.Fatalf("re-initialize must replace the open session")
+	}
+	if s := tr.close("scope"); s != second {
+		t.Fatalf("expected close to return the open session")
+	}
+	if s := tr.close("scope"); s != nil {
+		t.Fatal("expected no session after close")
+	}
+}
+
+func TestSessionTrackerDrainIdle(t *testing.T) {
+	tr := newSessionTracker()
+	now := time.Now()
+	tr.open("idle", "", "", now.Add(-2*sessionIdleTimeout))
+	tr.open("active", "", "", now)
+
+	ended := tr.drain(now.Add(-sessionIdleTimeout))
+	if len(ended) != 1 {
+		t.Fatalf("expected 1 idle session, got %d", len(ended))
+	}
+	if tr.touch("active", now) == "" {
+		t.Fatal("active session must survive the sweep")
+	}
+	if ended := tr.drain(time.Time{}); len(ended) != 1 {
+		t.Fatalf("expected zero cutoff to drain all sessions, got %d", len(ended))
+	}
+}
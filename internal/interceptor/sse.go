@@ -0,0 +1,195 @@
+package interceptor
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+const (
+	maxSSEChunkBytes    = 1024 * 1024
+	maxSSEEventBytes    = 1024 * 1024
+	maxSSELinesPerChunk = maxSSEChunkBytes + 1
+	maxSSEPending       = 1024
+	maxContentTypeBytes = 1024 // Longer upstream headers are not SSE
+)
+
+// isEventStream reports whether a Content-Type header denotes an SSE stream
+// (MCP Streamable HTTP).
+func isEventStream(contentType string) bool {
+	if len(contentType) > maxContentTypeBytes {
+		return false
+	}
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	return strings.EqualFold(mediaType, "text/event-stream")
+}
+
+// sseParser incrementally decodes a text/event-stream body.
+// It tolerates events split across arbitrary read boundaries and returns
+// the data payload of every completed event.
+type sseParser struct {
+	line     bytes.Buffer // partial line carried across reads
+	data     bytes.Buffer // data field of the event being assembled
+	skipLF   bool         // previous chunk ended in '\r'
+	overflow bool         // current event exceeded maxSSEEventBytes
+}
+
+// feed consumes one chunk of the stream and returns the data payloads of any
+// events completed by it. Oversized events are discarded and logged.
+func (p *sseParser) feed(chunk []byte) [][]byte {
+	if err := assert.NotNil(p, "sse parser"); err != nil {
+		return nil
+	}
+	if err := assert.Check(len(chunk) <= maxSSEChunkBytes, "sse chunk too large: %d", len(chunk)); err != nil {
+		return nil
+	}
+
+	var payloads [][]byte
+	rest := chunk
+	for j := 0; j < maxSSELinesPerChunk; j++ {
+		if len(rest) == 0 {
+			break
+		}
+		if p.skipLF {
+			p.skipLF = false
+			if rest[0] == '\n' {
+				rest = rest[1:]
+				continue
+			}
+		}
+		idx := bytes.IndexAny(rest, "\r\n")
+		if idx < 0 {
+			p.appendLine(rest)
+			break
+		}
+		p.appendLine(rest[:idx])
+		p.skipLF = rest[idx] == '\r'
+		rest = rest[idx+1:]
+		if payload := p.processLine(); payload != nil {
+			payloads = append(payloads, payload)
+		}
+	}
+	return payloads
+}
+
+func (p *sseParser) appendLine(b []byte) {
+	if err := assert.NotNil(p, "sse parser"); err != nil {
+		return
+	}
+	if p.line.Len()+len(b) > maxSSEEventBytes {
+		p.overflow = true
+		return
+	}
+	p.line.Write(b)
+}
+
+// processLine interprets the buffered line. A blank line dispatches the
+// current event; only the "data" field is retained, since MCP carries
+// JSON-RPC messages exclusively in data lines.
+func (p *sseParser) processLine() []byte {
+	if err := assert.NotNil(p, "sse parser"); err != nil {
+		return nil
+	}
+	line := p.line.Bytes()
+	defer p.line.Reset()
+
+	if len(line) == 0 {
+		return p.dispatch()
+	}
+	if line[0] == ':' {
+		return nil // comment / keep-alive
+	}
+
+	field, value := line, []byte(nil)
+	if idx := bytes.IndexByte(line, ':'); idx >= 0 {
+		field = line[:idx]
+		value = bytes.TrimPrefix(line[idx+1:], []byte(" "))
+	}
+	if string(field) != "data" {
+		return nil
+	}
+	if p.data.Len()+len(value)+1 > maxSSEEventBytes {
+		p.overflow = true
+		return nil
+	}
+	if p.data.Len() > 0 {
+		p.data.WriteByte('\n')
+	}
+	p.data.Write(value)
+	return nil
+}
+
+func (p *sseParser) dispatch() []byte {
+	if err := assert.NotNil(p, "sse parser"); err != nil {
+		return nil
+	}
+	defer p.data.Reset()
+	if p.overflow {
+		p.overflow = false
+		logging.Warn("sse_event_too_large", logging.Fields{Component: "interceptor"})
+		return nil
+	}
+	if p.data.Len() == 0 {
+		return nil
+	}
+	payload := make([]byte, p.data.Len())
+	copy(payload, p.data.Bytes())
+	return payload
+}
+
+// sseTap wraps an upstream SSE body. Bytes pass through to the agent untouched;
+// messages completed by a read are recorded at the start of the next read (or on
+// Close), i.e. only after the proxy has already forwarded them.
+type sseTap struct {
+	src         io.ReadCloser
+	parser      sseParser
+	pending     [][]byte
+	interceptor *Interceptor
+	exchange    *exchange
+}
+
+func newSSETap(src io.ReadCloser, interceptor *Interceptor, ex *exchange) *sseTap {
+	return &sseTap{src: src, interceptor: interceptor, exchange: ex}
+}
+
+func (t *sseTap) Read(b []byte) (int, error) {
+	if err := assert.NotNil(t.src, "sse source"); err != nil {
+		return 0, err
+	}
+	t.recordPending()
+
+	n, err := t.src.Read(b)
+	if n > 0 && n <= maxSSEChunkBytes {
+		t.pending = append(t.pending, t.parser.feed(b[:n])...)
+		if len(t.pending) > maxSSEPending {
+			logging.Warn("sse_pending_overflow", logging.Fields{Component: "interceptor"})
+			t.pending = t.pending[len(t.pending)-maxSSEPending:]
+		}
+	}
+	return n, err
+}
+
+func (t *sseTap) Close() error {
+	if err := assert.NotNil(t.src, "sse source"); err != nil {
+		return err
+	}
+	t.recordPending()
+	return t.src.Close()
+}
+
+func (t *sseTap) recordPending() {
+	if err := assert.Check(len(t.pending) <= maxSSEPending, "sse pending exceeds max: %d", len(t.pending)); err != nil {
+		t.pending = nil
+		return
+	}
+	for i := 0; i < maxSSEPending; i++ {
+		if i >= len(t.pending) {
+			break
+		}
+		t.interceptor.observeServerMessage(t.pending[i], t.exchange)
+	}
+	t.pending = t.pending[:0]
+}
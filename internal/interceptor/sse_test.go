@@ -0,0 +1,70 @@
+package interceptor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSSEParserSplitsAcrossChunks(t *testing.T) {
+	var p sseParser
+	chunks := []string{
+		"event: message\r\ndata: {\"jsonrpc\":",
+		"\"2.0\",\"id\":1,\"result\":{}}\r",
+		"\n\r\n: keep-alive\n\n",
+		"data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n",
+	}
+
+	var got []string
+	for _, c := range chunks {
+		for _, payload := range p.feed([]byte(c)) {
+			got = append(got, string(payload))
+		}
+	}
+
+	want := []string{
+		`{"jsonrpc":"2.0","id":1,"result":{}}`,
+		`{"jsonrpc":"2.0","method":"notifications/progress"}`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d payloads, got %d: %q", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("payload %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestSSEParserJoinsMultiLineData(t *testing.T) {
+	var p sseParser
+	payloads := p.feed([]byte("data: {\"a\":\ndata:1}\n\n"))
+	if len(payloads) != 1 {
+		t.Fatalf("expected 1 payload, got %d", len(payloads))
+	}
+	if string(payloads[0]) != "{\"a\":\n1}" {
+		t.Errorf("unexpected payload: %q", payloads[0])
+	}
+}
+
+func TestSSEParserIgnoresIncompleteEvent(t *testing.T) {
+	var p sseParser
+	if payloads := p.feed([]byte("data: {\"partial\":true}\n")); len(payloads) != 0 {
+		t.Fatalf("expected no payloads before blank line, got %d", len(payloads))
+	}
+}
+
+func TestIsEventStream(t *testing.T) {
+	tests := map[string]bool{
+		"text/event-stream":                true,
+		"text/event-stream; charset=utf-8": true,
+		"TEXT/EVENT-STREAM":                true,
+		"application/json":                 false,
+		"":                                 false,
+	}
+	tests["text/event-stream; x="+strings.Repeat("a", 2048)] = false // Over maxContentTypeBytes
+	for ct, want := range tests {
+		if got := isEventStream(ct); got != want {
+			t.Errorf("isEventStream(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
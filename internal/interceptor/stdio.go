@@ -0,0 +1,379 @@
+package interceptor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+const (
+	maxStdioLineBytes = maxMessageBytes
+	maxStdioReads     = 1 << 40
+	stdioReadBufBytes = 64 * 1024
+)
+
+// StdioRelay wraps a local MCP server subprocess launched over stdio.
+// Newline-delimited JSON-RPC is relayed between the agent's stdin/stdout and the
+// child; agent messages go through the same policy, redaction and submit path as
+// the HTTP interceptor, server messages are recorded after they are forwarded.
+// Agent requests stopped by a deny rule are answered on agentOut by the relay.
+type StdioRelay struct {
+	interceptor *Interceptor
+	cmd         *exec.Cmd
+	exchange    *exchange
+	agentOut    io.Writer
+}
+
+// lockedWriter serializes writes to the agent from the server pump and from
+// denials raised on the agent pump.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// NewStdioRelay prepares (but does not start) the MCP server subprocess.
+// Returns an error if the interceptor is nil or the command name is empty.
+func NewStdioRelay(interceptor *Interceptor, name string, args []string) (*StdioRelay, error) {
+	if err := assert.NotNil(interceptor, "interceptor"); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, fmt.Errorf("stdio server command must not be empty")
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = os.Stderr
+	return &StdioRelay{interceptor: interceptor, cmd: cmd, exchange: &exchange{session: stdioSession}}, nil
+}
+
+// agentExchange scopes an agent message. The relay's agent has no transport
+// credentials, so it is named by identity.default, if configured.
+func (r *StdioRelay) agentExchange() *exchange {
+	ex := *r.exchange
+	ex.actor = r.interceptor.identify(nil)
+	return &ex
+}
+
+// Run starts the subprocess and relays traffic until the server closes its stdout.
+// Closing agentIn closes the child's stdin, which MCP servers treat as shutdown.
+// Returns the relay or process error; a non-zero exit is reported via ExitCode().
+func (r *StdioRelay) Run(agentIn io.Reader, agentOut io.Writer) error {
+	if err := assert.NotNil(agentIn, "agent input"); err != nil {
+		return err
+	}
+	if err := assert.NotNil(agentOut, "agent output"); err != nil {
+		return err
+	}
+
+	childIn, err := r.cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("opening server stdin: %w", err)
+	}
+	childOut, err := r.cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("opening server stdout: %w", err)
+	}
+	if err := r.cmd.Start(); err != nil {
+		return fmt.Errorf("starting server: %w", err)
+	}
+	logging.Info("stdio_server_started", logging.Fields{Component: "stdio", Method: r.cmd.Path})
+	r.agentOut = &lockedWriter{w: agentOut}
+
+	go func() {
+		if err := r.pump(agentIn, childIn, true); err != nil {
+			logging.Warn("stdio_agent_pump_failed", logging.Fields{Component: "stdio", Error: err.Error()})
+		}
+		if err := childIn.Close(); err != nil {
+			logging.Warn("stdio_server_stdin_close_failed", logging.Fields{Component: "stdio", Error: err.Error()})
+		}
+	}()
+
+	pumpErr := r.pump(childOut, r.agentOut, false)
+	r.interceptor.endSession(r.exchange.scope(), sessionEndServerExited)
+	waitErr := r.cmd.Wait()
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		return fmt.Errorf("waiting for server: %w", waitErr)
+	}
+	return pumpErr
+}
+
+// Stop asks the subprocess to terminate, killing it if interrupts are unsupported.
+func (r *StdioRelay) Stop() error {
+	if err := assert.NotNil(r.cmd, "command"); err != nil {
+		return err
+	}
+	if r.cmd.Process == nil {
+		return nil
+	}
+	if err := r.cmd.Process.Signal(os.Interrupt); err != nil {
+		return r.cmd.Process.Kill()
+	}
+	return nil
+}
+
+// ExitCode returns the subprocess exit code, or -1 if it has not exited.
+func (r *StdioRelay) ExitCode() int {
+	if err := assert.NotNil(r.cmd, "command"); err != nil {
+		return -1
+	}
+	if r.cmd.ProcessState == nil {
+		return -1
+	}
+	return r.cmd.ProcessState.ExitCode()
+}
+
+// passthroughLine is a line longer than maxStdioLineBytes, streamed to dst as it
+// arrives instead of being buffered. Agent lines are hashed (and offloaded) on
+// the way so they are still recorded, from their envelope only. While deny or
+// approval rules are loaded, an agent line is refused instead: it is consumed
+// without being forwarded and the agent gets a JSON-RPC error once it ends.
+type passthroughLine struct {
+	active  bool
+	refused bool
+	body    *unparsedBody
+}
+
+// pump copies newline-delimited messages from src to dst. Lines longer than
+// maxStdioLineBytes are streamed through rather than buffered.
+func (r *StdioRelay) pump(src io.Reader, dst io.Writer, toServer bool) error {
+	reader := bufio.NewReaderSize(src, stdioReadBufBytes)
+	var line bytes.Buffer
+	var passthrough passthroughLine
+
+	for n := 0; n < maxStdioReads; n++ {
+		chunk, err := reader.ReadSlice('\n')
+		if len(chunk) > 0 {
+			if werr := r.handleChunk(&line, &passthrough, chunk, dst, toServer); werr != nil {
+				return werr
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			if passthrough.active {
+				r.finishPassthrough(&passthrough)
+				return nil
+			}
+			if line.Len() > 0 {
+				return r.forwardLine(line.Bytes(), dst, toServer)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return assert.Check(false, "stdio pump exceeded max reads")
+}
+
+func (r *StdioRelay) handleChunk(line *bytes.Buffer, passthrough *passthroughLine, chunk []byte, dst io.Writer, toServer bool) error {
+	if err := assert.NotNil(line, "line buffer"); err != nil {
+		return err
+	}
+	if err := assert.Check(len(chunk) > 0, "chunk must not be empty"); err != nil {
+		return err
+	}
+	complete := chunk[len(chunk)-1] == '\n'
+
+	if passthrough.active {
+		var err error
+		if !passthrough.refused {
+			_, err = dst.Write(chunk)
+		}
+		passthrough.record(chunk, complete)
+		if complete {
+			r.finishPassthrough(passthrough)
+		}
+		return err
+	}
+	if line.Len()+len(chunk) > maxStdioLineBytes {
+		logging.Warn("stdio_line_too_large", logging.Fields{Component: "stdio"})
+		*passthrough = passthroughLine{active: true}
+		if toServer {
+			passthrough.body = r.interceptor.newUnparsedBody()
+			passthrough.refused = r.interceptor.enforces()
+			passthrough.record(line.Bytes(), false)
+		}
+		if !passthrough.refused {
+			if _, err := dst.Write(line.Bytes()); err != nil {
+				return err
+			}
+		}
+		line.Reset()
+		var err error
+		if !passthrough.refused {
+			_, err = dst.Write(chunk)
+		}
+		passthrough.record(chunk, complete)
+		if complete {
+			r.finishPassthrough(passthrough)
+		}
+		return err
+	}
+
+	line.Write(chunk)
+	if !complete {
+		return nil
+	}
+	err := r.forwardLine(line.Bytes(), dst, toServer)
+	line.Reset()
+	return err
+}
+
+// record feeds a passed-through chunk to the body hash, without the line terminator.
+func (p *passthroughLine) record(chunk []byte, complete bool) {
+	if p.body == nil {
+		return
+	}
+	if complete {
+		chunk = bytes.TrimRight(chunk, "\r\n")
+	}
+	_, _ = p.body.Write(chunk)
+}
+
+// finishPassthrough records a passed-through agent line once it is complete, or
+// answers a refused one. Panics are contained like in observeAgentLine; a refused
+// line was never forwarded, and still gets its JSON-RPC error if recording it panics.
+func (r *StdioRelay) finishPassthrough(p *passthroughLine) {
+	body, refused := p.body, p.refused
+	*p = passthroughLine{}
+	if body == nil {
+		return
+	}
+	var reply []byte
+	defer func() {
+		if rec := recover(); rec != nil {
+			logging.Critical("stdio_observe_panic", logging.Fields{Component: "stdio", Error: fmt.Sprint(rec)})
+			if refused {
+				reply = failedReply(body.prefix)
+			}
+		}
+		if reply == nil {
+			return
+		}
+		if _, err := r.agentOut.Write(append(reply, '\n')); err != nil {
+			logging.Warn("stdio_reply_failed", logging.Fields{Component: "stdio", Error: err.Error()})
+		}
+	}()
+	if !refused {
+		r.interceptor.submitUnparsedRequest(body, r.agentExchange(), oversizeReason(body.size))
+		return
+	}
+	reply = r.interceptor.refuseUnparsedRequest(body, r.agentExchange()).reply
+}
+
+// forwardLine writes one message to dst. Agent messages are observed first so that
+// redaction applies to what the server receives; server messages are written first
+// so recording never delays the agent.
+func (r *StdioRelay) forwardLine(raw []byte, dst io.Writer, toServer bool) error {
+	if err := assert.Check(len(raw) > 0, "line must not be empty"); err != nil {
+		return err
+	}
+	message := bytes.TrimRight(raw, "\r\n")
+
+	if !toServer {
+		if _, err := dst.Write(raw); err != nil {
+			return err
+		}
+		r.observeServerLine(message)
+		return nil
+	}
+
+	out, reply := r.observeAgentLine(message)
+	if reply != nil {
+		if _, err := r.agentOut.Write(append(reply, '\n')); err != nil {
+			return err
+		}
+	}
+	if out == nil {
+		return nil
+	}
+	if _, err := dst.Write(out); err != nil {
+		return err
+	}
+	_, err := dst.Write([]byte("\n"))
+	return err
+}
+
+// observeAgentLine records an agent-to-server message (or batch) and returns the line
+// to forward. Client responses are forwarded unchanged; lines that are not valid
+// JSON-RPC are handled like invalid HTTP bodies (refused only while deny or
+// approval rules are loaded).
+// A denied message returns a nil out and the JSON-RPC error reply for the agent, if any.
+// Panics raised by strict assertions are contained here so the relay stays up. While
+// deny or approval rules are loaded the message is then refused, as it was not
+// checked against them; otherwise it is forwarded unchanged, like the proxy would.
+func (r *StdioRelay) observeAgentLine(message []byte) (out, reply []byte) {
+	out = message
+	defer func() {
+		if rec := recover(); rec != nil {
+			logging.Critical("stdio_observe_panic", logging.Fields{Component: "stdio", Error: fmt.Sprint(rec)})
+			out, reply = message, nil
+			if r.interceptor.enforces() {
+				out, reply = nil, r.refusePanicked(message)
+			}
+		}
+	}()
+	if len(bytes.TrimSpace(message)) == 0 {
+		return message, nil
+	}
+
+	if !isBatch(message) && isResponseMessage(message) {
+		return message, nil
+	}
+	ex := r.agentExchange()
+	forward, err := r.interceptor.observeRequestBody(message, ex)
+	var reqErr *requestError
+	if err != nil && !(errors.As(err, &reqErr) && reqErr.blocked) {
+		forward, err = r.interceptor.observeUnchecked(message, ex, err.Error())
+	}
+	if errors.As(err, &reqErr) && reqErr.blocked {
+		return nil, reqErr.reply
+	}
+	if err != nil {
+		return nil, nil
+	}
+	return forward, nil
+}
+
+// refusePanicked refuses an agent message whose observation panicked, recording it
+// as blocked with refuseFailed. The agent gets its JSON-RPC error even if recording
+// panics too.
+func (r *StdioRelay) refusePanicked(message []byte) (reply []byte) {
+	reply = failedReply(message)
+	defer func() {
+		if rec := recover(); rec != nil {
+			logging.Critical("stdio_refuse_panic", logging.Fields{Component: "stdio", Error: fmt.Sprint(rec)})
+		}
+	}()
+	return r.interceptor.refuseFailed(message, r.agentExchange()).reply
+}
+
+// observeServerLine records a server-to-agent message. Panics are contained.
+func (r *StdioRelay) observeServerLine(message []byte) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			logging.Critical("stdio_observe_panic", logging.Fields{Component: "stdio", Error: fmt.Sprint(rec)})
+		}
+	}()
+	if len(bytes.TrimSpace(message)) == 0 {
+		return
+	}
+	r.interceptor.observeServerMessage(message, r.exchange)
+}
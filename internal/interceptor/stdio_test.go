@@ -0,0 +1,241 @@
+package interceptor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// stdioHelperEnv selects the helper server's behaviour when the test binary is
+// re-run as the relay's subprocess.
+const stdioHelperEnv = "LOGRYPH_STDIO_HELPER"
+
+// TestStdioHelperServer is not a test: it is the MCP server the relay tests
+// launch. It answers every request with the length of the line it received,
+// and in "exit-after-init" mode exits right after answering initialize.
+func TestStdioHelperServer(t *testing.T) {
+	mode := os.Getenv(stdioHelperEnv)
+	if mode == "" {
+		t.Skip("helper process only")
+	}
+	reader := bufio.NewReader(os.Stdin)
+	for n := 0; n < 1000; n++ {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var msg struct {
+				ID     json.RawMessage `json:"id"`
+				Method string          `json:"method"`
+			}
+			_ = json.Unmarshal(line, &msg)
+			if msg.Method == "initialize" {
+				fmt.Printf(`{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"2025-06-18","capabilities":{},"serverInfo":{"name":"helper"}}}`+"\n", msg.ID)
+				if mode == "exit-after-init" {
+					os.Exit(0)
+				}
+			} else if len(msg.ID) > 0 {
+				fmt.Printf(`{"jsonrpc":"2.0","id":%s,"result":{"received":%d}}`+"\n", msg.ID, len(strings.TrimRight(string(line), "\n")))
+			}
+		}
+		if err != nil {
+			os.Exit(0)
+		}
+	}
+	os.Exit(0)
+}
+
+// runRelay runs a relay to the helper server in the given mode, feeding it
+// input through a pipe, and returns the replies the agent received by id.
+func runRelay(t *testing.T, i *Interceptor, mode, input string) map[string]map[string]interface{} {
+	t.Helper()
+	relay, err := NewStdioRelay(i, os.Args[0], []string{"-test.run=^TestStdioHelperServer$"})
+	if err != nil {
+		t.Fatalf("relay: %v", err)
+	}
+	relay.cmd.Env = append(os.Environ(), stdioHelperEnv+"="+mode)
+
+	agentIn, agentWriter := io.Pipe()
+	defer agentWriter.Close()
+	go func() {
+		_, _ = io.WriteString(agentWriter, input)
+		if mode != "exit-after-init" {
+			agentWriter.Close()
+		}
+	}()
+
+	var agentOut strings.Builder
+	done := make(chan error, 1)
+	go func() { done <- relay.Run(agentIn, &agentOut) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run: %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		_ = relay.Stop()
+		t.Fatal("relay did not finish")
+	}
+	if code := relay.ExitCode(); code != 0 {
+		t.Fatalf("helper server exited with %d", code)
+	}
+
+	replies := make(map[string]map[string]interface{})
+	lines := strings.Split(strings.TrimSpace(agentOut.String()), "\n")
+	for j := 0; j < len(lines); j++ {
+		if lines[j] == "" {
+			continue
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[j]), &msg); err != nil {
+			t.Fatalf("agent received invalid line %q: %v", lines[j], err)
+		}
+		id := fmt.Sprint(msg["id"])
+		if _, dup := replies[id]; dup {
+			t.Fatalf("agent received two replies for id %s", id)
+		}
+		replies[id] = msg
+	}
+	return replies
+}
+
+func errorCode(msg map[string]interface{}) int {
+	e, _ := msg["error"].(map[string]interface{})
+	code, _ := e["code"].(float64)
+	return int(code)
+}
+
+func TestStdioDeniedCallIsAnsweredByRelay(t *testing.T) {
+	i, drain := newTestInterceptor(t, denyPolicy)
+	replies := runRelay(t, i, "echo", toolCall(1, "fs:delete_file")+"\n"+toolCall(2, "fs:read_file")+"\n")
+
+	if len(replies) != 2 {
+		t.Fatalf("expected 2 replies, got %v", replies)
+	}
+	if denied := replies["1"]; denied["result"] != nil || errorCode(denied) != codePolicyDenied {
+		t.Errorf("expected the denied call answered by the relay and never reaching the server, got %v", denied)
+	}
+	if allowed := replies["2"]; allowed["result"] == nil {
+		t.Errorf("expected the allowed call answered by the server, got %v", allowed)
+	}
+
+	var blocked []models.Event
+	for _, e := range drain() {
+		if e.EventType == "blocked" {
+			blocked = append(blocked, e)
+		}
+	}
+	if len(blocked) != 1 || blocked[0].PolicyID != "no-deletes" {
+		t.Errorf("expected one blocked event for the denied call, got %+v", blocked)
+	}
+}
+
+// oversizedCall returns a tools/call line too large for the relay to buffer.
+func oversizedCall(id int) string {
+	content := strings.Repeat("x", maxStdioLineBytes)
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"fs:write","arguments":{"content":%q}}}`, id, content)
+}
+
+func TestStdioOversizedLinePassesThrough(t *testing.T) {
+	i, drain := newTestInterceptor(t, "policies: []\n")
+	line := oversizedCall(3)
+	replies := runRelay(t, i, "echo", line+"\n"+toolCall(4, "fs:read_file")+"\n")
+
+	received, _ := replies["3"]["result"].(map[string]interface{})
+	if got, _ := received["received"].(float64); int(got) != len(line) {
+		t.Errorf("expected the server to receive the whole %d byte line, got %v", len(line), replies["3"])
+	}
+	if replies["4"]["result"] == nil {
+		t.Errorf("expected the next line relayed normally, got %v", replies["4"])
+	}
+
+	calls := requestEvents(drain())
+	if len(calls) != 2 || calls[0].BodyStorage != bodyOmitted || calls[0].BodySize != int64(len(line)) {
+		t.Fatalf("expected the oversized line recorded from its envelope, got %+v", calls)
+	}
+}
+
+func TestStdioOversizedLineRefusedUnderDenyRules(t *testing.T) {
+	i, drain := newTestInterceptor(t, denyPolicy)
+	replies := runRelay(t, i, "echo", oversizedCall(5)+"\n"+toolCall(6, "fs:read_file")+"\n")
+
+	if refused := replies["5"]; refused["result"] != nil || errorCode(refused) != codeBodyTooLarge {
+		t.Errorf("expected the oversized line refused without reaching the server, got %v", refused)
+	}
+	if replies["6"]["result"] == nil {
+		t.Errorf("expected the next line relayed normally, got %v", replies["6"])
+	}
+
+	var blocked int
+	for _, e := range drain() {
+		if e.EventType == "blocked" && e.BodyStorage == bodyOmitted {
+			blocked++
+		}
+	}
+	if blocked != 1 {
+		t.Errorf("expected one blocked event for the oversized line, got %d", blocked)
+	}
+}
+
+func TestStdioServerExitEndsSession(t *testing.T) {
+	i, drain := newTestInterceptor(t, "policies: []\n")
+	// The agent keeps its end open: only the server exiting ends the relay.
+	initialize := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{}}}`
+	replies := runRelay(t, i, "exit-after-init", initialize+"\n")
+	if replies["1"]["result"] == nil {
+		t.Fatalf("expected the initialize result relayed, got %v", replies)
+	}
+
+	var started, ended *models.Event
+	events := drain()
+	for j := range events {
+		switch events[j].EventType {
+		case "session_started":
+			started = &events[j]
+		case "session_ended":
+			ended = &events[j]
+		}
+	}
+	if started == nil || ended == nil {
+		t.Fatalf("expected the session started and ended, got %d events", len(events))
+	}
+	if ended.SessionID != started.SessionID || ended.Params["reason"] != sessionEndServerExited {
+		t.Errorf("unexpected session end: session=%s reason=%v", ended.SessionID, ended.Params["reason"])
+	}
+}
+
+// panicPolicy loads a deny rule alongside a redact rule with more keys than
+// redactSensitiveData allows, which trips a strict assertion on every match.
+var panicPolicy = denyPolicy + `  - id: "scrub-writes"
+    match_methods: ["fs:write*"]
+    risk_level: "high"
+    redact: [` + strings.Repeat(`"secret", `, maxRedactKeys) + `"secret"]
+`
+
+func TestStdioPanickingObservationRefusedUnderDenyRules(t *testing.T) {
+	i, drain := newTestInterceptor(t, panicPolicy)
+	call := `{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"fs:write_file","arguments":{"secret":"hunter2"}}}`
+	replies := runRelay(t, i, "echo", call+"\n"+toolCall(8, "fs:read_file")+"\n")
+
+	if refused := replies["7"]; refused["result"] != nil || errorCode(refused) != codeInternalError {
+		t.Errorf("expected the call refused without reaching the server, got %v", refused)
+	}
+	if replies["8"]["result"] == nil {
+		t.Errorf("expected the next line relayed normally, got %v", replies["8"])
+	}
+
+	var blocked int
+	for _, e := range drain() {
+		if e.EventType == "blocked" && e.Method == "tools/call" {
+			blocked++
+		}
+	}
+	if blocked != 1 {
+		t.Errorf("expected one blocked event for the refused call, got %d", blocked)
+	}
+}
@@ -0,0 +1,107 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Headers added to requests forwarded upstream, so tool servers and tracing
+// backends can join their records with Logryph events.
+const (
+	headerTraceparent = "traceparent"
+	traceVersion      = "00"
+	traceSampled      = "01"
+)
+
+// traceContext is the W3C trace context of an HTTP exchange. Logryph acts as one
+// span: it continues the agent's trace when the request carries a valid
+// traceparent, else starts a new one, and sends its own span ID upstream as the
+// parent. All events of the exchange record traceID and spanID.
+type traceContext struct {
+	traceID string
+	spanID  string
+	flags   string
+}
+
+// newTraceContext continues the trace of an incoming traceparent header, if valid.
+func newTraceContext(incoming string) traceContext {
+	tc := traceContext{flags: traceSampled}
+	if traceID, flags, ok := parseTraceparent(incoming); ok {
+		tc.traceID, tc.flags = traceID, flags
+	} else {
+		tc.traceID = randomHex(16)
+	}
+	tc.spanID = randomHex(8)
+	return tc
+}
+
+// header returns the traceparent to send upstream.
+func (tc traceContext) header() string {
+	return traceVersion + "-" + tc.traceID + "-" + tc.spanID + "-" + tc.flags
+}
+
+// parseTraceparent returns the trace ID and flags of a W3C traceparent. Versions
+// other than 00 are read by their 00 prefix, as the spec asks; all-zero IDs and
+// version ff are invalid.
+func parseTraceparent(v string) (traceID, flags string, ok bool) {
+	v = strings.TrimSpace(v)
+	if len(v) < 55 || (len(v) > 55 && v[55] != '-') {
+		return "", "", false
+	}
+	version, traceID, parentID, flags := v[0:2], v[3:35], v[36:52], v[53:55]
+	if v[2] != '-' || v[35] != '-' || v[52] != '-' || version == "ff" || (version == traceVersion && len(v) != 55) {
+		return "", "", false
+	}
+	if !lowerHex(version) || !lowerHex(traceID) || !lowerHex(parentID) || !lowerHex(flags) {
+		return "", "", false
+	}
+	if allZero(traceID) || allZero(parentID) {
+		return "", "", false
+	}
+	return traceID, flags, true
+}
+
+func lowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func allZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+// randomHex returns n random bytes as hex. A read failure yields a fixed,
+// non-zero ID rather than an invalid header.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		b[n-1] = 1
+	}
+	return hex.EncodeToString(b)
+}
+
+type traceKey struct{}
+
+// withTrace starts the exchange's trace context, sets the traceparent sent
+// upstream, and carries the context to the response.
+func withTrace(req *http.Request) *http.Request {
+	tc := newTraceContext(req.Header.Get(headerTraceparent))
+	req.Header.Set(headerTraceparent, tc.header())
+	return req.WithContext(context.WithValue(req.Context(), traceKey{}, tc))
+}
+
+func traceFrom(ctx context.Context) traceContext {
+	if ctx == nil {
+		return traceContext{}
+	}
+	tc, _ := ctx.Value(traceKey{}).(traceContext)
+	return tc
+}
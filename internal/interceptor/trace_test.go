@@ -0,0 +1,73 @@
+package interceptor
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	cases := []struct {
+		header string
+		ok     bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if _, _, ok := parseTraceparent(c.header); ok != c.ok {
+			t.Errorf("%q: expected ok=%v", c.header, c.ok)
+		}
+	}
+}
+
+func TestForwardedRequestCarriesTraceContext(t *testing.T) {
+	i, drain := newTestInterceptor(t, denyPolicy)
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewBufferString(toolCall(1, "fs:read_file")))
+	req.Header.Set("traceparent", incoming)
+	req.Header.Set(headerEventID, "spoofed")
+	forward := i.InterceptRequest(httptest.NewRecorder(), req)
+	if forward == nil {
+		t.Fatal("expected request to be forwarded")
+	}
+	parts := strings.Split(forward.Header.Get("traceparent"), "-")
+	if len(parts) != 4 || parts[1] != "4bf92f3577b34da6a3ce929d0e0e4736" || parts[2] == "00f067aa0ba902b7" || parts[3] != "01" {
+		t.Fatalf("expected the agent's trace continued with a new span, got %q", forward.Header.Get("traceparent"))
+	}
+	ids := forward.Header.Values(headerEventID)
+	if len(ids) != 1 || ids[0] == "spoofed" {
+		t.Fatalf("expected the recorded event ID upstream, got %v", ids)
+	}
+
+	fresh := httptest.NewRequest(http.MethodPost, "/mcp", bytes.NewBufferString(toolCall(2, "fs:read_file")))
+	fresh.Header.Set("traceparent", "garbage")
+	forwardFresh := i.InterceptRequest(httptest.NewRecorder(), fresh)
+	if _, _, ok := parseTraceparent(forwardFresh.Header.Get("traceparent")); !ok {
+		t.Fatalf("expected a new valid traceparent, got %q", forwardFresh.Header.Get("traceparent"))
+	}
+
+	events := drain()
+	found := false
+	for _, e := range events {
+		if e.ID == ids[0] {
+			found = true
+			if e.TraceID != parts[1] || e.SpanID != parts[2] {
+				t.Errorf("expected event trace %s/%s, got %s/%s", parts[1], parts[2], e.TraceID, e.SpanID)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("event %s not recorded", ids[0])
+	}
+}
@@ -0,0 +1,119 @@
+package interceptor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/models"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+const (
+	maxCapturedHeaders  = 64
+	maxHeaderValueBytes = 4096
+)
+
+// sensitiveHeaders are never stored in clear, whatever the allowlist says.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"X-Admin-Token":       true,
+}
+
+// requestMetadata captures connection facts and allowlisted headers of an agent request.
+func (i *Interceptor) requestMetadata(req *http.Request, bodyLen int) *models.HTTPMetadata {
+	if err := assert.NotNil(req, "request"); err != nil {
+		return nil
+	}
+	capture := i.httpCapture()
+	return &models.HTTPMetadata{
+		ClientAddr:     req.RemoteAddr,
+		Method:         req.Method,
+		Path:           req.URL.Path,
+		ContentLength:  int64(bodyLen),
+		RequestHeaders: captureHeaders(req.Header, capture.RequestHeaders, capture.HashedHeaders),
+	}
+}
+
+// responseMetadata captures the upstream response's allowlisted headers along with
+// the facts of the request it answers. ContentLength is unknown (0) for streams.
+func (i *Interceptor) responseMetadata(resp *http.Response) *models.HTTPMetadata {
+	if err := assert.NotNil(resp, "response"); err != nil {
+		return nil
+	}
+	capture := i.httpCapture()
+	meta := &models.HTTPMetadata{
+		ResponseHeaders: captureHeaders(resp.Header, capture.ResponseHeaders, capture.HashedHeaders),
+	}
+	if resp.ContentLength > 0 {
+		meta.ContentLength = resp.ContentLength
+	}
+	if req := resp.Request; req != nil {
+		meta.ClientAddr = req.RemoteAddr
+		meta.Method = req.Method
+		meta.Path = req.URL.Path
+	}
+	return meta
+}
+
+func (i *Interceptor) httpCapture() observer.HTTPCapture {
+	if i.Core == nil || i.Core.Observer == nil {
+		return observer.HTTPCapture{}
+	}
+	return i.Core.Observer.GetHTTPCapture()
+}
+
+// captureHeaders copies allowlisted headers, hashing sensitive or oversized values.
+// Returns nil when nothing was captured.
+func captureHeaders(header http.Header, allow, hashed []string) map[string]string {
+	if len(allow) == 0 || len(header) == 0 {
+		return nil
+	}
+	if err := assert.Check(len(allow) <= maxCapturedHeaders, "header allowlist exceeds max: %d", len(allow)); err != nil {
+		return nil
+	}
+
+	var captured map[string]string
+	for j := 0; j < maxCapturedHeaders; j++ {
+		if j >= len(allow) {
+			break
+		}
+		name := http.CanonicalHeaderKey(allow[j])
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		value := strings.Join(values, ", ")
+		if sensitiveHeaders[name] || containsHeader(hashed, name) || len(value) > maxHeaderValueBytes {
+			value = hashHeaderValue(value)
+		}
+		if captured == nil {
+			captured = make(map[string]string)
+		}
+		captured[name] = value
+	}
+	return captured
+}
+
+func containsHeader(names []string, name string) bool {
+	for j := 0; j < maxCapturedHeaders; j++ {
+		if j >= len(names) {
+			break
+		}
+		if strings.EqualFold(names[j], name) {
+			return true
+		}
+	}
+	return false
+}
+
+func hashHeaderValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,41 @@
+package interceptor
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCaptureHeadersAllowlist(t *testing.T) {
+	header := http.Header{}
+	header.Set("User-Agent", "agent/1.0")
+	header.Set("Authorization", "Bearer secret")
+	header.Set("X-Tenant", "acme")
+	header.Set("X-Ignored", "nope")
+
+	got := captureHeaders(header, []string{"user-agent", "authorization", "x-tenant"}, []string{"X-TENANT"})
+
+	if got["User-Agent"] != "agent/1.0" {
+		t.Errorf("expected User-Agent in clear, got %q", got["User-Agent"])
+	}
+	if !strings.HasPrefix(got["Authorization"], "sha256:") || strings.Contains(got["Authorization"], "secret") {
+		t.Errorf("expected Authorization to be hashed, got %q", got["Authorization"])
+	}
+	if got["X-Tenant"] != hashHeaderValue("acme") {
+		t.Errorf("expected configured hashed header, got %q", got["X-Tenant"])
+	}
+	if _, ok := got["X-Ignored"]; ok {
+		t.Error("headers outside the allowlist must not be captured")
+	}
+}
+
+func TestCaptureHeadersEmpty(t *testing.T) {
+	header := http.Header{}
+	header.Set("User-Agent", "agent/1.0")
+	if got := captureHeaders(header, nil, nil); got != nil {
+		t.Errorf("expected nil without an allowlist, got %v", got)
+	}
+	if got := captureHeaders(header, []string{"Mcp-Session-Id"}, nil); got != nil {
+		t.Errorf("expected nil when no allowlisted header is present, got %v", got)
+	}
+}
@@ -93,25 +93,8 @@ func VerifyEvent(event *models.Event, signer *crypto.Signer) error {
 	if err := assert.Check(event.CurrentHash != "", "event current hash is missing: id=%s", event.ID); err != nil {
 		return err
 	}
-	// 4. Calculate hash using normalized payload and JCS
-	tsStr := event.Timestamp.Format(time.RFC3339Nano)
-	// Recalculate the hash
-	payload := map[string]interface{}{
-		"id":         event.ID,
-		"run_id":     event.RunID,
-		"seq_index":  event.SeqIndex,
-		"timestamp":  tsStr, // Use the formatted string
-		"actor":      event.Actor,
-		"event_type": event.EventType,
-		"method":     event.Method,
-		"params":     event.Params,
-		"response":   event.Response,
-		"task_id":    event.TaskID,
-		"task_state": event.TaskState,
-		"parent_id":  event.ParentID,
-		"policy_id":  event.PolicyID,
-		"risk_level": event.RiskLevel,
-	}
+	// 4. Recalculate the hash using the normalized payload and JCS
+	payload := event.HashPayload()
 
 	calculatedHash, err := crypto.CalculateEventHash(event.PrevHash, payload)
 	if err != nil {
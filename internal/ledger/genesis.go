@@ -43,22 +43,7 @@ func CreateGenesisBlock(db EventRepository, signer *crypto.Signer, agentName str
 	}
 
 	// Calculate genesis hash
-	payload := map[string]interface{}{
-		"id":         genesisEvent.ID,
-		"run_id":     genesisEvent.RunID,
-		"seq_index":  genesisEvent.SeqIndex,
-		"timestamp":  genesisEvent.Timestamp.Format(time.RFC3339Nano),
-		"actor":      genesisEvent.Actor,
-		"event_type": genesisEvent.EventType,
-		"method":     genesisEvent.Method,
-		"params":     genesisEvent.Params,
-		"response":   genesisEvent.Response,
-		"task_id":    genesisEvent.TaskID,
-		"task_state": genesisEvent.TaskState,
-		"parent_id":  genesisEvent.ParentID,
-		"policy_id":  genesisEvent.PolicyID,
-		"risk_level": genesisEvent.RiskLevel,
-	}
+	payload := genesisEvent.HashPayload()
 
 	currentHash, err := crypto.CalculateEventHash(genesisEvent.PrevHash, payload)
 	if err != nil {
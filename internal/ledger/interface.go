@@ -8,6 +8,7 @@ type RunStats struct {
 	TotalEvents   uint64         `json:"total_events"`
 	CallCount     uint64         `json:"call_count"`
 	BlockedCount  uint64         `json:"blocked_count"`
+	ErrorCount    uint64         `json:"error_count"`
 	RiskBreakdown map[string]int `json:"risk_breakdown"`
 }
 
@@ -31,6 +32,7 @@ type EventRepository interface {
 	GetRecentEvents(runID string, limit int) ([]models.Event, error)
 	GetEventsByTaskID(taskID string) ([]models.Event, error)
 	GetRiskEvents() ([]models.Event, error)
+	GetErrorEvents() ([]models.Event, error)
 
 	// Meta
 	HasRuns() (bool, error)
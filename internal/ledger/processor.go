@@ -133,22 +133,7 @@ func (p *EventProcessor) hashAndSignEvent(event *models.Event) error {
 		return err
 	}
 
-	payload := map[string]interface{}{
-		"id":         event.ID,
-		"run_id":     event.RunID,
-		"seq_index":  event.SeqIndex,
-		"timestamp":  event.Timestamp.Format(time.RFC3339Nano),
-		"actor":      event.Actor,
-		"event_type": event.EventType,
-		"method":     event.Method,
-		"params":     event.Params,
-		"response":   event.Response,
-		"task_id":    event.TaskID,
-		"task_state": event.TaskState,
-		"parent_id":  event.ParentID,
-		"policy_id":  event.PolicyID,
-		"risk_level": event.RiskLevel,
-	}
+	payload := event.HashPayload()
 
 	currentHash, err := crypto.CalculateEventHash(event.PrevHash, payload)
 	if err != nil {
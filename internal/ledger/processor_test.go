@@ -1,7 +1,9 @@
 package ledger
 
 import (
+	"errors"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -15,13 +17,23 @@ type mockEventRepository struct {
 	lastSeq  uint64
 	lastHash string
 	events   []*models.Event
+	failing  atomic.Bool // Fail every StoreEvent, like a full disk
 }
 
 func (m *mockEventRepository) StoreEvent(event *models.Event) error {
 	if event == nil {
 		return assert.Check(false, "event is nil")
 	}
-	m.events = append(m.events, event)
+	if m.failing.Load() {
+		return errors.New("disk I/O error")
+	}
+	// Keep a copy, as a database would: the worker returns events to the pool.
+	stored := *event
+	stored.Params = make(map[string]interface{}, len(event.Params))
+	for k, v := range event.Params {
+		stored.Params[k] = v
+	}
+	m.events = append(m.events, &stored)
 	m.lastSeq = event.SeqIndex
 	m.lastHash = event.CurrentHash
 	return nil
@@ -63,6 +75,10 @@ func (m *mockEventRepository) GetRiskEvents() ([]models.Event, error) {
 	return nil, nil
 }
 
+func (m *mockEventRepository) GetErrorEvents() ([]models.Event, error) {
+	return nil, nil
+}
+
 func (m *mockEventRepository) HasRuns() (bool, error) {
 	return len(m.events) > 0, nil
 }
@@ -0,0 +1,191 @@
+package ledger
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+// Values of Receipt.Kind. Events are persisted asynchronously, so a receipt
+// issued before its event reached the ledger can only promise its inclusion.
+const (
+	ReceiptCommitment = "commitment" // Event persisted: Seq and EventHash are set
+	ReceiptPromise    = "promise"    // Event queued: its ID and body digest are promised
+)
+
+const (
+	receiptVersion   = 1
+	maxReceiptBytes  = 4096
+	maxCommittedKeep = 4096
+)
+
+// Receipt is a signed statement, handed to the agent, that an event was recorded.
+// A commitment pins the event's position and hash in the chain; a promise binds
+// the ledger key to including an event with this ID (and request body digest)
+// in the run. Both are checked against the ledger by `logyctl verify-receipt`.
+type Receipt struct {
+	Version    int       `json:"v"`
+	Kind       string    `json:"kind"`
+	RunID      string    `json:"run_id"`
+	EventID    string    `json:"event_id"`
+	Seq        uint64    `json:"seq,omitempty"`
+	EventHash  string    `json:"event_hash,omitempty"`
+	BodySHA256 string    `json:"body_sha256,omitempty"`
+	IssuedAt   time.Time `json:"issued_at"`
+	PublicKey  string    `json:"public_key"`
+	Signature  string    `json:"signature,omitempty"`
+}
+
+// Digest returns the hex SHA-256 of the receipt without its signature; that
+// string is what the ledger key signs, as for events.
+func (r *Receipt) Digest() (string, error) {
+	unsigned := *r
+	unsigned.Signature = ""
+	encoded, err := json.Marshal(&unsigned)
+	if err != nil {
+		return "", fmt.Errorf("encoding receipt: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifySignature checks the receipt's signature against its public key. Whether
+// that key belongs to the ledger is for the caller to decide.
+func (r *Receipt) VerifySignature() error {
+	if err := assert.NotNil(r, "receipt"); err != nil {
+		return err
+	}
+	pub, err := hex.DecodeString(r.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("receipt public key is not an Ed25519 key")
+	}
+	sig, err := hex.DecodeString(r.Signature)
+	if err != nil {
+		return fmt.Errorf("receipt signature is not hex: %w", err)
+	}
+	digest, err := r.Digest()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, []byte(digest), sig) {
+		return fmt.Errorf("receipt signature is invalid")
+	}
+	return nil
+}
+
+// Encode returns the receipt as unpadded base64url JSON, fit for an HTTP header.
+func (r *Receipt) Encode() (string, error) {
+	encoded, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("encoding receipt: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+// DecodeReceipt parses an encoded receipt.
+func DecodeReceipt(s string) (*Receipt, error) {
+	if len(s) > maxReceiptBytes {
+		return nil, fmt.Errorf("receipt exceeds %d bytes", maxReceiptBytes)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decoding receipt: %w", err)
+	}
+	var r Receipt
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, fmt.Errorf("parsing receipt: %w", err)
+	}
+	if r.Version != receiptVersion {
+		return nil, fmt.Errorf("unsupported receipt version %d", r.Version)
+	}
+	switch r.Kind {
+	case ReceiptCommitment, ReceiptPromise:
+	default:
+		return nil, fmt.Errorf("unknown receipt kind %q", r.Kind)
+	}
+	if r.EventID == "" || r.RunID == "" {
+		return nil, fmt.Errorf("receipt has no event or run ID")
+	}
+	return &r, nil
+}
+
+// committedEvent is where a persisted event landed in the chain.
+type committedEvent struct {
+	seq  uint64
+	hash string
+}
+
+// commitLog remembers the position of recently persisted events, so receipts can
+// commit to them without reading the database. Oldest entries are evicted first.
+type commitLog struct {
+	mu     sync.Mutex
+	events map[string]committedEvent
+	order  [maxCommittedKeep]string
+	next   int
+}
+
+func (c *commitLog) record(event *models.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.events == nil {
+		c.events = make(map[string]committedEvent, maxCommittedKeep)
+	}
+	if old := c.order[c.next]; old != "" {
+		delete(c.events, old)
+	}
+	c.order[c.next] = event.ID
+	c.next = (c.next + 1) % maxCommittedKeep
+	c.events[event.ID] = committedEvent{seq: event.SeqIndex, hash: event.CurrentHash}
+}
+
+func (c *commitLog) lookup(eventID string) (committedEvent, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.events[eventID]
+	return e, ok
+}
+
+// IssueReceipt signs a receipt for an event submitted to the worker: a commitment
+// once it is persisted, a promise while it is still queued. bodySHA256 is the
+// digest of the request body the event records, if any.
+func (w *Worker) IssueReceipt(eventID, bodySHA256 string) (*Receipt, error) {
+	if err := assert.NotNil(w, "worker"); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(eventID != "", "event id must not be empty"); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(w.runID != "", "worker must be started"); err != nil {
+		return nil, err
+	}
+	r := &Receipt{
+		Version:    receiptVersion,
+		Kind:       ReceiptPromise,
+		RunID:      w.runID,
+		EventID:    eventID,
+		BodySHA256: bodySHA256,
+		IssuedAt:   time.Now().UTC(),
+		PublicKey:  w.signer.GetPublicKey(),
+	}
+	if committed, ok := w.committed.lookup(eventID); ok {
+		r.Kind = ReceiptCommitment
+		r.Seq = committed.seq
+		r.EventHash = committed.hash
+	}
+	digest, err := r.Digest()
+	if err != nil {
+		return nil, err
+	}
+	if r.Signature, err = w.signer.SignHash(digest); err != nil {
+		return nil, fmt.Errorf("signing receipt: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,66 @@
+package ledger
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/models"
+)
+
+func TestReceiptPromiseBecomesCommitment(t *testing.T) {
+	worker, cleanup := newTestWorker(t, 4)
+	defer cleanup()
+	worker.runID = "run-receipts"
+
+	promise, err := worker.IssueReceipt("evt-1", "abc123")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if promise.Kind != ReceiptPromise || promise.EventHash != "" {
+		t.Fatalf("expected a promise for a queued event, got %+v", promise)
+	}
+	if err := promise.VerifySignature(); err != nil {
+		t.Fatalf("promise signature: %v", err)
+	}
+
+	worker.committed.record(&models.Event{ID: "evt-1", SeqIndex: 7, CurrentHash: "deadbeef"})
+	commitment, err := worker.IssueReceipt("evt-1", "abc123")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if commitment.Kind != ReceiptCommitment || commitment.Seq != 7 || commitment.EventHash != "deadbeef" {
+		t.Fatalf("expected a commitment to seq 7, got %+v", commitment)
+	}
+
+	encoded, err := commitment.Encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	decoded, err := DecodeReceipt(encoded)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if err := decoded.VerifySignature(); err != nil {
+		t.Fatalf("decoded signature: %v", err)
+	}
+	decoded.Seq = 8
+	if err := decoded.VerifySignature(); err == nil {
+		t.Fatal("expected a tampered receipt to fail verification")
+	}
+}
+
+func TestCommitLogEvictsOldest(t *testing.T) {
+	var committed commitLog
+	for i := 0; i <= maxCommittedKeep; i++ {
+		committed.record(&models.Event{ID: fmt.Sprintf("evt-%d", i), SeqIndex: uint64(i)})
+	}
+	if _, ok := committed.lookup("evt-0"); ok {
+		t.Error("expected the oldest event to be evicted")
+	}
+	if e, ok := committed.lookup(fmt.Sprintf("evt-%d", maxCommittedKeep)); !ok || e.seq != maxCommittedKeep {
+		t.Errorf("expected the newest event to be kept, got %+v %v", e, ok)
+	}
+	if len(committed.events) != maxCommittedKeep {
+		t.Errorf("expected %d entries, got %d", maxCommittedKeep, len(committed.events))
+	}
+}
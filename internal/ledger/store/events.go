@@ -13,8 +13,50 @@ import (
 
 const maxEventRows = 100000
 
+// eventColumns is the column list shared by every events SELECT and INSERT.
+// Its order must match eventRow.values() and scanEvent.
+const eventColumns = `id, run_id, seq_index, timestamp, actor, event_type, method, params, response,
+		task_id, task_state, parent_id, policy_id, risk_level, prev_hash, current_hash, signature,
+		batch_id, latency_ms, http_status, upstream, http_meta,
+		session_id, body_size, body_sha256, body_storage, trace_id, span_id, blob_refs`
+
+const eventPlaceholders = `?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?`
+
+// eventRow is the flattened, SQL-ready form of a models.Event.
+type eventRow struct {
+	id, runID                           string
+	seqIndex                            uint64
+	timestamp, actor, eventType, method string
+	params, response                    string
+	taskID, taskState, parentID         string
+	policyID, riskLevel                 string
+	prevHash, currentHash, signature    string
+	batchID                             string
+	latencyMs                           int64
+	httpStatus                          int
+	upstream                            string
+	httpMeta                            string
+	sessionID                           string
+	bodySize                            int64
+	bodySHA256, bodyStorage             string
+	traceID, spanID                     string
+	blobRefs                            string
+}
+
+func (r *eventRow) values() []interface{} {
+	return []interface{}{
+		r.id, r.runID, r.seqIndex, r.timestamp, r.actor, r.eventType, r.method, r.params, r.response,
+		r.taskID, r.taskState, r.parentID, r.policyID, r.riskLevel, r.prevHash, r.currentHash, r.signature,
+		r.batchID, r.latencyMs, r.httpStatus, r.upstream, r.httpMeta,
+		r.sessionID, r.bodySize, r.bodySHA256, r.bodyStorage, r.traceID, r.spanID, r.blobRefs,
+	}
+}
+
 // StoreEvent persists a models.Event to the ledger, unpacking it for the SQL query
 func (db *DB) StoreEvent(event *models.Event) error {
+	if err := assert.NotNil(event, "event"); err != nil {
+		return err
+	}
 	paramsBytes, err := json.Marshal(event.Params)
 	if err != nil {
 		return fmt.Errorf("marshaling params: %w", err)
@@ -23,52 +65,86 @@ func (db *DB) StoreEvent(event *models.Event) error {
 	if err != nil {
 		return fmt.Errorf("marshaling response: %w", err)
 	}
+	httpMeta, err := optionalJSON(event.HTTP, event.HTTP != nil)
+	if err != nil {
+		return fmt.Errorf("marshaling http metadata: %w", err)
+	}
+	blobRefs, err := optionalJSON(event.BlobRefs, len(event.BlobRefs) > 0)
+	if err != nil {
+		return fmt.Errorf("marshaling blob refs: %w", err)
+	}
+
+	return db.insertRow(&eventRow{
+		id:          event.ID,
+		runID:       event.RunID,
+		seqIndex:    event.SeqIndex,
+		timestamp:   event.Timestamp.Format(time.RFC3339Nano),
+		actor:       event.Actor,
+		eventType:   event.EventType,
+		method:      event.Method,
+		params:      string(paramsBytes),
+		response:    string(responseBytes),
+		taskID:      event.TaskID,
+		taskState:   event.TaskState,
+		parentID:    event.ParentID,
+		policyID:    event.PolicyID,
+		riskLevel:   event.RiskLevel,
+		prevHash:    event.PrevHash,
+		currentHash: event.CurrentHash,
+		signature:   event.Signature,
+		batchID:     event.BatchID,
+		latencyMs:   event.LatencyMs,
+		httpStatus:  event.HTTPStatus,
+		upstream:    event.Upstream,
+		httpMeta:    httpMeta,
+		sessionID:   event.SessionID,
+		bodySize:    event.BodySize,
+		bodySHA256:  event.BodySHA256,
+		bodyStorage: event.BodyStorage,
+		traceID:     event.TraceID,
+		spanID:      event.SpanID,
+		blobRefs:    blobRefs,
+	})
+}
 
-	return db.InsertEvent(
-		event.ID,
-		event.RunID,
-		event.SeqIndex,
-		event.Timestamp.Format(time.RFC3339Nano),
-		event.Actor,
-		event.EventType,
-		event.Method,
-		string(paramsBytes),
-		string(responseBytes),
-		event.TaskID,
-		event.TaskState,
-		event.ParentID,
-		event.PolicyID,
-		event.RiskLevel,
-		event.PrevHash,
-		event.CurrentHash,
-		event.Signature,
-	)
+// optionalJSON encodes an optional event column: v as JSON when present, or ""
+// (stored as absent) when not.
+func optionalJSON(v interface{}, present bool) (string, error) {
+	if !present {
+		return "", nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
 }
 
 // InsertEvent inserts a new event into the ledger
 func (db *DB) InsertEvent(id, runID string, seqIndex uint64, timestamp, actor, eventType, method, params, response, taskID, taskState, parentID, policyID, riskLevel, prevHash, currentHash, signature string) error {
-	if err := assert.Check(id != "", "event id must not be empty"); err != nil {
+	return db.insertRow(&eventRow{
+		id: id, runID: runID, seqIndex: seqIndex, timestamp: timestamp, actor: actor,
+		eventType: eventType, method: method, params: params, response: response,
+		taskID: taskID, taskState: taskState, parentID: parentID, policyID: policyID,
+		riskLevel: riskLevel, prevHash: prevHash, currentHash: currentHash, signature: signature,
+	})
+}
+
+func (db *DB) insertRow(row *eventRow) error {
+	if err := assert.Check(row.id != "", "event id must not be empty"); err != nil {
 		return err
 	}
-	if err := assert.Check(runID != "", "run id must not be empty"); err != nil {
+	if err := assert.Check(row.runID != "", "run id must not be empty"); err != nil {
 		return err
 	}
-	if err := assert.Check(currentHash != "", "current hash must not be empty"); err != nil {
+	if err := assert.Check(row.currentHash != "", "current hash must not be empty"); err != nil {
 		return err
 	}
-	if err := assert.Check(signature != "", "signature must not be empty"); err != nil {
+	if err := assert.Check(row.signature != "", "signature must not be empty"); err != nil {
 		return err
 	}
-	query := `
-		INSERT INTO events (
-			id, run_id, seq_index, timestamp, actor, event_type, method, params, response,
-			task_id, task_state, parent_id, policy_id, risk_level, prev_hash, current_hash, signature
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-	res, err := db.conn.Exec(query,
-		id, runID, seqIndex, timestamp, actor, eventType, method, params, response,
-		taskID, taskState, parentID, policyID, riskLevel, prevHash, currentHash, signature,
-	)
+	query := `INSERT INTO events (` + eventColumns + `) VALUES (` + eventPlaceholders + `)`
+	res, err := db.conn.Exec(query, row.values()...)
 	if err != nil {
 		return fmt.Errorf("inserting event: %w", err)
 	}
@@ -79,44 +155,83 @@ func (db *DB) InsertEvent(id, runID string, seqIndex uint64, timestamp, actor, e
 	return nil
 }
 
-// GetLastEvent retrieves the most recent event for a given run
-func (db *DB) GetLastEvent(runID string) (seqIndex uint64, currentHash string, err error) {
-	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
-		return 0, "", err
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanEvent reads one row selected with eventColumns into a models.Event,
+// decoding the timestamp and JSON payload columns.
+func scanEvent(row rowScanner) (models.Event, error) {
+	var e models.Event
+	var timestamp, params, response, httpMeta, blobRefs string
+
+	err := row.Scan(
+		&e.ID, &e.RunID, &e.SeqIndex, &timestamp, &e.Actor, &e.EventType, &e.Method,
+		&params, &response, &e.TaskID, &e.TaskState, &e.ParentID, &e.PolicyID, &e.RiskLevel,
+		&e.PrevHash, &e.CurrentHash, &e.Signature, &e.BatchID, &e.LatencyMs, &e.HTTPStatus, &e.Upstream, &httpMeta,
+		&e.SessionID, &e.BodySize, &e.BodySHA256, &e.BodyStorage, &e.TraceID, &e.SpanID, &blobRefs,
+	)
+	if err != nil {
+		return e, err
 	}
 
-	query := `SELECT seq_index, current_hash FROM events WHERE run_id = ? ORDER BY seq_index DESC LIMIT 1`
-	err = db.conn.QueryRow(query, runID).Scan(&seqIndex, &currentHash)
-	if err == sql.ErrNoRows {
-		return 0, "", nil
+	// Parse timestamp
+	if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+		e.Timestamp = t
 	}
-	if err != nil {
-		return 0, "", fmt.Errorf("querying last event: %w", err)
+	// WasBlocked is not a column: the event type already records it.
+	e.WasBlocked = e.EventType == "blocked"
+
+	// Parse JSON fields
+	if params != "" && params != "null" {
+		var paramsMap map[string]interface{}
+		if err := json.Unmarshal([]byte(params), &paramsMap); err != nil {
+			log.Printf("Warning: failed to unmarshal params for event %s: %v", e.ID, err)
+		} else {
+			e.Params = paramsMap
+		}
 	}
-	return seqIndex, currentHash, nil
+	if response != "" && response != "null" {
+		var responseMap map[string]interface{}
+		if err := json.Unmarshal([]byte(response), &responseMap); err != nil {
+			log.Printf("Warning: failed to unmarshal response for event %s: %v", e.ID, err)
+		} else {
+			e.Response = responseMap
+		}
+	}
+	if httpMeta != "" {
+		var meta models.HTTPMetadata
+		if err := json.Unmarshal([]byte(httpMeta), &meta); err != nil {
+			log.Printf("Warning: failed to unmarshal http metadata for event %s: %v", e.ID, err)
+		} else {
+			e.HTTP = &meta
+		}
+	}
+	if blobRefs != "" {
+		if err := json.Unmarshal([]byte(blobRefs), &e.BlobRefs); err != nil {
+			log.Printf("Warning: failed to unmarshal blob refs for event %s: %v", e.ID, err)
+		}
+	}
+	return e, nil
 }
 
-// GetAllEvents retrieves all events for a run, ordered by sequence
-func (db *DB) GetAllEvents(runID string) (events []models.Event, err error) {
-	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+// queryEvents runs a SELECT over eventColumns and scans up to maxEventRows rows.
+func (db *DB) queryEvents(label, where string, args ...interface{}) (events []models.Event, err error) {
+	if err := assert.Check(label != "", "query label must not be empty"); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(where != "", "query clause must not be empty"); err != nil {
 		return nil, err
 	}
 
-	query := `
-		SELECT id, run_id, seq_index, timestamp, actor, event_type, method, 
-		       params, response, task_id, task_state, parent_id, policy_id, risk_level, prev_hash, current_hash, signature
-		FROM events 
-		WHERE run_id = ? 
-		ORDER BY seq_index ASC
-	`
-
-	rows, err := db.conn.Query(query, runID)
+	rows, err := db.conn.Query(`SELECT `+eventColumns+` FROM events `+where, args...)
 	if err != nil {
-		return nil, fmt.Errorf("querying events: %w", err)
+		return nil, fmt.Errorf("querying %s: %w", label, err)
 	}
 	defer func() {
 		if closeErr := rows.Close(); closeErr != nil && err == nil {
-			err = fmt.Errorf("closing events rows: %w", closeErr)
+			err = fmt.Errorf("closing %s rows: %w", label, closeErr)
 		}
 	}()
 
@@ -124,113 +239,53 @@ func (db *DB) GetAllEvents(runID string) (events []models.Event, err error) {
 		if !rows.Next() {
 			break
 		}
-		var e models.Event
-		var timestamp, params, response, taskID, taskState, parentID, policyID, riskLevel string
-
-		err := rows.Scan(
-			&e.ID, &e.RunID, &e.SeqIndex, &timestamp, &e.Actor, &e.EventType, &e.Method,
-			&params, &response, &taskID, &taskState, &parentID, &policyID, &riskLevel, &e.PrevHash, &e.CurrentHash, &e.Signature,
-		)
+		e, err := scanEvent(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scanning event: %w", err)
 		}
-
-		e.ParentID = parentID
-		e.PolicyID = policyID
-		e.RiskLevel = riskLevel
-
-		// Parse timestamp
-		t, err := time.Parse(time.RFC3339Nano, timestamp)
-		if err == nil {
-			e.Timestamp = t
-		}
-		e.TaskID = taskID
-		e.TaskState = taskState
-
-		// Parse JSON fields
-		if params != "" && params != "null" {
-			var paramsMap map[string]interface{}
-			if err := json.Unmarshal([]byte(params), &paramsMap); err != nil {
-				log.Printf("Warning: failed to unmarshal params for event %s: %v", e.ID, err)
-			} else {
-				e.Params = paramsMap
-			}
-		}
-
-		if response != "" && response != "null" {
-			var responseMap map[string]interface{}
-			if err := json.Unmarshal([]byte(response), &responseMap); err != nil {
-				log.Printf("Warning: failed to unmarshal response for event %s: %v", e.ID, err)
-			} else {
-				e.Response = responseMap
-			}
-		}
-
 		events = append(events, e)
 	}
 
-	if err := assert.Check(rows.Err() == nil, "get all events rows error: %v", rows.Err()); err != nil {
+	if err := assert.Check(rows.Err() == nil, "%s rows error: %v", label, rows.Err()); err != nil {
 		return nil, err
 	}
 	return events, nil
 }
 
-// GetRecentEvents retrieves the N most recent events
-func (db *DB) GetRecentEvents(runID string, limit int) (events []models.Event, err error) {
+// GetLastEvent retrieves the most recent event for a given run
+func (db *DB) GetLastEvent(runID string) (seqIndex uint64, currentHash string, err error) {
 	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
-		return nil, err
-	}
-	if err := assert.Check(limit > 0, "limit must be positive"); err != nil {
-		return nil, err
+		return 0, "", err
 	}
 
-	query := `
-		SELECT id, run_id, seq_index, timestamp, actor, event_type, method, 
-		       params, response, task_id, task_state, parent_id, policy_id, risk_level, prev_hash, current_hash, signature
-		FROM events 
-		WHERE run_id = ? 
-		ORDER BY seq_index DESC 
-		LIMIT ?
-	`
-
-	rows, err := db.conn.Query(query, runID, limit)
+	query := `SELECT seq_index, current_hash FROM events WHERE run_id = ? ORDER BY seq_index DESC LIMIT 1`
+	err = db.conn.QueryRow(query, runID).Scan(&seqIndex, &currentHash)
+	if err == sql.ErrNoRows {
+		return 0, "", nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("querying recent events: %w", err)
+		return 0, "", fmt.Errorf("querying last event: %w", err)
 	}
-	defer func() {
-		if closeErr := rows.Close(); closeErr != nil && err == nil {
-			err = fmt.Errorf("closing recent events rows: %w", closeErr)
-		}
-	}()
-
-	for i := 0; i < maxEventRows; i++ {
-		if !rows.Next() {
-			break
-		}
-		var e models.Event
-		var timestamp, params, response, taskID, taskState, parentID, policyID, riskLevel string
-
-		err := rows.Scan(
-			&e.ID, &e.RunID, &e.SeqIndex, &timestamp, &e.Actor, &e.EventType, &e.Method,
-			&params, &response, &taskID, &taskState, &parentID, &policyID, &riskLevel, &e.PrevHash, &e.CurrentHash, &e.Signature,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("scanning event: %w", err)
-		}
-
-		e.TaskID = taskID
-		e.TaskState = taskState
-		e.ParentID = parentID
-		e.PolicyID = policyID
-		e.RiskLevel = riskLevel
+	return seqIndex, currentHash, nil
+}
 
-		events = append(events, e)
+// GetAllEvents retrieves all events for a run, ordered by sequence
+func (db *DB) GetAllEvents(runID string) ([]models.Event, error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
+		return nil, err
 	}
+	return db.queryEvents("events", `WHERE run_id = ? ORDER BY seq_index ASC`, runID)
+}
 
-	if err := assert.Check(rows.Err() == nil, "recent events rows error: %v", rows.Err()); err != nil {
+// GetRecentEvents retrieves the N most recent events
+func (db *DB) GetRecentEvents(runID string, limit int) ([]models.Event, error) {
+	if err := assert.Check(runID != "", "runID must not be empty"); err != nil {
 		return nil, err
 	}
-	return events, nil
+	if err := assert.Check(limit > 0, "limit must be positive"); err != nil {
+		return nil, err
+	}
+	return db.queryEvents("recent events", `WHERE run_id = ? ORDER BY seq_index DESC LIMIT ?`, runID, limit)
 }
 
 // GetEventByID retrieves a specific event by ID
@@ -239,127 +294,56 @@ func (db *DB) GetEventByID(eventID string) (*models.Event, error) {
 		return nil, err
 	}
 
-	query := `
-		SELECT id, run_id, seq_index, timestamp, actor, event_type, method, 
-		       params, response, task_id, task_state, parent_id, policy_id, risk_level, prev_hash, current_hash, signature
-		FROM events 
-		WHERE id = ?
-	`
-
-	var e models.Event
-	var timestamp, params, response, taskID, taskState, parentID, policyID, riskLevel string
-
-	err := db.conn.QueryRow(query, eventID).Scan(
-		&e.ID, &e.RunID, &e.SeqIndex, &timestamp, &e.Actor, &e.EventType, &e.Method,
-		&params, &response, &taskID, &taskState, &parentID, &policyID, &riskLevel, &e.PrevHash, &e.CurrentHash, &e.Signature,
-	)
+	row := db.conn.QueryRow(`SELECT `+eventColumns+` FROM events WHERE id = ?`, eventID)
+	e, err := scanEvent(row)
 	if err != nil {
 		return nil, fmt.Errorf("querying event: %w", err)
 	}
-
-	e.TaskID = taskID
-	e.TaskState = taskState
-	e.ParentID = parentID
-	e.PolicyID = policyID
-	e.RiskLevel = riskLevel
-
 	return &e, nil
 }
 
 // GetEventsByTaskID retrieves all events for a specific task
-func (db *DB) GetEventsByTaskID(taskID string) (events []models.Event, err error) {
+func (db *DB) GetEventsByTaskID(taskID string) ([]models.Event, error) {
 	if err := assert.Check(taskID != "", "taskID must not be empty"); err != nil {
 		return nil, err
 	}
-	query := `
-		SELECT id, run_id, seq_index, timestamp, actor, event_type, method, 
-		       params, response, task_id, task_state, parent_id, policy_id, risk_level, prev_hash, current_hash, signature
-		FROM events 
-		WHERE task_id = ? 
-		ORDER BY seq_index ASC
-	`
-	rows, err := db.conn.Query(query, taskID)
-	if err != nil {
-		return nil, fmt.Errorf("querying task events: %w", err)
-	}
-	defer func() {
-		if closeErr := rows.Close(); closeErr != nil && err == nil {
-			err = fmt.Errorf("closing task events rows: %w", closeErr)
-		}
-	}()
-
-	for i := 0; i < maxEventRows; i++ {
-		if !rows.Next() {
-			break
-		}
-		var e models.Event
-		var timestamp, params, response, tID, tState, parentID, policyID, riskLevel string
-
-		err := rows.Scan(
-			&e.ID, &e.RunID, &e.SeqIndex, &timestamp, &e.Actor, &e.EventType, &e.Method,
-			&params, &response, &tID, &tState, &parentID, &policyID, &riskLevel, &e.PrevHash, &e.CurrentHash, &e.Signature,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("scanning event: %w", err)
-		}
+	return db.queryEvents("task events", `WHERE task_id = ? ORDER BY seq_index ASC`, taskID)
+}
 
-		e.TaskID = tID
-		e.TaskState = tState
-		e.ParentID = parentID
-		e.PolicyID = policyID
-		e.RiskLevel = riskLevel
-		events = append(events, e)
-	}
-	if err := assert.Check(rows.Err() == nil, "task events rows error: %v", rows.Err()); err != nil {
+// GetEventsByBatchID retrieves all members of a JSON-RPC batch in ledger order
+func (db *DB) GetEventsByBatchID(batchID string) ([]models.Event, error) {
+	if err := assert.Check(batchID != "", "batchID must not be empty"); err != nil {
 		return nil, err
 	}
-	return events, nil
+	return db.queryEvents("batch events", `WHERE batch_id = ? ORDER BY seq_index ASC`, batchID)
 }
 
-// GetRiskEvents returns events with high or critical risk
-func (db *DB) GetRiskEvents() (events []models.Event, err error) {
-	query := `
-		SELECT id, run_id, seq_index, timestamp, actor, event_type, method, params, response,
-		       task_id, task_state, parent_id, policy_id, risk_level, prev_hash, current_hash, signature
-		FROM events 
-		WHERE risk_level IN ('high', 'critical')
-		ORDER BY timestamp DESC
-	`
-	rows, err := db.conn.Query(query)
-	if err != nil {
+// GetEventsBySessionID retrieves all events of one MCP session in ledger order
+func (db *DB) GetEventsBySessionID(sessionID string) ([]models.Event, error) {
+	if err := assert.Check(sessionID != "", "sessionID must not be empty"); err != nil {
 		return nil, err
 	}
-	defer func() {
-		if closeErr := rows.Close(); closeErr != nil && err == nil {
-			err = fmt.Errorf("closing risk events rows: %w", closeErr)
-		}
-	}()
+	return db.queryEvents("session events", `WHERE session_id = ? ORDER BY seq_index ASC`, sessionID)
+}
 
-	for i := 0; i < maxEventRows; i++ {
-		if !rows.Next() {
-			break
-		}
-		var e models.Event
-		var timestamp, params, response, taskID, taskState, parentID, policyID, riskLevel string
+// GetSessionStarts returns the session_started event of every recorded session, newest first
+func (db *DB) GetSessionStarts() ([]models.Event, error) {
+	return db.queryEvents("session starts", `WHERE event_type = 'session_started' ORDER BY seq_index DESC`)
+}
 
-		err := rows.Scan(
-			&e.ID, &e.RunID, &e.SeqIndex, &timestamp, &e.Actor, &e.EventType, &e.Method,
-			&params, &response, &taskID, &taskState, &parentID, &policyID, &riskLevel, &e.PrevHash, &e.CurrentHash, &e.Signature,
-		)
-		if err != nil {
-			return nil, err
-		}
+// GetRiskEvents returns events with high or critical risk
+func (db *DB) GetRiskEvents() ([]models.Event, error) {
+	return db.queryEvents("risk events", `WHERE risk_level IN ('high', 'critical') ORDER BY timestamp DESC`)
+}
 
-		e.ParentID = parentID
-		e.PolicyID = policyID
-		e.RiskLevel = riskLevel
-		events = append(events, e)
-	}
+// GetErrorEvents returns JSON-RPC error responses (tool_error events), newest first
+func (db *DB) GetErrorEvents() ([]models.Event, error) {
+	return db.queryEvents("error events", `WHERE event_type = 'tool_error' ORDER BY timestamp DESC`)
+}
 
-	if err := assert.Check(rows.Err() == nil, "risk events rows error: %v", rows.Err()); err != nil {
-		return nil, err
-	}
-	return events, nil
+// GetShadowResponses returns the shadow_response events of mirrored calls, newest first
+func (db *DB) GetShadowResponses() ([]models.Event, error) {
+	return db.queryEvents("shadow responses", `WHERE event_type = 'shadow_response' ORDER BY seq_index DESC`)
 }
 
 // GetUniqueTasks returns all unique task IDs in the ledger
@@ -12,8 +12,8 @@ CREATE TABLE IF NOT EXISTS events (
     run_id TEXT,
     seq_index INTEGER,
     timestamp TEXT,
-    actor TEXT,          -- agent | user | system
-    event_type TEXT,     -- tool_call | tool_response | task_started | task_completed | blocked | genesis
+    actor TEXT,          -- agent identity | user | system
+    event_type TEXT,     -- tool_call | tool_response | tool_error | notification | session_started | session_ended | server_request | approval_decision | task_started | task_completed | blocked | genesis
     method TEXT,
     params TEXT,         -- JSON string
     response TEXT,       -- JSON string
@@ -25,6 +25,18 @@ CREATE TABLE IF NOT EXISTS events (
     prev_hash TEXT,
     current_hash TEXT,
     signature TEXT,
+    batch_id TEXT NOT NULL DEFAULT '', -- Shared ID of a JSON-RPC batch (if any)
+    latency_ms INTEGER NOT NULL DEFAULT 0, -- Round-trip time of the call a response answers
+    http_status INTEGER NOT NULL DEFAULT 0, -- Upstream HTTP status (0 for stdio)
+    upstream TEXT NOT NULL DEFAULT '', -- Routed tool server name (multi-upstream proxy)
+    http_meta TEXT NOT NULL DEFAULT '', -- JSON: client address, path, allowlisted headers
+    session_id TEXT NOT NULL DEFAULT '', -- MCP session (opened by initialize)
+    body_size INTEGER NOT NULL DEFAULT 0, -- Size of the complete request body
+    body_sha256 TEXT NOT NULL DEFAULT '', -- SHA-256 of the complete request body
+    body_storage TEXT NOT NULL DEFAULT '', -- '', truncated, offloaded or omitted (params not complete)
+    trace_id TEXT NOT NULL DEFAULT '', -- W3C trace ID of the HTTP exchange
+    span_id TEXT NOT NULL DEFAULT '', -- Logryph span ID, sent upstream as the traceparent parent
+    blob_refs TEXT NOT NULL DEFAULT '', -- JSON: digests of response values moved to the blob store
     FOREIGN KEY(run_id) REFERENCES runs(id)
 );
 
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/slyt3/Logryph/internal/assert"
 )
 
 //go:embed schema.sql
@@ -48,9 +49,95 @@ func NewDB(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("executing schema: %w", err)
 	}
 
+	// Add columns introduced after the initial schema to existing ledgers
+	if err := migrateEvents(conn); err != nil {
+		if closeErr := conn.Close(); closeErr != nil {
+			return nil, fmt.Errorf("migrating schema: %v; closing database: %w", err, closeErr)
+		}
+		return nil, fmt.Errorf("migrating schema: %w", err)
+	}
+
 	return &DB{conn: conn}, nil
 }
 
+// eventMigrations lists columns added to the events table after the initial release.
+// CREATE TABLE IF NOT EXISTS leaves older ledgers untouched, so these are applied
+// with ALTER TABLE when missing. Definitions must carry a default for existing rows.
+var eventMigrations = []struct {
+	column     string
+	definition string
+}{
+	{"batch_id", "TEXT NOT NULL DEFAULT ''"},
+	{"latency_ms", "INTEGER NOT NULL DEFAULT 0"},
+	{"http_status", "INTEGER NOT NULL DEFAULT 0"},
+	{"upstream", "TEXT NOT NULL DEFAULT ''"},
+	{"http_meta", "TEXT NOT NULL DEFAULT ''"},
+	{"session_id", "TEXT NOT NULL DEFAULT ''"},
+	{"body_size", "INTEGER NOT NULL DEFAULT 0"},
+	{"body_sha256", "TEXT NOT NULL DEFAULT ''"},
+	{"body_storage", "TEXT NOT NULL DEFAULT ''"},
+	{"trace_id", "TEXT NOT NULL DEFAULT ''"},
+	{"span_id", "TEXT NOT NULL DEFAULT ''"},
+	{"blob_refs", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// migrateEvents adds any missing eventMigrations columns to the events table.
+func migrateEvents(conn *sql.DB) error {
+	if err := assert.NotNil(conn, "connection"); err != nil {
+		return err
+	}
+	existing, err := tableColumns(conn, "events")
+	if err != nil {
+		return err
+	}
+	if err := assert.Check(len(existing) > 0, "events table has no columns"); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(eventMigrations); i++ {
+		m := eventMigrations[i]
+		if existing[m.column] {
+			continue
+		}
+		if _, err := conn.Exec(fmt.Sprintf("ALTER TABLE events ADD COLUMN %s %s", m.column, m.definition)); err != nil {
+			return fmt.Errorf("adding column %s: %w", m.column, err)
+		}
+	}
+	return nil
+}
+
+// tableColumns returns the set of column names of a table.
+func tableColumns(conn *sql.DB, table string) (cols map[string]bool, err error) {
+	if err := assert.Check(table != "", "table name must not be empty"); err != nil {
+		return nil, err
+	}
+	rows, err := conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s columns: %w", table, err)
+	}
+	defer func() {
+		if closeErr := rows.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("closing %s columns rows: %w", table, closeErr)
+		}
+	}()
+
+	const maxColumns = 256
+	cols = make(map[string]bool)
+	for i := 0; i < maxColumns; i++ {
+		if !rows.Next() {
+			break
+		}
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return nil, fmt.Errorf("scanning %s columns: %w", table, err)
+		}
+		cols[name] = true
+	}
+	return cols, rows.Err()
+}
+
 // Close closes the database connection
 func (db *DB) Close() error {
 	return db.conn.Close()
@@ -1,10 +1,13 @@
 package store
 
 import (
+	"database/sql"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/slyt3/Logryph/internal/models"
 )
 
 func TestDB(t *testing.T) {
@@ -124,3 +127,60 @@ func TestDB(t *testing.T) {
 		t.Errorf("Expected event ID %s, got %s", eventID, event.ID)
 	}
 }
+
+func TestMigrateLegacyEventsTable(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	// Create a ledger with the original events table (no batch_id column)
+	legacy, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	_, err = legacy.Exec(`CREATE TABLE events (
+		id TEXT PRIMARY KEY, run_id TEXT, seq_index INTEGER, timestamp TEXT, actor TEXT,
+		event_type TEXT, method TEXT, params TEXT, response TEXT, task_id TEXT, task_state TEXT,
+		parent_id TEXT, policy_id TEXT, risk_level TEXT, prev_hash TEXT, current_hash TEXT, signature TEXT)`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+	_, err = legacy.Exec(`INSERT INTO events VALUES ('old-1', 'run-1', 0, '', 'system', 'genesis', 'logryph:init', '{}', 'null', '', '', '', '', '', '0', 'h0', 's0')`)
+	if err != nil {
+		t.Fatalf("Failed to insert legacy event: %v", err)
+	}
+	if err := legacy.Close(); err != nil {
+		t.Fatalf("Failed to close legacy database: %v", err)
+	}
+
+	db, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB failed to migrate legacy ledger: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("Failed to close database: %v", err)
+		}
+	})
+
+	old, err := db.GetEventByID("old-1")
+	if err != nil {
+		t.Fatalf("GetEventByID on legacy row failed: %v", err)
+	}
+	if old.BatchID != "" {
+		t.Errorf("Expected empty batch ID for legacy row, got %q", old.BatchID)
+	}
+
+	err = db.StoreEvent(&models.Event{
+		ID: "new-1", RunID: "run-1", SeqIndex: 1, Timestamp: time.Now(), EventType: "tool_call",
+		Method: "tools/call", BatchID: "batch-1", PrevHash: "h0", CurrentHash: "h1", Signature: "s1",
+	})
+	if err != nil {
+		t.Fatalf("StoreEvent failed: %v", err)
+	}
+	members, err := db.GetEventsByBatchID("batch-1")
+	if err != nil {
+		t.Fatalf("GetEventsByBatchID failed: %v", err)
+	}
+	if len(members) != 1 || members[0].ID != "new-1" {
+		t.Errorf("Expected batch to contain new-1, got %+v", members)
+	}
+}
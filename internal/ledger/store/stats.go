@@ -17,12 +17,13 @@ func (db *DB) GetRunStats(runID string) (stats *ledger.RunStats, err error) {
 		RiskBreakdown: make(map[string]int),
 	}
 
-	// Total and Blocked counts
+	// Total, Blocked and Error counts
 	err = db.conn.QueryRow(`
 		SELECT COUNT(*), 
 		       COALESCE(SUM(CASE WHEN event_type = 'blocked' THEN 1 ELSE 0 END), 0),
-		       COALESCE(SUM(CASE WHEN event_type = 'tool_call' THEN 1 ELSE 0 END), 0)
-		FROM events WHERE run_id = ?`, runID).Scan(&stats.TotalEvents, &stats.BlockedCount, &stats.CallCount)
+		       COALESCE(SUM(CASE WHEN event_type = 'tool_call' THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN event_type = 'tool_error' THEN 1 ELSE 0 END), 0)
+		FROM events WHERE run_id = ?`, runID).Scan(&stats.TotalEvents, &stats.BlockedCount, &stats.CallCount, &stats.ErrorCount)
 	if err != nil {
 		return nil, err
 	}
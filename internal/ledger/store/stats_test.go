@@ -43,6 +43,8 @@ func TestStats(t *testing.T) {
 	_ = db.InsertEvent("e2", runID, 2, now, "agent", "tool_call", "aws:ec2:terminate", "{}", "{}", "", "", "", "p2", "high", "h1", "h2", "s2")
 	// 4. blocked event
 	_ = db.InsertEvent("e3", runID, 3, now, "agent", "blocked", "aws:ec2:terminate", "{}", "{}", "", "", "", "p2", "high", "h2", "h3", "s3")
+	// 5. JSON-RPC error response
+	_ = db.InsertEvent("e4", runID, 4, now, "agent", "tool_error", "tools/call", "{}", `{"code":-32001,"message":"permission denied"}`, "", "", "e1", "", "", "h3", "h4", "s4")
 
 	// Test GetRunStats
 	stats, err := db.GetRunStats(runID)
@@ -50,8 +52,11 @@ func TestStats(t *testing.T) {
 		t.Fatalf("GetRunStats failed: %v", err)
 	}
 
-	if stats.TotalEvents != 4 {
-		t.Errorf("Expected 4 total events, got %d", stats.TotalEvents)
+	if stats.TotalEvents != 5 {
+		t.Errorf("Expected 5 total events, got %d", stats.TotalEvents)
+	}
+	if stats.ErrorCount != 1 {
+		t.Errorf("Expected 1 error event, got %d", stats.ErrorCount)
 	}
 	if stats.BlockedCount != 1 {
 		t.Errorf("Expected 1 blocked event, got %d", stats.BlockedCount)
@@ -77,4 +82,13 @@ func TestStats(t *testing.T) {
 	if len(risky) != 2 { // e2 and e3 are high
 		t.Errorf("Expected 2 risky events, got %d", len(risky))
 	}
+
+	// Test GetErrorEvents
+	errorEvents, err := db.GetErrorEvents()
+	if err != nil {
+		t.Fatalf("GetErrorEvents failed: %v", err)
+	}
+	if len(errorEvents) != 1 || errorEvents[0].Response["message"] != "permission denied" {
+		t.Errorf("Expected 1 error event with its message, got %+v", errorEvents)
+	}
 }
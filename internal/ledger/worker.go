@@ -40,12 +40,15 @@ type Worker struct {
 	processor        *EventProcessor
 	backpressureMode BackpressureMode
 	isUnhealthy      atomic.Bool   // Health sentinel
+	unhealthySince   atomic.Int64  // Unix nanoseconds of the first failed write
+	failedEvents     atomic.Uint64 // Events lost since the ledger became unhealthy
 	processedEvents  atomic.Uint64 // Metrics
 	droppedEvents    atomic.Uint64 // Metrics
 	blockedSubmits   atomic.Uint64 // Count of blocked Submit() calls
 	latencySumNs     atomic.Uint64 // Latency sum (ns)
 	latencyCount     atomic.Uint64 // Latency count
 	latencyBuckets   [maxLatencyBuckets]atomic.Uint64
+	committed        commitLog   // Recently persisted events, for receipts
 	closing          atomic.Bool // Shutdown sentinel
 	wg               sync.WaitGroup
 	shutdownOnce     sync.Once
@@ -57,8 +60,16 @@ const (
 	maxDrainEvents    = 1 << 20
 	maxShutdownTicks  = 1 << 12
 	maxLatencyBuckets = 7
+	maxRecoveryTicks  = 1 << 30
 )
 
+// EventLedgerRecovered is the system event that probes an unhealthy ledger. The
+// worker is healthy again once one is persisted; its params record the outage.
+const EventLedgerRecovered = "ledger_recovered"
+
+// recoveryProbeInterval is how often an unhealthy worker retries a write.
+var recoveryProbeInterval = 5 * time.Second
+
 var latencyBucketUpperNs = [maxLatencyBuckets]uint64{
 	1 * uint64(time.Millisecond),
 	5 * uint64(time.Millisecond),
@@ -163,6 +174,9 @@ func (w *Worker) GetDB() EventRepository {
 	return w.db
 }
 
+// IsHealthy reports whether the last write to the ledger succeeded. A worker
+// that failed to persist an event stays unhealthy until a recovery probe is
+// written.
 func (w *Worker) IsHealthy() bool {
 	if err := assert.Check(w != nil, "worker handle is nil"); err != nil {
 		return false
@@ -218,22 +232,30 @@ func (w *Worker) Start() error {
 		w.anchorLoop()
 	}()
 
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.recoveryLoop()
+	}()
+
 	return nil
 }
 
-// Submit sends an event to the worker for processing (non-blocking)
-func (w *Worker) Submit(event *models.Event) {
+// Submit sends an event to the worker for processing (non-blocking). Reports
+// whether the event was queued: it is dropped during shutdown, on backpressure
+// and when the ring buffer rejects it, and a dropped event must not be receipted.
+func (w *Worker) Submit(event *models.Event) bool {
 	// Check preconditions
 	if err := assert.NotNil(event, "event"); err != nil {
-		return
+		return false
 	}
 	if err := assert.NotNil(w.ringBuffer, "ring buffer"); err != nil {
-		return
+		return false
 	}
 	if w.closing.Load() {
 		w.droppedEvents.Add(1)
 		logging.Warn("event_dropped_shutdown", logging.Fields{Component: "worker", EventID: event.ID, TaskID: event.TaskID})
-		return
+		return false
 	}
 
 	// Backpressure handling based on configured mode
@@ -247,7 +269,7 @@ func (w *Worker) Submit(event *models.Event) {
 			if w.closing.Load() {
 				w.droppedEvents.Add(1)
 				logging.Warn("event_dropped_shutdown_blocking", logging.Fields{Component: "worker", EventID: event.ID})
-				return
+				return false
 			}
 			w.blockedSubmits.Add(1)
 			time.Sleep(1 * time.Millisecond)
@@ -255,20 +277,20 @@ func (w *Worker) Submit(event *models.Event) {
 		if w.ringBuffer.IsFull() {
 			w.droppedEvents.Add(1)
 			logging.Error("event_dropped_block_timeout", logging.Fields{Component: "worker", EventID: event.ID})
-			return
+			return false
 		}
 	} else {
 		// Drop mode: fail-open, drop event if buffer full
 		if w.ringBuffer.IsFull() {
 			w.droppedEvents.Add(1)
 			logging.Warn("event_dropped_backpressure", logging.Fields{Component: "worker", EventID: event.ID, TaskID: event.TaskID})
-			return
+			return false
 		}
 	}
 
 	if err := w.ringBuffer.Push(event); err != nil {
 		logging.Error("ring_buffer_push_failed", logging.Fields{Component: "worker", Error: err.Error()})
-		return
+		return false
 	}
 
 	// Notify worker (non-blocking send)
@@ -277,6 +299,7 @@ func (w *Worker) Submit(event *models.Event) {
 	default:
 		// Already signaled
 	}
+	return true
 }
 
 // Stats returns worker performance metrics
@@ -413,14 +436,7 @@ func (w *Worker) drainBuffer() error {
 		if err != nil {
 			break
 		}
-		start := time.Now()
-		if err := w.processor.ProcessEvent(event); err != nil {
-			logging.Critical("event_processing_failed", logging.Fields{Component: "worker", EventID: event.ID, TaskID: event.TaskID, Error: err.Error()})
-			w.isUnhealthy.Store(true)
-		}
-		w.recordLatency(time.Since(start))
-		w.processedEvents.Add(1)
-		pool.PutEvent(event)
+		w.process(event)
 	}
 	return nil
 }
@@ -462,6 +478,74 @@ func (w *Worker) anchorLoop() {
 	}
 }
 
+// process persists one event and updates health and metrics. A failed write
+// marks the worker unhealthy; only a persisted recovery probe clears it, so the
+// outage is recorded in the chain before normal traffic resumes.
+func (w *Worker) process(event *models.Event) {
+	start := time.Now()
+	probe := event.EventType == EventLedgerRecovered
+	if err := w.processor.ProcessEvent(event); err != nil {
+		if probe {
+			logging.Warn("ledger_probe_failed", logging.Fields{Component: "worker", Error: err.Error()})
+		} else {
+			logging.Critical("event_processing_failed", logging.Fields{Component: "worker", EventID: event.ID, TaskID: event.TaskID, Error: err.Error()})
+			w.failedEvents.Add(1)
+			if w.isUnhealthy.CompareAndSwap(false, true) {
+				w.unhealthySince.Store(start.UnixNano())
+			}
+		}
+	} else {
+		w.committed.record(event)
+		if probe && w.isUnhealthy.CompareAndSwap(true, false) {
+			w.failedEvents.Store(0)
+			w.unhealthySince.Store(0)
+			logging.Info("ledger_recovered", logging.Fields{Component: "worker", EventID: event.ID})
+		}
+	}
+	w.recordLatency(time.Since(start))
+	w.processedEvents.Add(1)
+	pool.PutEvent(event)
+}
+
+// recoveryLoop submits a recovery probe while the worker is unhealthy.
+func (w *Worker) recoveryLoop() {
+	ticker := time.NewTicker(recoveryProbeInterval)
+	defer ticker.Stop()
+
+	for i := 0; i < maxRecoveryTicks; i++ {
+		select {
+		case <-ticker.C:
+			if w.IsHealthy() {
+				continue
+			}
+			w.Submit(w.recoveryProbe())
+		case <-w.quitChan:
+			return
+		}
+	}
+	if err := assert.Check(false, "recovery loop exceeded max ticks"); err != nil {
+		return
+	}
+}
+
+// recoveryProbe builds a ledger_recovered event describing the current outage.
+func (w *Worker) recoveryProbe() *models.Event {
+	event := pool.GetEvent()
+	event.ID = uuid.New().String()[:8]
+	event.Timestamp = time.Now()
+	event.EventType = EventLedgerRecovered
+	event.Method = "logryph:" + EventLedgerRecovered
+	event.Actor = "system"
+	if event.Params == nil {
+		event.Params = make(map[string]interface{})
+	}
+	if since := w.unhealthySince.Load(); since != 0 {
+		event.Params["unhealthy_since"] = time.Unix(0, since).UTC().Format(time.RFC3339Nano)
+	}
+	event.Params["failed_events"] = w.failedEvents.Load()
+	return event
+}
+
 // processEvents is the main worker loop
 func (w *Worker) processEvents() {
 	for i := 0; i < maxSignalBatches; i++ {
@@ -482,14 +566,7 @@ func (w *Worker) processEvents() {
 			if err != nil {
 				break
 			}
-			start := time.Now()
-			if err := w.processor.ProcessEvent(event); err != nil {
-				logging.Critical("event_processing_failed", logging.Fields{Component: "worker", EventID: event.ID, TaskID: event.TaskID, Error: err.Error()})
-				w.isUnhealthy.Store(true)
-			}
-			w.recordLatency(time.Since(start))
-			w.processedEvents.Add(1)
-			pool.PutEvent(event)
+			w.process(event)
 		}
 	}
 	if err := assert.Check(false, "processEvents exceeded max signal batches"); err != nil {
@@ -0,0 +1,86 @@
+package ledger
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/pool"
+)
+
+func waitForHealth(t *testing.T, worker *Worker, healthy bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for i := 0; i < 2000; i++ {
+		if worker.IsHealthy() == healthy {
+			return
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("worker healthy = %v, want %v", worker.IsHealthy(), healthy)
+}
+
+func TestWorkerRecoversAfterFailedWrites(t *testing.T) {
+	oldStrictMode := assert.StrictMode
+	oldSuppressLogs := assert.SuppressLogs
+	oldInterval := recoveryProbeInterval
+	assert.StrictMode = false
+	assert.SuppressLogs = true
+	recoveryProbeInterval = 5 * time.Millisecond
+	defer func() {
+		assert.StrictMode = oldStrictMode
+		assert.SuppressLogs = oldSuppressLogs
+		recoveryProbeInterval = oldInterval
+	}()
+
+	repo := &mockEventRepository{}
+	worker, err := NewWorker(16, repo, filepath.Join(t.TempDir(), "test.key"))
+	if err != nil {
+		t.Fatalf("failed to create worker: %v", err)
+	}
+	if err := worker.Start(); err != nil {
+		t.Fatalf("failed to start worker: %v", err)
+	}
+
+	repo.failing.Store(true)
+	event := pool.GetEvent()
+	event.ID = "evt-lost"
+	event.EventType = "tool_call"
+	worker.Submit(event)
+	waitForHealth(t, worker, false)
+
+	// Probes keep failing while the store does.
+	time.Sleep(30 * time.Millisecond)
+	if worker.IsHealthy() {
+		t.Fatal("worker recovered while writes still fail")
+	}
+
+	repo.failing.Store(false)
+	waitForHealth(t, worker, true)
+	if err := worker.Shutdown(time.Second); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	var probe map[string]interface{}
+	for i := 0; i < len(repo.events); i++ {
+		if repo.events[i].ID == "evt-lost" {
+			t.Fatal("failed event was persisted")
+		}
+		if repo.events[i].EventType == EventLedgerRecovered && probe == nil {
+			probe = repo.events[i].Params
+		}
+	}
+	if probe == nil {
+		t.Fatal("no ledger_recovered event persisted")
+	}
+	if probe["failed_events"] != uint64(1) {
+		t.Errorf("failed_events = %v, want 1", probe["failed_events"])
+	}
+	if _, ok := probe["unhealthy_since"].(string); !ok {
+		t.Errorf("unhealthy_since missing: %v", probe)
+	}
+}
@@ -15,3 +15,15 @@ type MCPResponse struct {
 	Result  map[string]interface{} `json:"result,omitempty"`
 	Error   map[string]interface{} `json:"error,omitempty"`
 }
+
+// MCPMessage is the union of every JSON-RPC shape that can travel on an MCP stream.
+// Requests carry Method and ID, notifications carry Method only, responses carry
+// ID with Result or Error.
+type MCPMessage struct {
+	JSONRPC string                 `json:"jsonrpc"`
+	ID      interface{}            `json:"id,omitempty"`
+	Method  string                 `json:"method,omitempty"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+	Result  map[string]interface{} `json:"result,omitempty"`
+	Error   map[string]interface{} `json:"error,omitempty"`
+}
@@ -0,0 +1,175 @@
+// Package mirror pairs mirrored calls with their shadow responses. The
+// interceptor sends the shadow request; the Tracker bounds how many are in
+// flight, hands each one the primary result to compare against, and counts
+// the outcomes for /metrics.
+package mirror
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+)
+
+// Outcomes of a mirrored call, recorded on its shadow_response event.
+const (
+	OutcomeMatch          = "match"           // Shadow returned the same result or error as the primary
+	OutcomeDiverged       = "diverged"        // Results differ
+	OutcomeShadowFailed   = "shadow_failed"   // Shadow unreachable, timed out or sent no JSON-RPC response
+	OutcomePrimaryMissing = "primary_missing" // No primary response arrived within the mirror timeout
+)
+
+// Outcomes lists every outcome, in the order metrics report them.
+var Outcomes = [4]string{OutcomeMatch, OutcomeDiverged, OutcomeShadowFailed, OutcomePrimaryMissing}
+
+// MaxInFlight bounds concurrent shadow calls; calls beyond it are not mirrored.
+const MaxInFlight = 64
+
+// pair is a mirrored call waiting for its primary result.
+type pair struct {
+	digest string
+	ready  chan struct{}
+}
+
+// Tracker holds the mirrored calls in flight and the outcome counters.
+type Tracker struct {
+	mu      sync.Mutex
+	pending map[string]*pair // Call event ID -> primary result
+
+	outcomes [len(Outcomes)]uint64
+	skipped  uint64
+}
+
+// NewTracker creates an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{pending: make(map[string]*pair, MaxInFlight)}
+}
+
+// Begin registers a mirrored call. Returns false, counting the call as skipped,
+// when MaxInFlight shadow calls are already running.
+func (t *Tracker) Begin(callID string) bool {
+	if err := assert.Check(callID != "", "call id must not be empty"); err != nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) >= MaxInFlight {
+		atomic.AddUint64(&t.skipped, 1)
+		return false
+	}
+	if _, dup := t.pending[callID]; !dup {
+		t.pending[callID] = &pair{ready: make(chan struct{})}
+	}
+	return true
+}
+
+// Wants reports whether the primary response to callID is awaited.
+func (t *Tracker) Wants(callID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.pending[callID]
+	return ok && p.digest == ""
+}
+
+// Primary stores the digest of the primary response to a mirrored call.
+func (t *Tracker) Primary(callID, digest string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.pending[callID]
+	if !ok || p.digest != "" || digest == "" {
+		return
+	}
+	p.digest = digest
+	close(p.ready)
+}
+
+// Await waits up to timeout for the primary response to callID and returns its
+// digest. The call is released either way.
+func (t *Tracker) Await(callID string, timeout time.Duration) (string, bool) {
+	t.mu.Lock()
+	p, ok := t.pending[callID]
+	t.mu.Unlock()
+	if !ok {
+		return "", false
+	}
+	defer t.release(callID)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-p.ready:
+		return p.digest, true
+	case <-timer.C:
+		return "", false
+	}
+}
+
+func (t *Tracker) release(callID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pending, callID)
+}
+
+// Record counts the outcome of a mirrored call.
+func (t *Tracker) Record(outcome string) {
+	for i := 0; i < len(Outcomes); i++ {
+		if Outcomes[i] == outcome {
+			atomic.AddUint64(&t.outcomes[i], 1)
+			return
+		}
+	}
+	_ = assert.Check(false, "unknown mirror outcome %q", outcome)
+}
+
+// Stats is a snapshot of the tracker's counters.
+type Stats struct {
+	Outcomes map[string]uint64
+	Skipped  uint64
+	InFlight int
+}
+
+// Stats returns the outcome counters.
+func (t *Tracker) Stats() Stats {
+	s := Stats{Outcomes: make(map[string]uint64, len(Outcomes)), Skipped: atomic.LoadUint64(&t.skipped)}
+	for i := 0; i < len(Outcomes); i++ {
+		s.Outcomes[Outcomes[i]] = atomic.LoadUint64(&t.outcomes[i])
+	}
+	t.mu.Lock()
+	s.InFlight = len(t.pending)
+	t.mu.Unlock()
+	return s
+}
+
+// Digest returns the SHA-256 (hex) of a JSON-RPC response's result, or of its
+// error when set. Maps are encoded with sorted keys, so equal values always
+// have equal digests.
+func Digest(result, rpcErr map[string]interface{}) string {
+	v := map[string]interface{}{"result": result}
+	if rpcErr != nil {
+		v = map[string]interface{}{"error": rpcErr}
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// Compare returns the outcome of a mirrored call from the primary and shadow
+// digests; an empty shadow digest means the shadow call failed.
+func Compare(primary, shadow string, primaryOK bool) string {
+	switch {
+	case shadow == "":
+		return OutcomeShadowFailed
+	case !primaryOK:
+		return OutcomePrimaryMissing
+	case primary == shadow:
+		return OutcomeMatch
+	}
+	return OutcomeDiverged
+}
@@ -0,0 +1,64 @@
+package mirror
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTrackerComparesPrimaryAndShadow(t *testing.T) {
+	tr := NewTracker()
+	same := Digest(map[string]interface{}{"a": 1.0, "b": "x"}, nil)
+	if other := Digest(map[string]interface{}{"b": "x", "a": 1.0}, nil); other != same {
+		t.Fatalf("expected key order not to change the digest")
+	}
+
+	if !tr.Begin("call-1") || !tr.Wants("call-1") {
+		t.Fatal("expected call to be registered")
+	}
+	tr.Primary("call-1", same)
+	if tr.Wants("call-1") {
+		t.Error("expected primary to be stored once")
+	}
+	primary, ok := tr.Await("call-1", time.Second)
+	if got := Compare(primary, same, ok); got != OutcomeMatch {
+		t.Errorf("expected match, got %s", got)
+	}
+	tr.Record(OutcomeMatch)
+
+	tr.Begin("call-2")
+	tr.Primary("call-2", Digest(nil, map[string]interface{}{"code": -32000.0}))
+	primary, ok = tr.Await("call-2", time.Second)
+	if got := Compare(primary, same, ok); got != OutcomeDiverged {
+		t.Errorf("expected divergence, got %s", got)
+	}
+
+	tr.Begin("call-3")
+	primary, ok = tr.Await("call-3", 10*time.Millisecond)
+	if got := Compare(primary, same, ok); got != OutcomePrimaryMissing {
+		t.Errorf("expected missing primary, got %s", got)
+	}
+	if got := Compare("", "", false); got != OutcomeShadowFailed {
+		t.Errorf("expected shadow failure, got %s", got)
+	}
+
+	stats := tr.Stats()
+	if stats.InFlight != 0 || stats.Outcomes[OutcomeMatch] != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestTrackerSkipsBeyondMaxInFlight(t *testing.T) {
+	tr := NewTracker()
+	for i := 0; i < MaxInFlight; i++ {
+		if !tr.Begin(fmt.Sprintf("call-%d", i)) {
+			t.Fatalf("expected call %d to be accepted", i)
+		}
+	}
+	if tr.Begin("one-too-many") {
+		t.Fatal("expected call beyond MaxInFlight to be skipped")
+	}
+	if stats := tr.Stats(); stats.Skipped != 1 || stats.InFlight != MaxInFlight {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
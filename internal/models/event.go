@@ -12,7 +12,7 @@ type Event struct {
 	RunID       string                 `json:"run_id"`
 	SeqIndex    uint64                 `json:"seq_index"`
 	Timestamp   time.Time              `json:"timestamp"`
-	Actor       string                 `json:"actor"` // "agent", "user", or "system"
+	Actor       string                 `json:"actor"` // Agent identity (empty when unidentified), "user", or "system"
 	EventType   string                 `json:"event_type"`
 	Method      string                 `json:"method"`
 	Params      map[string]interface{} `json:"params"`
@@ -22,8 +22,86 @@ type Event struct {
 	ParentID    string                 `json:"parent_id,omitempty"`  // Hierarchy tracking
 	PolicyID    string                 `json:"policy_id,omitempty"`
 	RiskLevel   string                 `json:"risk_level,omitempty"`
+	BatchID     string                 `json:"batch_id,omitempty"`     // Shared by all members of a JSON-RPC batch
+	LatencyMs   int64                  `json:"latency_ms,omitempty"`   // Round-trip time of the call a response answers
+	HTTPStatus  int                    `json:"http_status,omitempty"`  // Upstream HTTP status (HTTP transport only)
+	Upstream    string                 `json:"upstream,omitempty"`     // Routed tool server (multi-upstream proxy only)
+	HTTP        *HTTPMetadata          `json:"http,omitempty"`         // HTTP exchange facts (HTTP transport only)
+	SessionID   string                 `json:"session_id,omitempty"`   // MCP session opened by initialize
+	BodySize    int64                  `json:"body_size,omitempty"`    // Size of the complete request body
+	BodySHA256  string                 `json:"body_sha256,omitempty"`  // SHA-256 (hex) of the complete request body
+	BodyStorage string                 `json:"body_storage,omitempty"` // "" (params complete), "truncated", "offloaded" or "omitted"
+	BlobRefs    []string               `json:"blob_refs,omitempty"`    // Digests of response values Logryph moved to the blob store
+	TraceID     string                 `json:"trace_id,omitempty"`     // W3C trace ID (hex) of the HTTP exchange
+	SpanID      string                 `json:"span_id,omitempty"`      // Logryph's span in that trace, the upstream's parent
 	PrevHash    string                 `json:"prev_hash"`
 	CurrentHash string                 `json:"current_hash"`
 	Signature   string                 `json:"signature"`
 	WasBlocked  bool                   `json:"was_blocked"`
 }
+
+// HTTPMetadata describes the HTTP exchange that carried an event's message.
+// Headers hold only allowlisted names; sensitive values are "sha256:<hex>" digests.
+type HTTPMetadata struct {
+	ClientAddr      string            `json:"client_addr,omitempty"`
+	Method          string            `json:"method,omitempty"`
+	Path            string            `json:"path,omitempty"`
+	ContentLength   int64             `json:"content_length,omitempty"`
+	RequestHeaders  map[string]string `json:"request_headers,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+}
+
+// HashPayload returns the fields covered by the event hash and signature.
+// Fields added after the original ledger format are included only when set,
+// so chains written by earlier versions keep verifying.
+func (e *Event) HashPayload() map[string]interface{} {
+	payload := map[string]interface{}{
+		"id":         e.ID,
+		"run_id":     e.RunID,
+		"seq_index":  e.SeqIndex,
+		"timestamp":  e.Timestamp.Format(time.RFC3339Nano),
+		"actor":      e.Actor,
+		"event_type": e.EventType,
+		"method":     e.Method,
+		"params":     e.Params,
+		"response":   e.Response,
+		"task_id":    e.TaskID,
+		"task_state": e.TaskState,
+		"parent_id":  e.ParentID,
+		"policy_id":  e.PolicyID,
+		"risk_level": e.RiskLevel,
+	}
+	if e.BatchID != "" {
+		payload["batch_id"] = e.BatchID
+	}
+	if e.LatencyMs != 0 {
+		payload["latency_ms"] = e.LatencyMs
+	}
+	if e.HTTPStatus != 0 {
+		payload["http_status"] = e.HTTPStatus
+	}
+	if e.Upstream != "" {
+		payload["upstream"] = e.Upstream
+	}
+	if e.HTTP != nil {
+		payload["http"] = e.HTTP
+	}
+	if e.SessionID != "" {
+		payload["session_id"] = e.SessionID
+	}
+	if e.BodySHA256 != "" {
+		payload["body_size"] = e.BodySize
+		payload["body_sha256"] = e.BodySHA256
+	}
+	if e.BodyStorage != "" {
+		payload["body_storage"] = e.BodyStorage
+	}
+	if len(e.BlobRefs) > 0 {
+		payload["blob_refs"] = e.BlobRefs
+	}
+	if e.TraceID != "" {
+		payload["trace_id"] = e.TraceID
+		payload["span_id"] = e.SpanID
+	}
+	return payload
+}
@@ -0,0 +1,234 @@
+package observer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+const (
+	maxRulePatterns   = 128
+	maxRuleConditions = 64
+	maxPeekActions    = 256
+	maxRuleRedact     = 128
+)
+
+// redactedValue replaces values scrubbed from recorded payloads.
+const redactedValue = "[REDACTED]"
+
+// Action is what an MCP request acts on. MCP multiplexes every tool behind
+// "tools/call", so rules are matched against Name: the tool name for tools/call,
+// the resource URI for resources/read and the prompt name for prompts/get, or
+// the method itself for anything else. Args is what conditions are evaluated
+// against: params.arguments for tools/call and prompts/get, params otherwise.
+// Actor is the agent identity, matched by match_actors; Actor and TaskID are set
+// by the caller and also key rate_limit counters.
+type Action struct {
+	Method string
+	Name   string
+	Args   map[string]interface{}
+	Actor  string
+	TaskID string
+}
+
+// ResolveAction derives the action of a JSON-RPC request. It never asserts on
+// params, which come straight from the agent. Args is never nil.
+func ResolveAction(method string, params map[string]interface{}) Action {
+	a := Action{Method: method, Name: method, Args: params}
+	switch method {
+	case "tools/call", "prompts/get":
+		if name, ok := params["name"].(string); ok && name != "" {
+			a.Name = name
+		}
+		args, _ := params["arguments"].(map[string]interface{})
+		a.Args = args
+	case "resources/read":
+		if uri, ok := params["uri"].(string); ok && uri != "" {
+			a.Name = uri
+		}
+	}
+	if a.Args == nil {
+		a.Args = map[string]interface{}{}
+	}
+	return a
+}
+
+// Match returns the first rule evaluated on matchOn (MatchOnRequest,
+// MatchOnError or MatchOnResponse) whose patterns select the action and whose
+// conditions hold against args, or nil. For error rules args is the JSON-RPC
+// error object, for response rules the result or error object; otherwise it is
+// action.Args. The call is counted against every rate_limit
+// rule that selects it, even past the first match, and a rate rule only
+// matches once its window is over the limit. An over-limit rate rule that
+// denies or requires approval wins over an earlier tag-only match, so listing
+// a tag rule first never disables a limit's enforcement.
+func (e *ObserverEngine) Match(action Action, args map[string]interface{}, matchOn string) *Rule {
+	return e.match(action, args, matchOn, time.Now(), nil)
+}
+
+// MatchUncounted is Match without counting the message against rate_limit
+// rules: a rate rule matches if one more message would put it over its limit.
+// It is used for shadow traffic, which must not eat into the primary's windows.
+func (e *ObserverEngine) MatchUncounted(action Action, args map[string]interface{}, matchOn string) *Rule {
+	return e.match(action, args, matchOn, time.Now(), make(map[rateKey]int))
+}
+
+// Peek returns the request rule each action would match if the actions were
+// matched in order, without counting them against rate_limit rules. Calls
+// earlier in the slice count towards the windows of later ones, so a JSON-RPC
+// batch can be vetted as a whole before any member is recorded.
+func (e *ObserverEngine) Peek(actions []Action) []*Rule {
+	if err := assert.Check(len(actions) <= maxPeekActions, "peek exceeds max actions: %d", len(actions)); err != nil {
+		return nil
+	}
+	now := time.Now()
+	pending := make(map[rateKey]int)
+	rules := make([]*Rule, len(actions))
+	for i := 0; i < maxPeekActions; i++ {
+		if i >= len(actions) {
+			break
+		}
+		rules[i] = e.match(actions[i], actions[i].Args, MatchOnRequest, now, pending)
+	}
+	return rules
+}
+
+// match implements Match and Peek. A nil pending records the call in the rate
+// counters; otherwise calls are tallied in pending only.
+func (e *ObserverEngine) match(action Action, args map[string]interface{}, matchOn string, now time.Time, pending map[rateKey]int) *Rule {
+	if err := assert.Check(action.Name != "", "action name must not be empty"); err != nil {
+		return nil
+	}
+	policies := e.GetPolicies()
+	var matched *Rule
+	for i := 0; i < maxRules; i++ {
+		if i >= len(policies) {
+			break
+		}
+		rule := &policies[i]
+		if rule.Target() != matchOn {
+			continue
+		}
+		if matched != nil && rule.RateLimit == nil {
+			continue
+		}
+		if err := assert.Check(len(rule.MatchMethods) <= maxRulePatterns, "match_methods exceeds max in rule=%s", rule.ID); err != nil {
+			return nil
+		}
+		if err := assert.Check(len(rule.MatchConditions) <= maxRuleConditions, "conditions exceeds max in rule=%s", rule.ID); err != nil {
+			return nil
+		}
+		if !rule.selects(action, args) {
+			continue
+		}
+		if rule.RateLimit != nil && (e.rates == nil || !e.rates.over(rule, action, now, pending)) {
+			continue
+		}
+		if matched == nil || (matched.Effect() == RuleActionTag && rule.Effect() != RuleActionTag) {
+			matched = rule
+		}
+	}
+	return matched
+}
+
+// selects reports whether the rule's match_methods select the action (its
+// resolved name or its method), its match_actors (if any) select the caller,
+// and its conditions hold against args.
+func (r *Rule) selects(action Action, args map[string]interface{}) bool {
+	methods, actors := r.methods, r.actors
+	if methods == nil {
+		// Rules built in code rather than loaded: compile on use.
+		compiled := *r
+		if err := compiled.compilePatterns(); err != nil {
+			return false
+		}
+		methods, actors = compiled.methods, compiled.actors
+	}
+	if actors != nil && (action.Actor == "" || !actors.Match(action.Actor)) {
+		return false
+	}
+	if !methods.Match(action.Name, action.Method) {
+		return false
+	}
+	return len(r.MatchConditions) == 0 || (args != nil && CheckConditions(r.MatchConditions, args))
+}
+
+// compilePatterns compiles match_methods and match_actors.
+func (r *Rule) compilePatterns() error {
+	methods, err := CompilePatterns(r.MatchMethods)
+	if err != nil {
+		return fmt.Errorf("match_methods: %w", err)
+	}
+	var actors *PatternSet
+	if len(r.MatchActors) > 0 {
+		if actors, err = CompilePatterns(r.MatchActors); err != nil {
+			return fmt.Errorf("match_actors: %w", err)
+		}
+	}
+	r.methods, r.actors = methods, actors
+	return nil
+}
+
+// compileRedact parses the redact entries of response and error rules as
+// condition paths. Request rules name parameter keys and are left as they are.
+func (r *Rule) compileRedact() error {
+	if r.Target() == MatchOnRequest {
+		return nil
+	}
+	if len(r.Redact) > maxRuleRedact {
+		return fmt.Errorf("redact: too many paths: %d (max %d)", len(r.Redact), maxRuleRedact)
+	}
+	paths := make([]path, 0, len(r.Redact))
+	for i := 0; i < len(r.Redact); i++ {
+		p, err := parsePath(r.Redact[i])
+		if err != nil {
+			return fmt.Errorf("redact: %w", err)
+		}
+		paths = append(paths, p)
+	}
+	r.redact = paths
+	return nil
+}
+
+// RedactPayload replaces every value selected by the redact paths of a response
+// or error rule with "[REDACTED]", in place, and returns how many it replaced.
+// It fails closed: when a path selects more values than can be visited
+// (maxPathValues) or cannot be compiled, every top-level value of the payload is
+// redacted instead.
+func (r *Rule) RedactPayload(payload map[string]interface{}) int {
+	if payload == nil || len(r.Redact) == 0 || r.Target() == MatchOnRequest {
+		return 0
+	}
+	paths := r.redact
+	if paths == nil {
+		// Rules built in code rather than loaded: compile on use.
+		compiled := *r
+		if err := compiled.compileRedact(); err != nil {
+			logging.Warn("redact_whole_payload", logging.Fields{Component: "observer", PolicyID: r.ID, Error: err.Error()})
+			return redactAll(payload)
+		}
+		paths = compiled.redact
+	}
+	replaced := 0
+	for i := 0; i < len(paths) && i < maxRuleRedact; i++ {
+		n, complete := paths[i].assign(payload, redactedValue)
+		if !complete {
+			logging.Warn("redact_whole_payload", logging.Fields{Component: "observer", PolicyID: r.ID, Error: fmt.Sprintf("redact path %q selects more than %d values", r.Redact[i], maxPathValues)})
+			return redactAll(payload)
+		}
+		replaced += n
+	}
+	return replaced
+}
+
+// redactAll replaces every top-level value of payload and returns how many.
+func redactAll(payload map[string]interface{}) int {
+	replaced := 0
+	for key := range payload {
+		payload[key] = redactedValue
+		replaced++
+	}
+	return replaced
+}
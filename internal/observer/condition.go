@@ -0,0 +1,551 @@
+package observer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/slyt3/Logryph/internal/assert"
+)
+
+// Bounds on condition trees.
+const (
+	maxConditionDepth = 8
+	maxConditionNodes = 256
+	maxConditionSet   = 256  // Entries in values
+	maxRegexBytes     = 1024 // Length of a regex pattern
+
+	// maxConditionSteps bounds a walk over a condition tree: each node is
+	// entered once and each group is left once.
+	maxConditionSteps = 2*maxConditionNodes + 2
+)
+
+// Comparison operators. Those marked ci also have a case-insensitive variant
+// with a "_ci" suffix, e.g. "eq_ci", "contains_ci".
+const (
+	OpEq       = "eq"        // String form equals value (ci)
+	OpNe       = "ne"        // String form differs from value (ci)
+	OpGt       = "gt"        // Number greater than value
+	OpLt       = "lt"        // Number less than value
+	OpGte      = "gte"       // Number at least value
+	OpLte      = "lte"       // Number at most value
+	OpContains = "contains"  // String contains value (ci)
+	OpPrefix   = "prefix"    // String starts with value (ci)
+	OpSuffix   = "suffix"    // String ends with value (ci)
+	OpRegex    = "regex"     // String matches the RE2 pattern in value (ci)
+	OpIn       = "in"        // String form equals one of values (ci)
+	OpNotIn    = "not_in"    // String form equals none of values (ci)
+	OpExists   = "exists"    // The path selects a value, even null
+	OpLengthGt = "length_gt" // String (in characters), array or object is longer than value
+)
+
+// caseInsensitiveSuffix turns an operator into its case-insensitive variant.
+const caseInsensitiveSuffix = "_ci"
+
+// Condition is one node of a rule's condition tree: either a comparison (Key,
+// Operator, Value) or a group. All holds when every child holds, Any when at
+// least one does, and Not when its child does not. A rule's conditions list is
+// an implicit All.
+//
+//	conditions:
+//	  - any:
+//	      - {key: "amount", operator: "gt", value: "1000"}
+//	      - not: {key: "currency", operator: "eq", value: "USD"}
+type Condition struct {
+	Key      string   `yaml:"key,omitempty"`
+	Operator string   `yaml:"operator,omitempty"`
+	Value    string   `yaml:"value,omitempty"`
+	Values   []string `yaml:"values,omitempty"` // Set for in and not_in
+	Match    string   `yaml:"match,omitempty"`  // PathMatchAny (default) or PathMatchAll
+
+	All []Condition `yaml:"all,omitempty"`
+	Any []Condition `yaml:"any,omitempty"`
+	Not *Condition  `yaml:"not,omitempty"`
+
+	// Set by compile
+	path path
+	op   string         // Operator without the _ci suffix
+	fold bool           // Case-insensitive
+	num  float64        // Value of numeric operators
+	re   *regexp.Regexp // Compiled regex
+}
+
+// CheckConditions evaluates policy conditions against request parameters.
+// Each comparison's key is a path into params (see parsePath); a path that
+// selects several values holds when any of them satisfies the operator, or with
+// Match "all" when every one does. Returns true if all conditions pass, or if
+// the conditions list is empty. Returns false if params is nil, a key is
+// missing, or a comparison is invalid (unknown operator, malformed path).
+// Conditions loaded from a policy file are compiled once; others are compiled
+// on each call.
+func CheckConditions(conditions []Condition, params map[string]interface{}) bool {
+	if len(conditions) == 0 {
+		return true
+	}
+	if err := assert.Check(params != nil, "params must not be nil"); err != nil {
+		return false
+	}
+	if err := assert.Check(len(conditions) <= maxRuleConditions, "conditions exceed max: %d", len(conditions)); err != nil {
+		return false
+	}
+	return allHold(conditions, params)
+}
+
+// Kinds of condition groups walked by condFrame.
+const (
+	groupAll = iota // The rule's conditions list or an all group
+	groupAny
+	groupNot
+)
+
+// condFrame is one open group of a condition tree walk: its children (or, for
+// not, its single child), the next child to visit, and the group's name in
+// compile errors.
+type condFrame struct {
+	kind     int
+	children []Condition
+	not      *Condition
+	next     int
+	at       string
+}
+
+// frameFor opens the group c. Reports false when c is a comparison.
+func frameFor(c *Condition, at string) (condFrame, bool) {
+	switch {
+	case c.Not != nil:
+		return condFrame{kind: groupNot, not: c.Not, at: at + ".not"}, true
+	case c.All != nil:
+		return condFrame{kind: groupAll, children: c.All, at: at + ".all"}, true
+	case c.Any != nil:
+		return condFrame{kind: groupAny, children: c.Any, at: at + ".any"}, true
+	}
+	return condFrame{}, false
+}
+
+// child returns the frame's next child, or nil once every child was visited.
+func (f *condFrame) child() *Condition {
+	if f.kind == groupNot {
+		if f.next == 0 {
+			return f.not
+		}
+		return nil
+	}
+	if f.next < len(f.children) {
+		return &f.children[f.next]
+	}
+	return nil
+}
+
+// childName names the frame's next child in compile errors. The root frame
+// (empty at) holds the rule's conditions list.
+func (f *condFrame) childName() string {
+	switch {
+	case f.at == "":
+		return fmt.Sprintf("condition %d", f.next+1)
+	case f.kind == groupNot:
+		return f.at
+	}
+	return fmt.Sprintf("%s[%d]", f.at, f.next)
+}
+
+// feed folds the result of the frame's current child into the group and moves
+// on. Reports whether that settles the group, and its result if so.
+func (f *condFrame) feed(holds bool) (settled, result bool) {
+	f.next++
+	switch f.kind {
+	case groupNot:
+		return true, !holds
+	case groupAny:
+		if holds {
+			return true, true
+		}
+	default:
+		if !holds {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// allHold evaluates a rule's conditions list without recursion: open groups sit
+// on a fixed stack of maxConditionDepth+1 frames. Nodes deeper than
+// maxConditionDepth, groups over maxRuleConditions children and trees over
+// maxConditionNodes never hold; validation rejects them at load.
+func allHold(conditions []Condition, params map[string]interface{}) bool {
+	if len(conditions) > maxRuleConditions {
+		return false
+	}
+	var stack [maxConditionDepth + 1]condFrame
+	stack[0] = condFrame{kind: groupAll, children: conditions}
+	top := 0
+
+	for step := 0; step < maxConditionSteps; step++ {
+		f := &stack[top]
+		c := f.child()
+		var holds bool
+		switch {
+		case c == nil:
+			// Every child visited without settling the group: all holds, any does not.
+			if top == 0 {
+				return f.kind == groupAll
+			}
+			top--
+			holds = f.kind == groupAll
+		case top >= maxConditionDepth:
+			holds = false
+		default:
+			if group, ok := frameFor(c, ""); ok {
+				if len(group.children) > maxRuleConditions {
+					holds = false
+					break
+				}
+				top++
+				stack[top] = group
+				continue
+			}
+			holds = c.compare(params)
+		}
+
+		// Fold the result into the enclosing groups it settles.
+		for n := 0; n <= maxConditionDepth; n++ {
+			settled, result := stack[top].feed(holds)
+			if !settled {
+				break
+			}
+			if top == 0 {
+				return result
+			}
+			top--
+			holds = result
+		}
+	}
+	return false
+}
+
+// compare evaluates a comparison against the values its path selects.
+func (c *Condition) compare(params map[string]interface{}) bool {
+	if c.path == nil {
+		compiled := *c
+		if err := compiled.compileComparison(); err != nil {
+			return false
+		}
+		c = &compiled
+	}
+	values := c.path.resolve(params)
+	if c.op == OpExists {
+		return len(values) > 0
+	}
+	if len(values) == 0 {
+		return false
+	}
+	all := c.Match == PathMatchAll
+	for j := 0; j < len(values); j++ {
+		ok := c.test(values[j])
+		if ok && !all {
+			return true
+		}
+		if !ok && all {
+			return false
+		}
+	}
+	return all
+}
+
+// test applies the compiled operator to one selected value.
+func (c *Condition) test(val interface{}) bool {
+	switch c.op {
+	case OpEq:
+		return c.equal(fmt.Sprintf("%v", val), c.Value)
+	case OpNe:
+		return !c.equal(fmt.Sprintf("%v", val), c.Value)
+	case OpGt, OpLt, OpGte, OpLte:
+		fVal, ok := toFloat(val)
+		if !ok {
+			return false
+		}
+		switch c.op {
+		case OpGt:
+			return fVal > c.num
+		case OpLt:
+			return fVal < c.num
+		case OpGte:
+			return fVal >= c.num
+		default:
+			return fVal <= c.num
+		}
+	case OpIn, OpNotIn:
+		text := fmt.Sprintf("%v", val)
+		found := false
+		for j := 0; j < maxConditionSet; j++ {
+			if j >= len(c.Values) {
+				break
+			}
+			if c.equal(text, c.Values[j]) {
+				found = true
+				break
+			}
+		}
+		return found == (c.op == OpIn)
+	case OpLengthGt:
+		n, ok := lengthOf(val)
+		return ok && float64(n) > c.num
+	}
+
+	text, ok := scalarText(val)
+	if !ok {
+		return false
+	}
+	value := c.Value
+	if c.fold {
+		text, value = strings.ToLower(text), strings.ToLower(value)
+	}
+	switch c.op {
+	case OpContains:
+		return strings.Contains(text, value)
+	case OpPrefix:
+		return strings.HasPrefix(text, value)
+	case OpSuffix:
+		return strings.HasSuffix(text, value)
+	case OpRegex:
+		return c.re.MatchString(text)
+	}
+	return false
+}
+
+func (c *Condition) equal(a, b string) bool {
+	if c.fold {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// scalarText returns the text that string operators see: strings as they are,
+// numbers and booleans formatted. Objects, arrays and null have none.
+func scalarText(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case float64, float32, int, int64, bool:
+		return fmt.Sprintf("%v", v), true
+	}
+	return "", false
+}
+
+// lengthOf returns the length of a string in characters, or of an array or object.
+func lengthOf(val interface{}) (int, bool) {
+	switch v := val.(type) {
+	case string:
+		return utf8.RuneCountInString(v), true
+	case []interface{}:
+		return len(v), true
+	case map[string]interface{}:
+		return len(v), true
+	}
+	return 0, false
+}
+
+// compileConditions validates a rule's condition tree and parses its paths,
+// visiting nodes depth first on a fixed stack of maxConditionDepth+1 frames.
+func compileConditions(conditions []Condition) error {
+	if len(conditions) > maxRuleConditions {
+		return fmt.Errorf("too many conditions: %d (max %d)", len(conditions), maxRuleConditions)
+	}
+	var stack [maxConditionDepth + 1]condFrame
+	stack[0] = condFrame{kind: groupAll, children: conditions}
+	top, nodes := 0, 0
+
+	for step := 0; step < maxConditionSteps; step++ {
+		f := &stack[top]
+		c := f.child()
+		if c == nil {
+			if top == 0 {
+				return nil
+			}
+			top--
+			continue
+		}
+		at := f.childName()
+		f.next++
+		// The root frame's children are at depth 1.
+		if top+1 > maxConditionDepth {
+			return fmt.Errorf("%s: conditions nested deeper than %d", at, maxConditionDepth)
+		}
+		nodes++
+		if nodes > maxConditionNodes {
+			return fmt.Errorf("%s: more than %d conditions in one rule", at, maxConditionNodes)
+		}
+		if err := c.checkShape(at); err != nil {
+			return err
+		}
+		group, ok := frameFor(c, at)
+		if !ok {
+			if err := c.compileComparison(); err != nil {
+				return fmt.Errorf("%s: %w", at, err)
+			}
+			continue
+		}
+		if group.kind != groupNot {
+			if len(group.children) == 0 {
+				return fmt.Errorf("%s: empty group", group.at)
+			}
+			if len(group.children) > maxRuleConditions {
+				return fmt.Errorf("%s: too many conditions: %d (max %d)", group.at, len(group.children), maxRuleConditions)
+			}
+		}
+		top++
+		stack[top] = group
+	}
+	return assert.Check(false, "condition compile exceeded %d steps", maxConditionSteps)
+}
+
+// checkShape rejects a node that mixes a comparison with a group, or several
+// groups; at names the node in errors.
+func (c *Condition) checkShape(at string) error {
+	groups := 0
+	if c.All != nil {
+		groups++
+	}
+	if c.Any != nil {
+		groups++
+	}
+	if c.Not != nil {
+		groups++
+	}
+	if groups > 1 || (groups == 1 && (c.Key != "" || c.Operator != "" || c.Value != "" || c.Values != nil || c.Match != "")) {
+		return fmt.Errorf("%s: a condition is either a comparison or one of all, any, not", at)
+	}
+	return nil
+}
+
+// compileComparison parses the key, checks the operator and its value, and
+// precompiles numbers and regexes.
+func (c *Condition) compileComparison() error {
+	p, err := parsePath(c.Key)
+	if err != nil {
+		return err
+	}
+	switch c.Match {
+	case "", PathMatchAny, PathMatchAll:
+	default:
+		return fmt.Errorf("unknown match %q (want %q or %q)", c.Match, PathMatchAny, PathMatchAll)
+	}
+	op, fold, err := c.splitOperator()
+	if err != nil {
+		return err
+	}
+	if err := c.checkValues(op); err != nil {
+		return err
+	}
+
+	var num float64
+	var re *regexp.Regexp
+	switch op {
+	case OpGt, OpLt, OpGte, OpLte, OpLengthGt, OpExists:
+		num, err = c.compileNumber(op)
+	case OpRegex:
+		re, err = c.compileRegex(fold)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.path, c.op, c.fold, c.num, c.re = p, op, fold, num, re
+	return nil
+}
+
+// splitOperator returns the operator without its _ci suffix and whether it had
+// one, rejecting unknown operators and case-insensitive numeric ones.
+func (c *Condition) splitOperator() (string, bool, error) {
+	op, fold := strings.CutSuffix(c.Operator, caseInsensitiveSuffix)
+	switch op {
+	case OpEq, OpNe, OpContains, OpPrefix, OpSuffix, OpRegex, OpIn, OpNotIn:
+	case OpGt, OpLt, OpGte, OpLte, OpExists, OpLengthGt:
+		if fold {
+			return "", false, fmt.Errorf("operator %q has no case-insensitive variant", op)
+		}
+	case "":
+		return "", false, fmt.Errorf("condition on %q has no operator", c.Key)
+	default:
+		return "", false, fmt.Errorf("unknown operator %q", c.Operator)
+	}
+	return op, fold, nil
+}
+
+// checkValues requires a values list of set membership operators and a single
+// value of every other one.
+func (c *Condition) checkValues(op string) error {
+	switch op {
+	case OpIn, OpNotIn:
+		if len(c.Values) == 0 || c.Value != "" {
+			return fmt.Errorf("operator %q takes a values list", c.Operator)
+		}
+		if len(c.Values) > maxConditionSet {
+			return fmt.Errorf("operator %q: more than %d values", c.Operator, maxConditionSet)
+		}
+	default:
+		if len(c.Values) > 0 {
+			return fmt.Errorf("operator %q takes a single value, not values", c.Operator)
+		}
+	}
+	return nil
+}
+
+// compileNumber parses the value of a numeric operator (gt, lt, gte, lte,
+// length_gt); exists takes no value.
+func (c *Condition) compileNumber(op string) (float64, error) {
+	switch op {
+	case OpLengthGt:
+		n, err := strconv.Atoi(c.Value)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("operator %q needs a non-negative integer value, got %q", op, c.Value)
+		}
+		return float64(n), nil
+	case OpExists:
+		if c.Value != "" {
+			return 0, fmt.Errorf("operator %q takes no value", op)
+		}
+		return 0, nil
+	}
+	num, err := strconv.ParseFloat(c.Value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("operator %q needs a numeric value, got %q", op, c.Value)
+	}
+	return num, nil
+}
+
+// compileRegex compiles the pattern of a regex operator, case-insensitively
+// for regex_ci.
+func (c *Condition) compileRegex(fold bool) (*regexp.Regexp, error) {
+	if len(c.Value) > maxRegexBytes {
+		return nil, fmt.Errorf("regex exceeds %d bytes", maxRegexBytes)
+	}
+	pattern := c.Value
+	if fold {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", c.Value, err)
+	}
+	return re, nil
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch i := v.(type) {
+	case float64:
+		return i, true
+	case float32:
+		return float64(i), true
+	case int:
+		return float64(i), true
+	case int64:
+		return float64(i), true
+	case string:
+		f, err := strconv.ParseFloat(i, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -23,18 +21,176 @@ type Config struct {
 		SigningEnabled bool   `yaml:"signing_enabled"`
 		LogLevel       string `yaml:"log_level"`
 	} `yaml:"defaults"`
-	Policies []Rule `yaml:"policies"`
+	Policies    []Rule      `yaml:"policies"`
+	Upstreams   []Upstream  `yaml:"upstreams,omitempty"`
+	HTTPCapture HTTPCapture `yaml:"http_capture,omitempty"`
+	Approvals   Approvals   `yaml:"approvals,omitempty"`
+	Payloads    Payloads    `yaml:"payloads,omitempty"`
+	Identity    Identity    `yaml:"identity,omitempty"`
+	Mirror      Mirror      `yaml:"mirror,omitempty"`
+
+	LedgerUnavailable string `yaml:"ledger_unavailable,omitempty"` // "allow" (default) or "reject"
+}
+
+// Values of ledger_unavailable: what the proxy does with new requests while the
+// ledger cannot persist events.
+const (
+	LedgerUnavailableAllow  = "allow"  // Forward them unrecorded (fail-open)
+	LedgerUnavailableReject = "reject" // Refuse them with a JSON-RPC error (fail-closed)
+)
+
+// Payloads bounds how much of a message is kept inline on its event. Request
+// bodies are always hashed and, up to the parse limit, evaluated in full; above
+// MaxInlineBytes the stored params are truncated, and with Oversize "offload"
+// the complete body is also put in the blob store. When BlobMinBytes is set,
+// response values of at least that size are moved to the blob store and
+// referenced by digest.
+type Payloads struct {
+	MaxInlineBytes int    `yaml:"max_inline_bytes,omitempty"` // Default 1 MiB
+	Oversize       string `yaml:"oversize,omitempty"`         // "truncate" (default) or "offload"
+	BlobMinBytes   int    `yaml:"blob_min_bytes,omitempty"`   // 0 (default) or negative keeps responses inline
+}
+
+// Payload defaults and bounds.
+const (
+	DefaultMaxInlineBytes = 1024 * 1024
+	minInlineBytes        = 1024
+	maxInlineBytes        = 16 * 1024 * 1024
+)
+
+// InlineLimit returns the body size above which params are no longer stored whole.
+func (p Payloads) InlineLimit() int {
+	if p.MaxInlineBytes <= 0 {
+		return DefaultMaxInlineBytes
+	}
+	return p.MaxInlineBytes
+}
+
+// Offload reports whether oversized bodies are written to the offload directory.
+func (p Payloads) Offload() bool {
+	return p.Oversize == "offload"
+}
+
+// BlobThreshold returns the size from which response values go to the blob store,
+// or 0 when response blobs are disabled.
+func (p Payloads) BlobThreshold() int {
+	if p.BlobMinBytes < 0 {
+		return 0
+	}
+	return p.BlobMinBytes
+}
+
+// Approvals configures require_approval rules: how long a held call waits for an
+// operator and which decision applies when nobody answers in time.
+type Approvals struct {
+	TimeoutSeconds  int    `yaml:"timeout_seconds,omitempty"`  // Default 300
+	DefaultDecision string `yaml:"default_decision,omitempty"` // "reject" (default) or "approve"
+}
+
+// Approval defaults and bounds.
+const (
+	DefaultApprovalTimeout = 5 * time.Minute
+	maxApprovalTimeout     = 24 * time.Hour
+)
+
+// Timeout returns the configured approval timeout or DefaultApprovalTimeout.
+func (a Approvals) Timeout() time.Duration {
+	if a.TimeoutSeconds <= 0 {
+		return DefaultApprovalTimeout
+	}
+	return time.Duration(a.TimeoutSeconds) * time.Second
+}
+
+// ApproveOnTimeout reports whether held calls are forwarded when they time out.
+func (a Approvals) ApproveOnTimeout() bool {
+	return a.DefaultDecision == "approve"
+}
+
+// HTTPCapture is the allowlist of HTTP headers recorded on events. Header names are
+// case-insensitive. Sensitive headers (Authorization, Cookie, ...) and any listed in
+// HashedHeaders are stored as a SHA-256 digest, never in clear.
+type HTTPCapture struct {
+	RequestHeaders  []string `yaml:"request_headers,omitempty"`
+	ResponseHeaders []string `yaml:"response_headers,omitempty"`
+	HashedHeaders   []string `yaml:"hashed_headers,omitempty"`
+}
+
+// Upstream is one entry of the proxy routing table. A request is routed to Target
+// when every criterion that is set matches: URL path prefix, Host header, or the
+// tool name of a tools/call request (e.g. "github:*"). Read at startup only.
+type Upstream struct {
+	Name        string `yaml:"name"`
+	Target      string `yaml:"target"`
+	PathPrefix  string `yaml:"path_prefix,omitempty"`
+	StripPrefix bool   `yaml:"strip_prefix,omitempty"` // Remove PathPrefix before forwarding
+	Host        string `yaml:"host,omitempty"`
+	ToolPrefix  string `yaml:"tool_prefix,omitempty"`
 }
 
 // Rule represents a single policy rule with method patterns, conditions, and redaction keys.
-// MatchMethods supports wildcards (e.g., "aws:*"). Redact lists parameter keys to scrub.
+// MatchMethods holds globs (e.g., "aws:*", "*:delete*"), opt-in "^" regexes and "!"
+// exclusions (see PatternSet), matched against the resolved Action (tool name,
+// resource URI or prompt name) as well as the JSON-RPC method.
+// MatchActors, when set, restricts the rule to calls from matching agent identities.
+// Conditions address the tool arguments and may nest all/any/not groups.
+// Redact lists parameter keys to scrub.
+// MatchOn selects what the rule is evaluated against: requests (default), JSON-RPC
+// error responses, whose conditions then address the error's code, message and data,
+// or all responses, whose conditions address the result (or the error object).
+// On response and error rules Redact holds condition paths scrubbed from the
+// recorded response.
+// Action is what happens to a matching request: "tag" (default, passive), "deny",
+// which answers the agent with a JSON-RPC error instead of forwarding the request,
+// or "require_approval", which holds the request until an operator decides.
 type Rule struct {
-	ID              string              `yaml:"id"`
-	MatchMethods    []string            `yaml:"match_methods"`
-	RiskLevel       string              `yaml:"risk_level"`
-	LogLevel        string              `yaml:"log_level,omitempty"`
-	MatchConditions []map[string]string `yaml:"conditions,omitempty"`
-	Redact          []string            `yaml:"redact,omitempty"` // List of param keys to redact
+	ID              string      `yaml:"id"`
+	MatchMethods    []string    `yaml:"match_methods"`
+	MatchActors     []string    `yaml:"match_actors,omitempty"`
+	MatchOn         string      `yaml:"match_on,omitempty"`
+	Action          string      `yaml:"action,omitempty"`
+	Message         string      `yaml:"message,omitempty"` // Error message sent on deny
+	RiskLevel       string      `yaml:"risk_level"`
+	LogLevel        string      `yaml:"log_level,omitempty"`
+	MatchConditions []Condition `yaml:"conditions,omitempty"`
+	Redact          []string    `yaml:"redact,omitempty"` // List of param keys to redact
+	RateLimit       *RateLimit  `yaml:"rate_limit,omitempty"`
+
+	methods *PatternSet // Compiled MatchMethods
+	actors  *PatternSet // Compiled MatchActors, nil when unset
+	redact  []path      // Compiled Redact of a response or error rule
+}
+
+// maxRules bounds the policy list of one config.
+const maxRules = 256
+
+// Values of Rule.MatchOn.
+const (
+	MatchOnRequest  = "request"
+	MatchOnError    = "error"
+	MatchOnResponse = "response"
+)
+
+// Values of Rule.Action.
+const (
+	RuleActionTag     = "tag"
+	RuleActionDeny    = "deny"
+	RuleActionApprove = "require_approval"
+)
+
+// Effect returns the rule's action, defaulting to RuleActionTag.
+func (r *Rule) Effect() string {
+	if r.Action == "" {
+		return RuleActionTag
+	}
+	return r.Action
+}
+
+// Target returns what the rule is evaluated against, defaulting to MatchOnRequest.
+func (r *Rule) Target() string {
+	if r.MatchOn == "" {
+		return MatchOnRequest
+	}
+	return r.MatchOn
 }
 
 // ObserverEngine handles policy evaluation and hot-reload from logryph-policy.yaml.
@@ -44,6 +200,7 @@ type ObserverEngine struct {
 	mu         sync.RWMutex
 	config     *Config
 	configPath string
+	rates      *rateTracker
 	stopChan   chan struct{}
 	stopOnce   sync.Once
 }
@@ -68,6 +225,7 @@ func NewObserverEngine(configPath string) (*ObserverEngine, error) {
 	return &ObserverEngine{
 		config:     config,
 		configPath: absPath,
+		rates:      newRateTracker(),
 		stopChan:   make(chan struct{}),
 	}, nil
 }
@@ -83,10 +241,92 @@ func loadConfig(path string) (*Config, error) {
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("parsing policy YAML: %w", err)
 	}
+	if err := validateConfig(&config); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
 
+// validateConfig rejects rule settings that would otherwise be silently ignored.
+func validateConfig(config *Config) error {
+	if err := assert.NotNil(config, "config"); err != nil {
+		return err
+	}
+	if len(config.Policies) > maxRules {
+		return fmt.Errorf("too many policies: %d (max %d)", len(config.Policies), maxRules)
+	}
+	for i := 0; i < len(config.Policies); i++ {
+		rule := &config.Policies[i]
+		if err := rule.compile(); err != nil {
+			return fmt.Errorf("policy %q: %w", rule.ID, err)
+		}
+	}
+	switch config.Approvals.DefaultDecision {
+	case "", "reject", "approve":
+	default:
+		return fmt.Errorf("approvals: unknown default_decision %q", config.Approvals.DefaultDecision)
+	}
+	if config.Approvals.Timeout() > maxApprovalTimeout {
+		return fmt.Errorf("approvals: timeout_seconds exceeds %s", maxApprovalTimeout)
+	}
+	switch config.Payloads.Oversize {
+	case "", "truncate", "offload":
+	default:
+		return fmt.Errorf("payloads: unknown oversize %q", config.Payloads.Oversize)
+	}
+	if limit := config.Payloads.MaxInlineBytes; limit != 0 && (limit < minInlineBytes || limit > maxInlineBytes) {
+		return fmt.Errorf("payloads: max_inline_bytes must be between %d and %d", minInlineBytes, maxInlineBytes)
+	}
+	if limit := config.Payloads.BlobMinBytes; limit > 0 && limit < minInlineBytes {
+		return fmt.Errorf("payloads: blob_min_bytes must be at least %d (or 0 to disable)", minInlineBytes)
+	}
+	switch config.LedgerUnavailable {
+	case "", LedgerUnavailableAllow, LedgerUnavailableReject:
+	default:
+		return fmt.Errorf("unknown ledger_unavailable %q", config.LedgerUnavailable)
+	}
+	if err := config.Mirror.validate(); err != nil {
+		return err
+	}
+	return config.Identity.compile()
+}
+
+// compile checks a rule's target, action and rate limit against each other and
+// compiles its conditions, patterns and redact paths.
+func (r *Rule) compile() error {
+	switch r.Target() {
+	case MatchOnRequest, MatchOnError, MatchOnResponse:
+	default:
+		return fmt.Errorf("unknown match_on %q", r.MatchOn)
+	}
+	switch r.Effect() {
+	case RuleActionTag:
+	case RuleActionDeny, RuleActionApprove:
+		if r.Target() != MatchOnRequest {
+			return fmt.Errorf("action %q only applies to match_on %q", r.Action, MatchOnRequest)
+		}
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+	if err := compileConditions(r.MatchConditions); err != nil {
+		return err
+	}
+	if err := r.compilePatterns(); err != nil {
+		return err
+	}
+	if err := r.compileRedact(); err != nil {
+		return err
+	}
+	if r.RateLimit == nil {
+		return nil
+	}
+	if r.Target() == MatchOnResponse {
+		return fmt.Errorf("rate_limit only applies to match_on %q or %q", MatchOnRequest, MatchOnError)
+	}
+	return r.RateLimit.validate()
+}
+
 // Reload reloads the policy configuration from disk.
 // Returns an error if the file cannot be read or parsed.
 // Logs "policy_reloaded" event on success.
@@ -182,111 +422,80 @@ func (e *ObserverEngine) GetPolicies() []Rule {
 	return e.config.Policies
 }
 
-// MatchPattern checks if a method matches a policy pattern.
-// Supports exact match and wildcard patterns (e.g., "aws:*" matches "aws:CreateBucket").
-// Returns false if either pattern or method is empty.
-func MatchPattern(pattern, method string) bool {
-	if err := assert.Check(pattern != "", "pattern is non-empty"); err != nil {
-		return false
-	}
-	if err := assert.Check(method != "", "method is non-empty"); err != nil {
-		return false
-	}
-	if pattern == method {
-		return true
+// Enforces reports whether any loaded request rule can stop traffic (action
+// deny or require_approval). Requests that cannot be evaluated must then be
+// refused rather than forwarded.
+func (e *ObserverEngine) Enforces() bool {
+	policies := e.GetPolicies()
+	for i := 0; i < len(policies) && i < maxRules; i++ {
+		if policies[i].Target() == MatchOnRequest && policies[i].Effect() != RuleActionTag {
+			return true
+		}
 	}
+	return false
+}
 
-	// Handle wildcard patterns (e.g., "aws:*", "stripe:*")
-	if strings.HasSuffix(pattern, "*") {
-		prefix := strings.TrimSuffix(pattern, "*")
-		return strings.HasPrefix(method, prefix)
+// Redacts reports whether any loaded request rule has redact keys. Bodies that
+// cannot be evaluated might then carry values the policy scrubs.
+func (e *ObserverEngine) Redacts() bool {
+	policies := e.GetPolicies()
+	for i := 0; i < len(policies) && i < maxRules; i++ {
+		if policies[i].Target() == MatchOnRequest && len(policies[i].Redact) > 0 {
+			return true
+		}
 	}
-
 	return false
 }
 
-// CheckConditions evaluates policy conditions against request parameters.
-// Supports operators: eq, gt, lt, gte, lte. Returns true if all conditions pass.
-// Returns true if conditions list is empty. Returns false if params is nil.
-func CheckConditions(conditions []map[string]string, params map[string]interface{}) bool {
-	if len(conditions) == 0 {
-		return true
-	}
-	if err := assert.Check(params != nil, "params must not be nil"); err != nil {
-		return false
-	}
-	const maxConditions = 64
-	if err := assert.Check(len(conditions) <= maxConditions, "conditions exceed max: %d", len(conditions)); err != nil {
-		return false
-	}
+// GetUpstreams returns the proxy routing table from the loaded config.
+func (e *ObserverEngine) GetUpstreams() []Upstream {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config.Upstreams
+}
 
-	for i := 0; i < maxConditions; i++ {
-		if i >= len(conditions) {
-			break
-		}
-		cond := conditions[i]
-		key := cond["key"]
-		operator := cond["operator"]
-		value := cond["value"]
-
-		val, ok := params[key]
-		if !ok {
-			return false
-		}
+// GetApprovals returns the approval queue settings from the loaded config.
+func (e *ObserverEngine) GetApprovals() Approvals {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config.Approvals
+}
 
-		switch operator {
-		case "eq":
-			if fmt.Sprintf("%v", val) != value {
-				return false
-			}
-		case "gt", "lt", "gte", "lte":
-			fVal, ok1 := toFloat(val)
-			fTarget, err2 := strconv.ParseFloat(value, 64)
-			if !ok1 || err2 != nil {
-				return false
-			}
+// GetPayloads returns the request body size policy from the loaded config.
+func (e *ObserverEngine) GetPayloads() Payloads {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config.Payloads
+}
 
-			switch operator {
-			case "gt":
-				if !(fVal > fTarget) {
-					return false
-				}
-			case "lt":
-				if !(fVal < fTarget) {
-					return false
-				}
-			case "gte":
-				if !(fVal >= fTarget) {
-					return false
-				}
-			case "lte":
-				if !(fVal <= fTarget) {
-					return false
-				}
-			}
-		default:
-			// Unknown operator, skip
-			continue
-		}
+// GetMirror returns the traffic mirroring settings from the loaded config.
+func (e *ObserverEngine) GetMirror() Mirror {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config.Mirror
+}
+
+// GetLedgerUnavailable returns what to do with requests while the ledger is
+// unhealthy: LedgerUnavailableAllow or LedgerUnavailableReject.
+func (e *ObserverEngine) GetLedgerUnavailable() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.config.LedgerUnavailable == "" {
+		return LedgerUnavailableAllow
 	}
+	return e.config.LedgerUnavailable
+}
 
-	return true
+// GetIdentity returns the agent identity settings from the loaded config.
+func (e *ObserverEngine) GetIdentity() Identity {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config.Identity
 }
 
-func toFloat(v interface{}) (float64, bool) {
-	switch i := v.(type) {
-	case float64:
-		return i, true
-	case float32:
-		return float64(i), true
-	case int:
-		return float64(i), true
-	case int64:
-		return float64(i), true
-	case string:
-		f, err := strconv.ParseFloat(i, 64)
-		return f, err == nil
-	default:
-		return 0, false
-	}
+// GetHTTPCapture returns the header capture allowlist from the loaded config.
+func (e *ObserverEngine) GetHTTPCapture() HTTPCapture {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.config.HTTPCapture
 }
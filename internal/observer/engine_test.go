@@ -1,7 +1,10 @@
 package observer
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -149,76 +152,211 @@ policies:
 func TestCheckConditions(t *testing.T) {
 	tests := []struct {
 		name       string
-		conditions []map[string]string
+		conditions []Condition
 		params     map[string]interface{}
 		want       bool
 	}{
 		{
 			name: "eq success",
-			conditions: []map[string]string{
-				{"key": "amount", "operator": "eq", "value": "100"},
+			conditions: []Condition{
+				{Key: "amount", Operator: "eq", Value: "100"},
 			},
 			params: map[string]interface{}{"amount": 100},
 			want:   true,
 		},
 		{
 			name: "gt success",
-			conditions: []map[string]string{
-				{"key": "amount", "operator": "gt", "value": "1000"},
+			conditions: []Condition{
+				{Key: "amount", Operator: "gt", Value: "1000"},
 			},
 			params: map[string]interface{}{"amount": 1500},
 			want:   true,
 		},
 		{
 			name: "gt fail",
-			conditions: []map[string]string{
-				{"key": "amount", "operator": "gt", "value": "1000"},
+			conditions: []Condition{
+				{Key: "amount", Operator: "gt", Value: "1000"},
 			},
 			params: map[string]interface{}{"amount": 500},
 			want:   false,
 		},
 		{
 			name: "lt success",
-			conditions: []map[string]string{
-				{"key": "age", "operator": "lt", "value": "18"},
+			conditions: []Condition{
+				{Key: "age", Operator: "lt", Value: "18"},
 			},
 			params: map[string]interface{}{"age": 17},
 			want:   true,
 		},
 		{
 			name: "gte success",
-			conditions: []map[string]string{
-				{"key": "score", "operator": "gte", "value": "50"},
+			conditions: []Condition{
+				{Key: "score", Operator: "gte", Value: "50"},
 			},
 			params: map[string]interface{}{"score": 50},
 			want:   true,
 		},
 		{
 			name: "lte success",
-			conditions: []map[string]string{
-				{"key": "score", "operator": "lte", "value": "50"},
+			conditions: []Condition{
+				{Key: "score", Operator: "lte", Value: "50"},
 			},
 			params: map[string]interface{}{"score": "50"}, // string param
 			want:   true,
 		},
 		{
 			name: "multi condition success",
-			conditions: []map[string]string{
-				{"key": "amount", "operator": "gt", "value": "100"},
-				{"key": "mode", "operator": "eq", "value": "live"},
+			conditions: []Condition{
+				{Key: "amount", Operator: "gt", Value: "100"},
+				{Key: "mode", Operator: "eq", Value: "live"},
 			},
 			params: map[string]interface{}{"amount": 200, "mode": "live"},
 			want:   true,
 		},
 		{
 			name: "multi condition fail",
-			conditions: []map[string]string{
-				{"key": "amount", "operator": "gt", "value": "100"},
-				{"key": "mode", "operator": "eq", "value": "live"},
+			conditions: []Condition{
+				{Key: "amount", Operator: "gt", Value: "100"},
+				{Key: "mode", Operator: "eq", Value: "live"},
 			},
 			params: map[string]interface{}{"amount": 50, "mode": "live"},
 			want:   false,
 		},
+		{
+			name: "nested key",
+			conditions: []Condition{
+				{Key: "payment.amount", Operator: "gt", Value: "1000"},
+			},
+			params: map[string]interface{}{
+				"payment": map[string]interface{}{"amount": 2500.0, "currency": "usd"},
+				"recipients": []interface{}{
+					map[string]interface{}{"email": "a@corp.example", "amount": 10.0},
+					map[string]interface{}{"email": "b@gmail.com", "amount": 900.0},
+				},
+				"headers": map[string]interface{}{"x.trace": "1"},
+			},
+			want: true,
+		},
+		{
+			name: "jsonpath root and index",
+			conditions: []Condition{
+				{Key: "$.recipients[1].email", Operator: "eq", Value: "b@gmail.com"},
+			},
+			params: map[string]interface{}{
+				"payment": map[string]interface{}{"amount": 2500.0, "currency": "usd"},
+				"recipients": []interface{}{
+					map[string]interface{}{"email": "a@corp.example", "amount": 10.0},
+					map[string]interface{}{"email": "b@gmail.com", "amount": 900.0},
+				},
+				"headers": map[string]interface{}{"x.trace": "1"},
+			},
+			want: true,
+		},
+		{
+			name: "index out of range",
+			conditions: []Condition{
+				{Key: "recipients[2].email", Operator: "eq", Value: "b@gmail.com"},
+			},
+			params: map[string]interface{}{
+				"payment": map[string]interface{}{"amount": 2500.0, "currency": "usd"},
+				"recipients": []interface{}{
+					map[string]interface{}{"email": "a@corp.example", "amount": 10.0},
+					map[string]interface{}{"email": "b@gmail.com", "amount": 900.0},
+				},
+				"headers": map[string]interface{}{"x.trace": "1"},
+			},
+			want: false,
+		},
+		{
+			name: "wildcard any",
+			conditions: []Condition{
+				{Key: "recipients[*].amount", Operator: "gt", Value: "500"},
+			},
+			params: map[string]interface{}{
+				"payment": map[string]interface{}{"amount": 2500.0, "currency": "usd"},
+				"recipients": []interface{}{
+					map[string]interface{}{"email": "a@corp.example", "amount": 10.0},
+					map[string]interface{}{"email": "b@gmail.com", "amount": 900.0},
+				},
+				"headers": map[string]interface{}{"x.trace": "1"},
+			},
+			want: true,
+		},
+		{
+			name: "wildcard all fails",
+			conditions: []Condition{
+				{Key: "recipients.*.amount", Operator: "gt", Value: "500", Match: "all"},
+			},
+			params: map[string]interface{}{
+				"payment": map[string]interface{}{"amount": 2500.0, "currency": "usd"},
+				"recipients": []interface{}{
+					map[string]interface{}{"email": "a@corp.example", "amount": 10.0},
+					map[string]interface{}{"email": "b@gmail.com", "amount": 900.0},
+				},
+				"headers": map[string]interface{}{"x.trace": "1"},
+			},
+			want: false,
+		},
+		{
+			name: "wildcard all holds",
+			conditions: []Condition{
+				{Key: "recipients[*].amount", Operator: "lt", Value: "1000", Match: "all"},
+			},
+			params: map[string]interface{}{
+				"payment": map[string]interface{}{"amount": 2500.0, "currency": "usd"},
+				"recipients": []interface{}{
+					map[string]interface{}{"email": "a@corp.example", "amount": 10.0},
+					map[string]interface{}{"email": "b@gmail.com", "amount": 900.0},
+				},
+				"headers": map[string]interface{}{"x.trace": "1"},
+			},
+			want: true,
+		},
+		{
+			name: "quoted member with dot",
+			conditions: []Condition{
+				{Key: `headers["x.trace"]`, Operator: "eq", Value: "1"},
+			},
+			params: map[string]interface{}{
+				"payment": map[string]interface{}{"amount": 2500.0, "currency": "usd"},
+				"recipients": []interface{}{
+					map[string]interface{}{"email": "a@corp.example", "amount": 10.0},
+					map[string]interface{}{"email": "b@gmail.com", "amount": 900.0},
+				},
+				"headers": map[string]interface{}{"x.trace": "1"},
+			},
+			want: true,
+		},
+		{
+			name: "member of a scalar",
+			conditions: []Condition{
+				{Key: "payment.amount.value", Operator: "gt", Value: "0"},
+			},
+			params: map[string]interface{}{
+				"payment": map[string]interface{}{"amount": 2500.0, "currency": "usd"},
+				"recipients": []interface{}{
+					map[string]interface{}{"email": "a@corp.example", "amount": 10.0},
+					map[string]interface{}{"email": "b@gmail.com", "amount": 900.0},
+				},
+				"headers": map[string]interface{}{"x.trace": "1"},
+			},
+			want: false,
+		},
+		{
+			name: "malformed path never matches",
+			conditions: []Condition{
+				{Key: "recipients[", Operator: "eq", Value: "x"},
+			},
+			params: map[string]interface{}{
+				"payment": map[string]interface{}{"amount": 2500.0, "currency": "usd"},
+				"recipients": []interface{}{
+					map[string]interface{}{"email": "a@corp.example", "amount": 10.0},
+					map[string]interface{}{"email": "b@gmail.com", "amount": 900.0},
+				},
+				"headers": map[string]interface{}{"x.trace": "1"},
+			},
+			want: false,
+		},
 	}
 
 	const maxTests = 32
@@ -234,3 +372,828 @@ func TestCheckConditions(t *testing.T) {
 		})
 	}
 }
+
+func TestConditionOperators(t *testing.T) {
+	args := map[string]interface{}{
+		"email":  "Alice@Corp.example",
+		"amount": 250.0,
+		"tags":   []interface{}{"billing", "urgent"},
+		"note":   nil,
+		"name":   "héllo",
+	}
+	tests := []struct {
+		cond Condition
+		want bool
+	}{
+		{Condition{Key: "email", Operator: "ne", Value: "bob@corp.example"}, true},
+		{Condition{Key: "email", Operator: "ne_ci", Value: "alice@corp.example"}, false},
+		{Condition{Key: "email", Operator: "eq_ci", Value: "alice@corp.example"}, true},
+		{Condition{Key: "email", Operator: "contains", Value: "@Corp"}, true},
+		{Condition{Key: "email", Operator: "contains", Value: "@corp"}, false},
+		{Condition{Key: "email", Operator: "contains_ci", Value: "@CORP"}, true},
+		{Condition{Key: "email", Operator: "prefix", Value: "Alice"}, true},
+		{Condition{Key: "email", Operator: "suffix_ci", Value: ".EXAMPLE"}, true},
+		{Condition{Key: "email", Operator: "suffix", Value: ".com"}, false},
+		{Condition{Key: "email", Operator: "regex", Value: `^[a-z]+@`}, false},
+		{Condition{Key: "email", Operator: "regex_ci", Value: `^[a-z]+@`}, true},
+		{Condition{Key: "amount", Operator: "regex", Value: `^2\d+$`}, true},
+		{Condition{Key: "amount", Operator: "in", Values: []string{"100", "250"}}, true},
+		{Condition{Key: "tags[*]", Operator: "in", Values: []string{"urgent"}}, true},
+		{Condition{Key: "tags[*]", Operator: "not_in", Values: []string{"billing", "urgent"}}, false},
+		{Condition{Key: "email", Operator: "not_in_ci", Values: []string{"ALICE@CORP.EXAMPLE"}}, false},
+		{Condition{Key: "note", Operator: "exists"}, true},
+		{Condition{Key: "missing", Operator: "exists"}, false},
+		{Condition{Key: "tags", Operator: "length_gt", Value: "1"}, true},
+		{Condition{Key: "name", Operator: "length_gt", Value: "5"}, false},
+		{Condition{Key: "amount", Operator: "length_gt", Value: "0"}, false},
+		{Condition{Key: "tags", Operator: "contains", Value: "billing"}, false},
+		{Condition{Key: "email", Operator: "equals", Value: "x"}, false},
+		{Condition{Key: "email", Operator: ""}, false},
+	}
+	for _, tt := range tests {
+		if got := CheckConditions([]Condition{tt.cond}, args); got != tt.want {
+			t.Errorf("%s %s %q%v: got %v, want %v", tt.cond.Key, tt.cond.Operator, tt.cond.Value, tt.cond.Values, got, tt.want)
+		}
+	}
+}
+
+func TestConditionGroups(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "policy.yaml")
+	yamlBody := `
+policies:
+  - id: "risky-payment"
+    match_methods: ["stripe:*"]
+    risk_level: "high"
+    conditions:
+      - any:
+          - {key: "amount", operator: "gt", value: "1000"}
+          - not: {key: "currency", operator: "eq", value: "USD"}
+      - not:
+          all:
+            - {key: "mode", operator: "eq", value: "test"}
+            - {key: "amount", operator: "lt", value: "1000000"}
+`
+	if err := os.WriteFile(tmpFile, []byte(yamlBody), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	tests := []struct {
+		args map[string]interface{}
+		want bool
+	}{
+		{map[string]interface{}{"amount": 5000.0, "currency": "USD", "mode": "live"}, true},
+		{map[string]interface{}{"amount": 10.0, "currency": "EUR", "mode": "live"}, true},
+		{map[string]interface{}{"amount": 10.0, "currency": "USD", "mode": "live"}, false},
+		{map[string]interface{}{"amount": 5000.0, "currency": "USD", "mode": "test"}, false},
+		{map[string]interface{}{"amount": 5000000.0, "currency": "USD", "mode": "test"}, true},
+	}
+	for _, tt := range tests {
+		action := ResolveAction("tools/call", map[string]interface{}{"name": "stripe:charge", "arguments": tt.args})
+		rule := engine.Match(action, action.Args, MatchOnRequest)
+		if got := rule != nil; got != tt.want {
+			t.Errorf("args %v: matched = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestConditionDepthBound(t *testing.T) {
+	nest := func(n int) []Condition {
+		c := Condition{Key: "a", Operator: OpEq, Value: "1"}
+		for j := 0; j < n; j++ {
+			inner := c
+			c = Condition{Not: &inner}
+		}
+		return []Condition{c}
+	}
+	params := map[string]interface{}{"a": "1"}
+	if !CheckConditions(nest(6), params) {
+		t.Error("expected an even number of nots within the depth bound to hold")
+	}
+	// The comparison sits at depth 8, past maxConditionDepth, so it never holds.
+	if CheckConditions(nest(8), params) {
+		t.Error("expected a comparison past the depth bound not to hold")
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	valid := map[string]int{
+		"amount":                  1,
+		"payment.amount":          2,
+		"$.payment.amount":        2,
+		"recipients[0].email":     3,
+		"recipients[*].email":     3,
+		"items.*.price":           3,
+		`$["a.b"]['c']`:           2,
+		"matrix[1][2]":            3,
+		"$[*]":                    1,
+		"tool:name/with-chars_ok": 1,
+	}
+	for key, steps := range valid {
+		p, err := parsePath(key)
+		if err != nil {
+			t.Errorf("parsePath(%q): %v", key, err)
+			continue
+		}
+		if len(p) != steps {
+			t.Errorf("parsePath(%q): %d steps, want %d", key, len(p), steps)
+		}
+	}
+	malformed := []string{"", "$", "$x", ".a", "a.", "a..b", "a[", "a[]", "a[-1]", "a[x]", "a[0]b", `a["b`, `a["b"`, `a[""]`, "a]b", strings.Repeat("a.", maxPathSteps) + "a"}
+	for _, key := range malformed {
+		if _, err := parsePath(key); err == nil {
+			t.Errorf("parsePath(%q): expected an error", key)
+		}
+	}
+}
+
+func TestLoadConfigRejectsUnknownMatchOn(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "policy.yaml")
+	yamlBody := `
+version: "1.0"
+policies:
+  - id: "denied"
+    match_methods: ["tools/call"]
+    match_on: "error"
+    risk_level: "high"
+  - id: "bogus"
+    match_methods: ["tools/call"]
+    match_on: "headers"
+    risk_level: "low"
+`
+	if err := os.WriteFile(tmpFile, []byte(yamlBody), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewObserverEngine(tmpFile); err == nil {
+		t.Fatal("expected unknown match_on to be rejected")
+	}
+
+	rule := Rule{ID: "r"}
+	if rule.Target() != MatchOnRequest {
+		t.Errorf("expected default target %q, got %q", MatchOnRequest, rule.Target())
+	}
+}
+
+func TestResolveAction(t *testing.T) {
+	tests := []struct {
+		method   string
+		params   map[string]interface{}
+		wantName string
+		wantArgs int
+	}{
+		{"tools/call", map[string]interface{}{"name": "stripe:refund", "arguments": map[string]interface{}{"amount": 5.0}}, "stripe:refund", 1},
+		{"tools/call", map[string]interface{}{"arguments": "not-a-map"}, "tools/call", 0},
+		{"resources/read", map[string]interface{}{"uri": "file:///etc/passwd"}, "file:///etc/passwd", 1},
+		{"prompts/get", map[string]interface{}{"name": "summarize"}, "summarize", 0},
+		{"tools/list", nil, "tools/list", 0},
+	}
+	for _, tt := range tests {
+		a := ResolveAction(tt.method, tt.params)
+		if a.Name != tt.wantName {
+			t.Errorf("%s: expected name %q, got %q", tt.method, tt.wantName, a.Name)
+		}
+		if a.Args == nil || len(a.Args) != tt.wantArgs {
+			t.Errorf("%s: expected %d args, got %v", tt.method, tt.wantArgs, a.Args)
+		}
+	}
+}
+
+func TestMatchResolvedAction(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "policy.yaml")
+	yamlBody := `
+version: "1.0"
+policies:
+  - id: "big-refund"
+    match_methods: ["stripe:*"]
+    risk_level: "critical"
+    conditions:
+      - key: "amount"
+        operator: "gt"
+        value: "1000"
+  - id: "any-tool"
+    match_methods: ["tools/call"]
+    risk_level: "low"
+`
+	if err := os.WriteFile(tmpFile, []byte(yamlBody), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	call := func(amount float64) Action {
+		return ResolveAction("tools/call", map[string]interface{}{
+			"name":      "stripe:refund",
+			"arguments": map[string]interface{}{"amount": amount},
+		})
+	}
+	big := call(5000)
+	if rule := engine.Match(big, big.Args, MatchOnRequest); rule == nil || rule.ID != "big-refund" {
+		t.Errorf("expected big-refund, got %v", rule)
+	}
+	small := call(10)
+	if rule := engine.Match(small, small.Args, MatchOnRequest); rule == nil || rule.ID != "any-tool" {
+		t.Errorf("expected fallback to method pattern any-tool, got %v", rule)
+	}
+	if rule := engine.Match(big, big.Args, MatchOnError); rule != nil {
+		t.Errorf("expected no error rule, got %s", rule.ID)
+	}
+}
+
+func TestPatternSet(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		name     string
+		want     bool
+	}{
+		{[]string{"stripe:refund"}, "stripe:refund", true},
+		{[]string{"stripe:refund"}, "stripe:refunds", false},
+		{[]string{"*:delete*"}, "aws:delete_bucket", true},
+		{[]string{"*:delete*"}, "aws:create_bucket", false},
+		{[]string{"github:{create,delete}_repo"}, "github:delete_repo", true},
+		{[]string{"github:{create,delete}_repo"}, "github:rename_repo", false},
+		{[]string{"fs:[rw]*"}, "fs:write_file", true},
+		{[]string{"fs:[!rw]*"}, "fs:write_file", false},
+		{[]string{"fs:?ead"}, "fs:read", true},
+		{[]string{`docs:\*`}, "docs:*", true},
+		{[]string{`docs:\*`}, "docs:x", false},
+		{[]string{"^aws:(iam|kms):.*"}, "aws:kms:decrypt", true},
+		{[]string{"^aws:(iam|kms):.*"}, "aws:s3:get", false},
+		{[]string{"aws:*", "!aws:describe*"}, "aws:delete_bucket", true},
+		{[]string{"aws:*", "!aws:describe*"}, "aws:describe_instances", false},
+		{[]string{"*", "!^fs:read"}, "fs:read_file", false},
+		{[]string{"gh:{repo_{create,delete},issue}"}, "gh:repo_delete", true},
+		{[]string{"gh:{repo_{create,delete},issue}"}, "gh:issue", true},
+		{[]string{"gh:{repo_{create,delete},issue}"}, "gh:repo_issue", false},
+		{[]string{"a,b}"}, "a,b}", true},
+	}
+	for _, tt := range tests {
+		set, err := CompilePatterns(tt.patterns)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.patterns, err)
+		}
+		if got := set.Match(tt.name); got != tt.want {
+			t.Errorf("%q matching %q: got %v, want %v", tt.patterns, tt.name, got, tt.want)
+		}
+	}
+
+	// An exclusion vetoes the call whichever name it matches.
+	set, err := CompilePatterns([]string{"tools/*", "!stripe:*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Match("stripe:refund", "tools/call") {
+		t.Error("expected exclusion on the action name to veto the method")
+	}
+	if !set.Match("fs:read", "tools/call") {
+		t.Error("expected tools/call to be selected")
+	}
+
+	for _, bad := range [][]string{{"fs:[rw"}, {"gh:{a,b"}, {"gh:{a}"}, {"^aws:("}, {`x\`}, {"!aws:*"}, {""}, {strings.Repeat("{a,", 9) + "b" + strings.Repeat("}", 9)}} {
+		if _, err := CompilePatterns(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestRedactPayload(t *testing.T) {
+	rule := Rule{ID: "r", MatchOn: MatchOnResponse, Redact: []string{"content[*].text", "meta.token", "missing.key", "rows[5]"}}
+	payload := map[string]interface{}{
+		"content": []interface{}{
+			map[string]interface{}{"type": "text", "text": "a"},
+			map[string]interface{}{"type": "text", "text": "b"},
+		},
+		"meta": map[string]interface{}{"token": "t", "user": "u"},
+		"rows": []interface{}{1.0},
+	}
+	if n := rule.RedactPayload(payload); n != 3 {
+		t.Errorf("expected 3 values redacted, got %d", n)
+	}
+	content := payload["content"].([]interface{})
+	for _, item := range content {
+		if text := item.(map[string]interface{})["text"]; text != redactedValue {
+			t.Errorf("expected text redacted, got %v", text)
+		}
+	}
+	meta := payload["meta"].(map[string]interface{})
+	if meta["token"] != redactedValue || meta["user"] != "u" {
+		t.Errorf("unexpected meta: %+v", meta)
+	}
+	if _, ok := payload["missing"]; ok {
+		t.Error("expected missing paths not to be created")
+	}
+
+	request := Rule{ID: "r", Redact: []string{"meta.user"}}
+	if n := request.RedactPayload(payload); n != 0 || meta["user"] != "u" {
+		t.Error("expected request rules to leave response payloads alone")
+	}
+
+	// Past maxPathValues the whole payload is redacted rather than a prefix.
+	items := make([]interface{}, maxPathValues+1)
+	for j := range items {
+		items[j] = map[string]interface{}{"text": "secret"}
+	}
+	huge := map[string]interface{}{"content": items, "isError": false}
+	if n := rule.RedactPayload(huge); n != 2 || huge["content"] != redactedValue || huge["isError"] != redactedValue {
+		t.Errorf("expected the whole payload redacted, got %d values replaced", n)
+	}
+}
+
+func TestLoadConfigValidatesAction(t *testing.T) {
+	tests := map[string]string{
+		"unknown action": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    action: "drop"
+`,
+		"approval on error": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    match_on: "error"
+    action: "require_approval"
+`,
+		"unknown default decision": `
+approvals:
+  default_decision: "maybe"
+policies: []
+`,
+		"deny on error": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    match_on: "error"
+    action: "deny"
+`,
+		"rate limit on response": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    match_on: "response"
+    rate_limit: {max_calls: 5, window_seconds: 60}
+`,
+		"rate limit without window": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    rate_limit: {max_calls: 5}
+`,
+		"unknown rate limit key": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    rate_limit: {max_calls: 5, window_seconds: 60, per: "session"}
+`,
+		"unknown identity source": `
+identity:
+  sources: ["kerberos"]
+policies: []
+`,
+		"header source without header": `
+identity:
+  sources: ["header"]
+policies: []
+`,
+		"reserved agent name": `
+identity:
+  bearer_tokens: {"tok-1": "system"}
+policies: []
+`,
+		"malformed token digest": `
+identity:
+  bearer_tokens: {"sha256:abc": "ci-bot"}
+policies: []
+`,
+		"mirror without match_methods": `
+mirror:
+  target: "http://localhost:8090"
+policies: []
+`,
+		"mirror of every call": `
+mirror:
+  target: "http://localhost:8090"
+  match_methods: ["*"]
+policies: []
+`,
+		"mirror of every call, double star": `
+mirror:
+  target: "http://localhost:8090"
+  match_methods: ["**"]
+policies: []
+`,
+		"mirror of every namespaced tool": `
+mirror:
+  target: "http://localhost:8090"
+  match_methods: ["fs:read*", "*:*"]
+policies: []
+`,
+		"mirror of every tools method": `
+mirror:
+  target: "http://localhost:8090"
+  match_methods: ["tools/*"]
+policies: []
+`,
+		"mirror of every call by regex": `
+mirror:
+  target: "http://localhost:8090"
+  match_methods: ["^.*"]
+policies: []
+`,
+		"mirror of a namespace with writes": `
+mirror:
+  target: "http://localhost:8090"
+  match_methods: ["fs:*"]
+policies: []
+`,
+		"mirror target not a URL": `
+mirror:
+  target: "localhost:8090"
+  match_methods: ["tools/list"]
+policies: []
+`,
+		"malformed condition path": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - {key: "payment..amount", operator: "gt", value: "1"}
+`,
+		"unknown condition match": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - {key: "items[*].price", operator: "gt", value: "1", match: "most"}
+`,
+		"condition and group mixed": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - {key: "a", operator: "eq", value: "1", any: [{key: "b", operator: "eq", value: "2"}]}
+`,
+		"empty any group": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - any: []
+`,
+		"malformed path inside group": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - all: [{not: {key: "a[x]", operator: "eq", value: "1"}}]
+`,
+		"conditions nested too deep": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - {not: {not: {not: {not: {not: {not: {not: {not: {not: {key: "a", operator: "eq", value: "1"}}}}}}}}}}
+`,
+		"unknown operator": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - {key: "a", operator: "equals", value: "1"}
+`,
+		"missing operator": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - {key: "a", value: "1"}
+`,
+		"invalid regex": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - {key: "a", operator: "regex", value: "(unclosed"}
+`,
+		"non-numeric gt value": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - {key: "a", operator: "gt", value: "lots"}
+`,
+		"negative length": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - {key: "a", operator: "length_gt", value: "-1"}
+`,
+		"in without values": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - {key: "a", operator: "in", value: "x"}
+`,
+		"values on eq": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - {key: "a", operator: "eq", values: ["x"]}
+`,
+		"case-insensitive gt": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - {key: "a", operator: "gt_ci", value: "1"}
+`,
+		"value on exists": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    conditions:
+      - {key: "a", operator: "exists", value: "yes"}
+`,
+		"deny on response": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    match_on: "response"
+    action: "deny"
+`,
+		"malformed response redact path": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    match_on: "response"
+    redact: ["content[x].text"]
+`,
+		"malformed glob": `
+policies:
+  - id: "r"
+    match_methods: ["github:{create,delete"]
+`,
+		"invalid regex pattern": `
+policies:
+  - id: "r"
+    match_methods: ["^aws:(iam"]
+`,
+		"exclusions only": `
+policies:
+  - id: "r"
+    match_methods: ["!aws:describe*"]
+`,
+		"empty actor pattern": `
+policies:
+  - id: "r"
+    match_methods: ["tools/call"]
+    match_actors: [""]
+`,
+		"unknown ledger_unavailable mode": `
+ledger_unavailable: "queue"
+policies: []
+`,
+	}
+	for name, body := range tests {
+		tmpFile := filepath.Join(t.TempDir(), "policy.yaml")
+		if err := os.WriteFile(tmpFile, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewObserverEngine(tmpFile); err == nil {
+			t.Errorf("%s: expected config to be rejected", name)
+		}
+	}
+
+	rule := Rule{ID: "r"}
+	if rule.Effect() != RuleActionTag {
+		t.Errorf("expected default action %q, got %q", RuleActionTag, rule.Effect())
+	}
+}
+
+func TestRateLimitRule(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "policy.yaml")
+	yamlBody := `
+policies:
+  - id: "tool-loop"
+    match_methods: ["tools/call"]
+    risk_level: "high"
+    rate_limit: {max_calls: 2, window_seconds: 60, per: "method"}
+  - id: "stripe"
+    match_methods: ["stripe:*"]
+    risk_level: "medium"
+`
+	if err := os.WriteFile(tmpFile, []byte(yamlBody), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+
+	refund := ResolveAction("tools/call", map[string]interface{}{"name": "stripe:refund"})
+	for call := 1; call <= 3; call++ {
+		want := "stripe"
+		if call == 3 {
+			want = "tool-loop"
+		}
+		if rule := engine.Match(refund, refund.Args, MatchOnRequest); rule == nil || rule.ID != want {
+			t.Fatalf("call %d: expected %s, got %+v", call, want, rule)
+		}
+	}
+	// Counted per method: another tool has its own window.
+	query := ResolveAction("tools/call", map[string]interface{}{"name": "db:query"})
+	if rule := engine.Match(query, query.Args, MatchOnRequest); rule != nil {
+		t.Fatalf("expected no match for db:query, got %s", rule.ID)
+	}
+
+	// Peek counts earlier actions of the slice but records nothing.
+	search := ResolveAction("tools/call", map[string]interface{}{"name": "web:search"})
+	rules := engine.Peek([]Action{search, search, search})
+	if len(rules) != 3 || rules[1] != nil || rules[2] == nil || rules[2].ID != "tool-loop" {
+		t.Fatalf("unexpected peek result: %+v", rules)
+	}
+	if rule := engine.Match(search, search.Args, MatchOnRequest); rule != nil {
+		t.Fatalf("peek must not count calls, got %s", rule.ID)
+	}
+
+	windows, exceeded := engine.RateWindows()
+	if len(windows) != 3 || exceeded["tool-loop"] != 1 {
+		t.Fatalf("unexpected counters: %+v %v", windows, exceeded)
+	}
+}
+
+func TestRateLimitDenyOverridesEarlierTagRule(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "policy.yaml")
+	yamlBody := `
+policies:
+  - id: "k8s-watch"
+    match_methods: ["kubernetes:*"]
+    risk_level: "medium"
+  - id: "k8s-delete-loop"
+    match_methods: ["kubernetes:delete"]
+    action: "deny"
+    risk_level: "critical"
+    rate_limit: {max_calls: 2, window_seconds: 60}
+`
+	if err := os.WriteFile(tmpFile, []byte(yamlBody), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+
+	del := ResolveAction("tools/call", map[string]interface{}{"name": "kubernetes:delete"})
+	for call := 1; call <= 3; call++ {
+		want := "k8s-watch"
+		if call == 3 {
+			want = "k8s-delete-loop"
+		}
+		if rule := engine.Match(del, del.Args, MatchOnRequest); rule == nil || rule.ID != want {
+			t.Fatalf("call %d: expected %s, got %+v", call, want, rule)
+		}
+	}
+	if rules := engine.Peek([]Action{del}); len(rules) != 1 || rules[0] == nil || rules[0].Effect() != RuleActionDeny {
+		t.Fatalf("expected peek to report the deny rule, got %+v", rules)
+	}
+}
+
+func TestRateLimitCountsErrors(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "policy.yaml")
+	yamlBody := `
+policies:
+  - id: "permission-denied"
+    match_methods: ["tools/*"]
+    match_on: "error"
+    risk_level: "high"
+    conditions:
+      - {key: "message", operator: "regex_ci", value: "permission denied"}
+    rate_limit: {max_calls: 2, window_seconds: 60, per: "actor"}
+`
+	if err := os.WriteFile(tmpFile, []byte(yamlBody), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+
+	call := Action{Method: "tools/call", Name: "fs:read_file", Actor: "ci-bot"}
+	denied := map[string]interface{}{"code": -32603.0, "message": "Permission denied: /etc/shadow"}
+	other := map[string]interface{}{"code": -32602.0, "message": "Invalid params"}
+	if rule := engine.Match(call, other, MatchOnError); rule != nil {
+		t.Fatalf("expected other errors not to match, got %s", rule.ID)
+	}
+	for n := 1; n <= 3; n++ {
+		rule := engine.Match(call, denied, MatchOnError)
+		if (n == 3) != (rule != nil) {
+			t.Fatalf("error %d: unexpected match %+v", n, rule)
+		}
+	}
+	// Shadow errors see the window but are not counted.
+	if rule := engine.MatchUncounted(call, denied, MatchOnError); rule == nil {
+		t.Fatal("expected the over-limit window to match uncounted errors")
+	}
+	if windows, exceeded := engine.RateWindows(); len(windows) != 1 || windows[0].Key != "ci-bot" || exceeded["permission-denied"] != 1 {
+		t.Fatalf("unexpected counters: %+v %v", windows, exceeded)
+	}
+}
+
+func TestRateWindowSlides(t *testing.T) {
+	rule := &Rule{ID: "r", RateLimit: &RateLimit{MaxCalls: 4, WindowSeconds: 10}}
+	tracker := newRateTracker()
+	start := time.Unix(1700000000, 0).Truncate(10 * time.Second)
+
+	for i := 0; i < 4; i++ {
+		if tracker.over(rule, Action{Name: "x"}, start, nil) {
+			t.Fatalf("call %d within limit reported over", i+1)
+		}
+	}
+	if !tracker.over(rule, Action{Name: "x"}, start.Add(time.Second), nil) {
+		t.Fatal("fifth call in the window must be over")
+	}
+	// Halfway through the next bucket half of the previous bucket's calls still count.
+	if tracker.over(rule, Action{Name: "x"}, start.Add(15*time.Second), nil) {
+		t.Fatal("expected call to fit once the window slid")
+	}
+	// Two windows later nothing is left.
+	windows, _ := tracker.snapshot(start.Add(30 * time.Second))
+	if len(windows) != 1 || windows[0].Calls != 0 {
+		t.Fatalf("expected an empty window, got %+v", windows)
+	}
+}
+
+func TestRateTableFullSharesOverflowCounter(t *testing.T) {
+	rule := &Rule{ID: "r", RateLimit: &RateLimit{MaxCalls: 2, WindowSeconds: 60, Per: RatePerMethod}}
+	tracker := newRateTracker()
+	now := time.Unix(1700000000, 0)
+	for i := 0; i < maxRateKeys; i++ {
+		tracker.over(rule, Action{Name: fmt.Sprintf("tool-%d", i)}, now, nil)
+	}
+
+	// Each further tool name is new, but they all land in one counter.
+	for i := 0; i < 2; i++ {
+		if tracker.over(rule, Action{Name: fmt.Sprintf("spray-%d", i)}, now, nil) {
+			t.Fatalf("overflow call %d within limit reported over", i+1)
+		}
+	}
+	if !tracker.over(rule, Action{Name: "spray-2"}, now, nil) {
+		t.Fatal("expected a full table not to let calls escape the limit")
+	}
+	windows, _ := tracker.snapshot(now)
+	if len(windows) != maxRateKeys+1 {
+		t.Errorf("expected one overflow counter past the cap, got %d counters", len(windows))
+	}
+}
+
+func TestMatchActors(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "policy.yaml")
+	yamlBody := `
+identity:
+  bearer_tokens:
+    "tok-billing": "billing-bot"
+    "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08": "ci-runner"
+policies:
+  - id: "ci-no-refunds"
+    match_methods: ["stripe:refund"]
+    match_actors: ["ci-*"]
+    action: "deny"
+  - id: "refunds"
+    match_methods: ["stripe:*"]
+    risk_level: "high"
+`
+	if err := os.WriteFile(tmpFile, []byte(yamlBody), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := NewObserverEngine(tmpFile)
+	if err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+
+	identity := engine.GetIdentity()
+	if name, ok := identity.TokenName("tok-billing"); !ok || name != "billing-bot" {
+		t.Errorf("expected tok-billing to name billing-bot, got %q", name)
+	}
+	// Configured by digest: sha256("test").
+	if name, ok := identity.TokenName("test"); !ok || name != "ci-runner" {
+		t.Errorf("expected digest-configured token to name ci-runner, got %q", name)
+	}
+	if _, ok := identity.TokenName("unknown"); ok {
+		t.Error("expected unknown token to have no name")
+	}
+
+	refund := ResolveAction("tools/call", map[string]interface{}{"name": "stripe:refund"})
+	for actor, want := range map[string]string{"ci-runner": "ci-no-refunds", "billing-bot": "refunds", "": "refunds"} {
+		refund.Actor = actor
+		if rule := engine.Match(refund, refund.Args, MatchOnRequest); rule == nil || rule.ID != want {
+			t.Errorf("actor %q: expected %s, got %+v", actor, want, rule)
+		}
+	}
+}
@@ -25,6 +25,9 @@ func FuzzMatchPattern(f *testing.F) {
 	f.Add("", "")
 	f.Add("*", "anything")
 	f.Add("test", "test")
+	f.Add("github:{create,delete}_repo", "github:delete_repo")
+	f.Add("fs:[!w]*", "fs:read")
+	f.Add("^aws:(iam|kms):.*", "aws:kms:decrypt")
 
 	f.Fuzz(func(t *testing.T, pattern, method string) {
 		// Should never panic
@@ -65,11 +68,11 @@ func FuzzCheckConditions(f *testing.F) {
 
 	f.Fuzz(func(t *testing.T, key, operator, value, actualValue string) {
 		// Build condition and params
-		conditions := []map[string]string{
+		conditions := []Condition{
 			{
-				"key":      key,
-				"operator": operator,
-				"value":    value,
+				Key:      key,
+				Operator: operator,
+				Value:    value,
 			},
 		}
 		params := map[string]interface{}{
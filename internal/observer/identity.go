@@ -0,0 +1,129 @@
+package observer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Identity configures how the agent behind a request is named; the name is
+// recorded as Event.Actor and can be matched by match_actors. Sources are tried
+// in order and the first that yields a name wins:
+//
+//	"mtls"   the subject common name of a verified client certificate
+//	         (the full subject DN when it has no CN)
+//	"bearer" the Authorization: Bearer token, looked up in BearerTokens
+//	"header" the value of Header, for agents behind an authenticating gateway
+//
+// Default names requests no source identified, such as the agent of a stdio relay.
+type Identity struct {
+	Sources      []string          `yaml:"sources,omitempty"`       // Default: mtls, bearer, header
+	BearerTokens map[string]string `yaml:"bearer_tokens,omitempty"` // Token, or "sha256:<hex>" of it -> name
+	Header       string            `yaml:"header,omitempty"`        // e.g. X-Agent-Name
+	Default      string            `yaml:"default,omitempty"`
+
+	tokens map[string]string // Hex SHA-256 of each token -> name, built on load
+}
+
+// Values of Identity.Sources.
+const (
+	IdentityMTLS   = "mtls"
+	IdentityBearer = "bearer"
+	IdentityHeader = "header"
+)
+
+// Actors that Logryph records itself; agents cannot take these names.
+var reservedActors = map[string]bool{"system": true, "user": true}
+
+const (
+	maxBearerTokens = 1024
+	maxActorBytes   = 128
+	tokenDigestPfx  = "sha256:"
+)
+
+var defaultIdentitySources = []string{IdentityMTLS, IdentityBearer, IdentityHeader}
+
+// IdentitySources returns the sources to try, in order.
+func (id Identity) IdentitySources() []string {
+	if len(id.Sources) == 0 {
+		return defaultIdentitySources
+	}
+	return id.Sources
+}
+
+// TokenName returns the agent name of a bearer token.
+func (id Identity) TokenName(token string) (string, bool) {
+	if token == "" || len(id.tokens) == 0 {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(token))
+	name, ok := id.tokens[hex.EncodeToString(sum[:])]
+	return name, ok
+}
+
+// ValidActor reports whether name can be recorded as an agent identity: at most
+// maxActorBytes of printable ASCII, and not a name Logryph uses itself. Names
+// taken from request headers are checked too, since they come from the client.
+func ValidActor(name string) bool {
+	if name == "" || len(name) > maxActorBytes || reservedActors[name] {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < 0x20 || name[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// compile validates the identity settings and indexes the bearer tokens by digest,
+// so tokens can be configured as digests and are never compared in clear.
+func (id *Identity) compile() error {
+	seen := make(map[string]bool, len(id.Sources))
+	for i := 0; i < len(id.Sources); i++ {
+		source := id.Sources[i]
+		switch source {
+		case IdentityMTLS:
+		case IdentityBearer:
+			if len(id.BearerTokens) == 0 {
+				return fmt.Errorf("identity: source %q needs bearer_tokens", source)
+			}
+		case IdentityHeader:
+			if id.Header == "" {
+				return fmt.Errorf("identity: source %q needs header", source)
+			}
+		default:
+			return fmt.Errorf("identity: unknown source %q", source)
+		}
+		if seen[source] {
+			return fmt.Errorf("identity: source %q listed twice", source)
+		}
+		seen[source] = true
+	}
+	if len(id.BearerTokens) > maxBearerTokens {
+		return fmt.Errorf("identity: too many bearer_tokens: %d (max %d)", len(id.BearerTokens), maxBearerTokens)
+	}
+	if id.Default != "" && !ValidActor(id.Default) {
+		return fmt.Errorf("identity: invalid default %q", id.Default)
+	}
+
+	id.tokens = make(map[string]string, len(id.BearerTokens))
+	for token, name := range id.BearerTokens {
+		if !ValidActor(name) {
+			return fmt.Errorf("identity: invalid agent name %q", name)
+		}
+		digest := strings.ToLower(strings.TrimPrefix(token, tokenDigestPfx))
+		if !strings.HasPrefix(token, tokenDigestPfx) {
+			sum := sha256.Sum256([]byte(token))
+			digest = hex.EncodeToString(sum[:])
+		} else if raw, err := hex.DecodeString(digest); err != nil || len(raw) != sha256.Size {
+			return fmt.Errorf("identity: token digest for %q is not a hex SHA-256", name)
+		}
+		if _, dup := id.tokens[digest]; dup {
+			return fmt.Errorf("identity: bearer token for %q listed twice", name)
+		}
+		id.tokens[digest] = name
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package observer
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Mirror configures traffic mirroring: after a selected request is forwarded, a
+// copy is sent to Target, a shadow tool server (e.g. an upgraded build), and its
+// response is recorded and compared with the primary one. Only calls that are
+// safe to run twice should be mirrored, so nothing is selected by default:
+// MatchMethods lists the read-only actions (tool names, resource URIs, prompt
+// names or methods such as "tools/list"), and patterns that select any of
+// mutatingProbes are refused. Requests a rule denies or holds are never
+// forwarded, so they are never mirrored either.
+//
+// Shadow requests carry no Mcp-Session-Id and the shadow server never sees the
+// agent's initialize, so Target must answer calls without a session (a
+// stateless Streamable HTTP server).
+type Mirror struct {
+	Target       string   `yaml:"target,omitempty"`        // Shadow server URL; empty disables mirroring
+	MatchMethods []string `yaml:"match_methods,omitempty"` // Read-only actions to mirror, e.g. "fs:read*"
+	Upstream     string   `yaml:"upstream,omitempty"`      // Only mirror requests routed to this upstream
+	TimeoutMs    int      `yaml:"timeout_ms,omitempty"`    // Default 5000
+
+	methods *PatternSet // Compiled MatchMethods, set by validate
+}
+
+// mutatingProbes are MCP methods and typical tool names with side effects. It
+// is a heuristic against catch-alls, not a list of every mutating tool: a
+// pattern list selecting one of them ("*", "**", "*:*", "tools/*", "^.*"...) is
+// too broad to be limited to read-only calls, but a pattern naming some other
+// mutating tool passes, so match_methods still has to be reviewed.
+var mutatingProbes = []string{
+	"tools/call", "initialize", "logging/setLevel", "resources/subscribe", "resources/unsubscribe",
+	"delete", "write", "exec", "run", "send",
+	"fs:write_file", "fs:delete_file", "shell:exec", "kubernetes:delete",
+	"github:create_repo", "stripe:refund", "email:send", "db:execute",
+}
+
+// Mirror defaults and bounds.
+const (
+	DefaultMirrorTimeout = 5 * time.Second
+	maxMirrorTimeout     = 60 * time.Second
+)
+
+// Enabled reports whether mirroring is configured.
+func (m Mirror) Enabled() bool {
+	return m.Target != ""
+}
+
+// Timeout returns how long to wait for the shadow server.
+func (m Mirror) Timeout() time.Duration {
+	if m.TimeoutMs <= 0 {
+		return DefaultMirrorTimeout
+	}
+	return time.Duration(m.TimeoutMs) * time.Millisecond
+}
+
+// Selects reports whether a forwarded request with this action, routed to
+// upstream ("" for the default target), is mirrored. Patterns are matched
+// against the resolved name only, so "tools/call" does not select every tool.
+func (m Mirror) Selects(action Action, upstream string) bool {
+	if !m.Enabled() || action.Name == "" {
+		return false
+	}
+	if m.Upstream != "" && m.Upstream != upstream {
+		return false
+	}
+	methods := m.methods
+	if methods == nil {
+		methods, _ = CompilePatterns(m.MatchMethods)
+	}
+	return methods.Match(action.Name)
+}
+
+func (m *Mirror) validate() error {
+	if !m.Enabled() {
+		return nil
+	}
+	u, err := url.Parse(m.Target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("mirror: target %q must be an http(s) URL", m.Target)
+	}
+	if len(m.MatchMethods) == 0 {
+		return fmt.Errorf("mirror: match_methods must list the read-only actions to mirror")
+	}
+	if len(m.MatchMethods) > maxRulePatterns {
+		return fmt.Errorf("mirror: too many match_methods: %d (max %d)", len(m.MatchMethods), maxRulePatterns)
+	}
+	methods, err := CompilePatterns(m.MatchMethods)
+	if err != nil {
+		return fmt.Errorf("mirror: match_methods: %w", err)
+	}
+	for i := 0; i < len(mutatingProbes); i++ {
+		if methods.Match(mutatingProbes[i]) {
+			return fmt.Errorf("mirror: match_methods %q would mirror calls with side effects such as %q; list the read-only actions", m.MatchMethods, mutatingProbes[i])
+		}
+	}
+	m.methods = methods
+	if m.TimeoutMs < 0 || time.Duration(m.TimeoutMs)*time.Millisecond > maxMirrorTimeout {
+		return fmt.Errorf("mirror: timeout_ms must be between 0 and %d", maxMirrorTimeout.Milliseconds())
+	}
+	return nil
+}
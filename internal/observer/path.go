@@ -0,0 +1,257 @@
+package observer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Bounds on condition paths and the values one path may select.
+const (
+	maxPathBytes  = 256
+	maxPathSteps  = 32
+	maxPathValues = 1024
+)
+
+// Values of a condition's "match" key: how a path selecting several values
+// (through a wildcard) is judged.
+const (
+	PathMatchAny = "any" // Some selected value satisfies the operator (default)
+	PathMatchAll = "all" // At least one value is selected and every one satisfies it
+)
+
+type stepKind int
+
+const (
+	stepKey      stepKind = iota // Object member
+	stepIndex                    // Array element
+	stepWildcard                 // Every member or element
+)
+
+// pathStep is one segment of a condition path.
+type pathStep struct {
+	kind  stepKind
+	key   string
+	index int
+}
+
+// path is a parsed condition key, addressing values inside the arguments.
+type path []pathStep
+
+// parsePath parses a condition key. Keys are dot paths relative to the
+// arguments, optionally written as JSONPath with a leading "$":
+//
+//	amount
+//	payment.amount
+//	recipients[0].email
+//	recipients[*].email, items.*.price
+//	$.headers["x-api-key"]
+//
+// A bare segment may not be empty or contain brackets or quotes; members whose
+// names contain dots are written as quoted brackets.
+func parsePath(key string) (path, error) {
+	if key == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	if len(key) > maxPathBytes {
+		return nil, fmt.Errorf("path exceeds %d bytes", maxPathBytes)
+	}
+	rest := key
+	if strings.HasPrefix(rest, "$") {
+		rest = rest[1:]
+		if rest == "" {
+			return nil, fmt.Errorf("path %q selects the whole arguments object", key)
+		}
+		if rest[0] != '.' && rest[0] != '[' {
+			return nil, fmt.Errorf("path %q: expected '.' or '[' after '$'", key)
+		}
+		if rest[0] == '.' {
+			rest = rest[1:]
+		}
+	}
+
+	var p path
+	for i := 0; i < maxPathSteps+1; i++ {
+		if rest == "" {
+			return p, nil
+		}
+		if len(p) == maxPathSteps {
+			return nil, fmt.Errorf("path %q exceeds %d segments", key, maxPathSteps)
+		}
+		var step pathStep
+		var err error
+		if rest[0] == '[' {
+			step, rest, err = parseBracket(rest)
+		} else {
+			step, rest, err = parseSegment(rest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("path %q: %w", key, err)
+		}
+		p = append(p, step)
+		if rest == "" || rest[0] == '[' {
+			continue
+		}
+		if rest[0] != '.' || len(rest) == 1 {
+			return nil, fmt.Errorf("path %q: unexpected %q", key, rest)
+		}
+		rest = rest[1:]
+	}
+	return nil, fmt.Errorf("path %q exceeds %d segments", key, maxPathSteps)
+}
+
+// parseSegment reads a bare member name (or "*") up to the next '.' or '['.
+func parseSegment(s string) (pathStep, string, error) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	name := s[:end]
+	if name == "" {
+		return pathStep{}, "", fmt.Errorf("empty segment")
+	}
+	if strings.ContainsAny(name, `]'"`) {
+		return pathStep{}, "", fmt.Errorf("invalid segment %q", name)
+	}
+	if name == "*" {
+		return pathStep{kind: stepWildcard}, s[end:], nil
+	}
+	return pathStep{kind: stepKey, key: name}, s[end:], nil
+}
+
+// parseBracket reads [n], [*], ['name'] or ["name"].
+func parseBracket(s string) (pathStep, string, error) {
+	if len(s) > 1 && (s[1] == '\'' || s[1] == '"') {
+		closing := strings.IndexByte(s[2:], s[1])
+		if closing < 0 {
+			return pathStep{}, "", fmt.Errorf("unterminated quoted member")
+		}
+		name := s[2 : 2+closing]
+		rest := s[2+closing+1:]
+		if name == "" {
+			return pathStep{}, "", fmt.Errorf("empty quoted member")
+		}
+		if !strings.HasPrefix(rest, "]") {
+			return pathStep{}, "", fmt.Errorf("expected ']' after quoted member")
+		}
+		return pathStep{kind: stepKey, key: name}, rest[1:], nil
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return pathStep{}, "", fmt.Errorf("unterminated '['")
+	}
+	inner := s[1:end]
+	if inner == "*" {
+		return pathStep{kind: stepWildcard}, s[end+1:], nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil || index < 0 || strings.HasPrefix(inner, "+") {
+		return pathStep{}, "", fmt.Errorf("invalid array index %q", inner)
+	}
+	return pathStep{kind: stepIndex, index: index}, s[end+1:], nil
+}
+
+// resolve returns the values the path selects in root, at most maxPathValues.
+// Steps that do not apply (a member of an array, an index past the end) select
+// nothing.
+func (p path) resolve(root map[string]interface{}) []interface{} {
+	frontier := []interface{}{root}
+	for i := 0; i < maxPathSteps; i++ {
+		if i >= len(p) || len(frontier) == 0 {
+			break
+		}
+		step := p[i]
+		next := make([]interface{}, 0, len(frontier))
+		for j := 0; j < len(frontier) && len(next) < maxPathValues; j++ {
+			next = step.apply(frontier[j], next)
+		}
+		frontier = next
+	}
+	return frontier
+}
+
+// assign replaces the values the path selects in root with value and returns
+// how many it replaced. Like resolve it never creates members or elements.
+// complete is false when the path may select more than maxPathValues values, in
+// which case some were left unreplaced.
+func (p path) assign(root map[string]interface{}, value interface{}) (replaced int, complete bool) {
+	if len(p) == 0 {
+		return 0, true
+	}
+	parents := p[:len(p)-1].resolve(root)
+	if len(parents) >= maxPathValues {
+		return 0, false
+	}
+	last := p[len(p)-1]
+	for i := 0; i < len(parents) && replaced < maxPathValues; i++ {
+		replaced += last.set(parents[i], value, maxPathValues-replaced)
+	}
+	return replaced, replaced < maxPathValues
+}
+
+// set replaces at most budget children of v selected by the step with value.
+func (s pathStep) set(v interface{}, value interface{}, budget int) int {
+	replaced := 0
+	switch s.kind {
+	case stepKey:
+		if m, ok := v.(map[string]interface{}); ok {
+			if _, ok := m[s.key]; ok {
+				m[s.key] = value
+				replaced++
+			}
+		}
+	case stepIndex:
+		if arr, ok := v.([]interface{}); ok && s.index < len(arr) {
+			arr[s.index] = value
+			replaced++
+		}
+	case stepWildcard:
+		switch c := v.(type) {
+		case map[string]interface{}:
+			for key := range c {
+				if replaced >= budget {
+					break
+				}
+				c[key] = value
+				replaced++
+			}
+		case []interface{}:
+			for k := 0; k < len(c) && replaced < budget; k++ {
+				c[k] = value
+				replaced++
+			}
+		}
+	}
+	return replaced
+}
+
+// apply appends the children of v selected by the step to out.
+func (s pathStep) apply(v interface{}, out []interface{}) []interface{} {
+	switch s.kind {
+	case stepKey:
+		if m, ok := v.(map[string]interface{}); ok {
+			if child, ok := m[s.key]; ok {
+				out = append(out, child)
+			}
+		}
+	case stepIndex:
+		if arr, ok := v.([]interface{}); ok && s.index < len(arr) {
+			out = append(out, arr[s.index])
+		}
+	case stepWildcard:
+		switch c := v.(type) {
+		case map[string]interface{}:
+			for _, child := range c {
+				if len(out) >= maxPathValues {
+					break
+				}
+				out = append(out, child)
+			}
+		case []interface{}:
+			for k := 0; k < len(c) && len(out) < maxPathValues; k++ {
+				out = append(out, c[k])
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,256 @@
+package observer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Bounds on match patterns.
+const (
+	maxPatternBytes = 256
+	maxGlobBraces   = 8 // Nesting depth of {a,b} alternatives
+)
+
+// PatternSet is a compiled list of match patterns, as written in match_methods
+// and match_actors. A pattern is one of:
+//
+//	stripe:refund          exact name
+//	aws:*, *:delete*       glob: * any run of characters, ? one character,
+//	fs:[rw]*, [!a-z]*      [...] a character class ([!...] negated),
+//	github:{create,delete}_repo   {a,b} alternatives, \ escapes the next character
+//	^aws:(iam|kms):.*      RE2 regex, opt-in by the leading ^ (not anchored at the end)
+//	!aws:describe*         exclusion: a glob or regex after !
+//
+// A name is selected when some inclusion matches and no exclusion does. Exact
+// names are looked up in a map; every other inclusion is folded into one regex,
+// as are the exclusions, so a match costs at most two regex runs however long
+// the list is.
+type PatternSet struct {
+	exact   map[string]struct{}
+	include *regexp.Regexp // nil when every inclusion is exact
+	exclude *regexp.Regexp // nil without exclusions
+}
+
+// CompilePatterns compiles a pattern list. It fails on empty or malformed
+// patterns and on lists made only of exclusions, which would select nothing.
+func CompilePatterns(patterns []string) (*PatternSet, error) {
+	if len(patterns) > maxRulePatterns {
+		return nil, fmt.Errorf("too many patterns: %d (max %d)", len(patterns), maxRulePatterns)
+	}
+	set := &PatternSet{exact: make(map[string]struct{}, len(patterns))}
+	var include, exclude []string
+	for i := 0; i < len(patterns); i++ {
+		pattern, negated := strings.CutPrefix(patterns[i], "!")
+		if pattern == "" {
+			return nil, fmt.Errorf("empty pattern %q", patterns[i])
+		}
+		if len(pattern) > maxPatternBytes {
+			return nil, fmt.Errorf("pattern %q exceeds %d bytes", patterns[i], maxPatternBytes)
+		}
+		if !negated && !strings.HasPrefix(pattern, "^") && !strings.ContainsAny(pattern, `*?[{\`) {
+			set.exact[pattern] = struct{}{}
+			continue
+		}
+		expr, err := patternRegex(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", patterns[i], err)
+		}
+		if negated {
+			exclude = append(exclude, expr)
+		} else {
+			include = append(include, expr)
+		}
+	}
+	if len(set.exact) == 0 && len(include) == 0 && len(exclude) > 0 {
+		return nil, fmt.Errorf("patterns %q only exclude; add an inclusion such as \"*\"", patterns)
+	}
+
+	var err error
+	if set.include, err = joinRegex(include); err != nil {
+		return nil, err
+	}
+	if set.exclude, err = joinRegex(exclude); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Match reports whether the set selects one of names: some name is included
+// and none is excluded. Empty names are ignored; a nil set selects nothing.
+func (s *PatternSet) Match(names ...string) bool {
+	if s == nil {
+		return false
+	}
+	included := false
+	for i := 0; i < len(names); i++ {
+		name := names[i]
+		if name == "" {
+			continue
+		}
+		if s.exclude != nil && s.exclude.MatchString(name) {
+			return false
+		}
+		if included {
+			continue
+		}
+		if _, ok := s.exact[name]; ok {
+			included = true
+		} else if s.include != nil && s.include.MatchString(name) {
+			included = true
+		}
+	}
+	return included
+}
+
+// MatchPattern checks if a method matches a single policy pattern (see
+// PatternSet; exclusions are not meaningful here and never match). The pattern
+// is compiled on every call, so hot paths should use a PatternSet.
+// Returns false if either pattern or method is empty or the pattern is malformed.
+func MatchPattern(pattern, method string) bool {
+	if pattern == "" || method == "" {
+		return false
+	}
+	if pattern == method {
+		return true
+	}
+	set, err := CompilePatterns([]string{pattern})
+	if err != nil {
+		return false
+	}
+	return set.Match(method)
+}
+
+// patternRegex translates a glob, or validates an opt-in regex, to an RE2
+// expression matched against the whole name (regexes: from the start).
+func patternRegex(pattern string) (string, error) {
+	if strings.HasPrefix(pattern, "^") {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return "", fmt.Errorf("invalid regex: %w", err)
+		}
+		return "(?:" + pattern + ")", nil
+	}
+	expr, err := translateGlob(pattern)
+	if err != nil {
+		return "", err
+	}
+	expr = "^(?:" + expr + ")$"
+	if _, err := regexp.Compile(expr); err != nil {
+		return "", fmt.Errorf("invalid glob: %w", err)
+	}
+	return expr, nil
+}
+
+// braceGroup is an open {a,b,...} group of a glob: the alternatives translated
+// so far and the one in progress.
+type braceGroup struct {
+	alts []string
+	cur  strings.Builder
+}
+
+// translateGlob translates glob syntax to an RE2 expression in one pass. Open
+// brace groups sit on a fixed stack of maxGlobBraces frames above the pattern
+// itself; ',' and '}' outside braces are literal.
+func translateGlob(s string) (string, error) {
+	var groups [maxGlobBraces + 1]braceGroup
+	top := 0
+	for i := 0; i < maxPatternBytes+1; i++ {
+		if s == "" {
+			if top > 0 {
+				return "", fmt.Errorf("unterminated '{'")
+			}
+			return groups[0].cur.String(), nil
+		}
+		g := &groups[top]
+		c := s[0]
+		switch {
+		case c == ',' && top > 0:
+			g.alts = append(g.alts, g.cur.String())
+			g.cur.Reset()
+			s = s[1:]
+		case c == '}' && top > 0:
+			alts := append(g.alts, g.cur.String())
+			if len(alts) < 2 {
+				return "", fmt.Errorf("braces need at least two alternatives")
+			}
+			groups[top] = braceGroup{}
+			top--
+			groups[top].cur.WriteString("(?:" + strings.Join(alts, "|") + ")")
+			s = s[1:]
+		case c == '{':
+			if top == maxGlobBraces {
+				return "", fmt.Errorf("braces nested deeper than %d", maxGlobBraces)
+			}
+			top++
+			s = s[1:]
+		case c == '*':
+			g.cur.WriteString(".*")
+			s = s[1:]
+		case c == '?':
+			g.cur.WriteString(".")
+			s = s[1:]
+		case c == '\\':
+			if len(s) < 2 {
+				return "", fmt.Errorf("trailing backslash")
+			}
+			g.cur.WriteString(regexp.QuoteMeta(s[1:2]))
+			s = s[2:]
+		case c == '[':
+			class, after, err := translateClass(s)
+			if err != nil {
+				return "", err
+			}
+			g.cur.WriteString(class)
+			s = after
+		default:
+			g.cur.WriteString(regexp.QuoteMeta(s[:1]))
+			s = s[1:]
+		}
+	}
+	return "", fmt.Errorf("pattern too long")
+}
+
+// translateClass translates a [...] character class at the start of s.
+func translateClass(s string) (expr, rest string, err error) {
+	end := strings.IndexByte(s[1:], ']')
+	if end < 0 {
+		return "", "", fmt.Errorf("unterminated '['")
+	}
+	body := s[1 : 1+end]
+	negated := false
+	if strings.HasPrefix(body, "!") || strings.HasPrefix(body, "^") {
+		negated = true
+		body = body[1:]
+	}
+	if body == "" {
+		return "", "", fmt.Errorf("empty character class")
+	}
+	var b strings.Builder
+	b.WriteByte('[')
+	if negated {
+		b.WriteByte('^')
+	}
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; c {
+		case '\\', '[', '^':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(']')
+	return b.String(), s[2+end:], nil
+}
+
+// joinRegex compiles expressions as one alternation, or returns nil for none.
+func joinRegex(exprs []string) (*regexp.Regexp, error) {
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+	re, err := regexp.Compile(strings.Join(exprs, "|"))
+	if err != nil {
+		return nil, fmt.Errorf("compiling patterns: %w", err)
+	}
+	return re, nil
+}
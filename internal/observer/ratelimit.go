@@ -0,0 +1,221 @@
+package observer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+// RateLimit turns a rule into a frequency rule: it only matches once more than
+// MaxCalls calls selected by its patterns and conditions were seen within the
+// sliding window. On match_on: error rules the errors are counted instead, keyed
+// by the call they answer. Per splits the count by the resolved action
+// ("method"), the caller ("actor") or the SEP-1686 task ("task"); empty counts
+// all calls together.
+type RateLimit struct {
+	MaxCalls      int    `yaml:"max_calls"`
+	WindowSeconds int    `yaml:"window_seconds"`
+	Per           string `yaml:"per,omitempty"`
+}
+
+// Values of RateLimit.Per.
+const (
+	RatePerMethod = "method"
+	RatePerActor  = "actor"
+	RatePerTask   = "task"
+)
+
+// RateOverflowKey is the shared counter key of a rule's calls once the counter
+// table is full, so that an agent spreading calls over many keys (tool names,
+// actors) counts towards a limit instead of escaping it.
+const RateOverflowKey = "(overflow)"
+
+const (
+	maxRateKeys       = 4096
+	maxRateCalls      = 1000000
+	maxRateWindowSecs = 86400
+)
+
+// Window returns the sliding window length.
+func (r *RateLimit) Window() time.Duration {
+	return time.Duration(r.WindowSeconds) * time.Second
+}
+
+// key returns the counter key of an action, and false when the action is not
+// counted (per-task limits ignore calls outside a task).
+func (r *RateLimit) key(a Action) (string, bool) {
+	switch r.Per {
+	case RatePerMethod:
+		return a.Name, true
+	case RatePerActor:
+		return a.Actor, true
+	case RatePerTask:
+		return a.TaskID, a.TaskID != ""
+	}
+	return "", true
+}
+
+func (r *RateLimit) validate() error {
+	if r.MaxCalls < 1 || r.MaxCalls > maxRateCalls {
+		return fmt.Errorf("rate_limit.max_calls must be between 1 and %d", maxRateCalls)
+	}
+	if r.WindowSeconds < 1 || r.WindowSeconds > maxRateWindowSecs {
+		return fmt.Errorf("rate_limit.window_seconds must be between 1 and %d", maxRateWindowSecs)
+	}
+	switch r.Per {
+	case "", RatePerMethod, RatePerActor, RatePerTask:
+		return nil
+	}
+	return fmt.Errorf("rate_limit.per must be method, actor or task, got %q", r.Per)
+}
+
+// RateWindow is a snapshot of one sliding-window counter, for metrics.
+type RateWindow struct {
+	PolicyID string
+	Per      string
+	Key      string
+	Calls    float64 // Estimated calls in the window ending now
+	Limit    int
+}
+
+type rateKey struct {
+	rule string
+	key  string
+}
+
+// rateWindow is a sliding-window counter: calls in the current fixed bucket plus
+// the previous bucket's calls weighted by how much of it the window still covers.
+type rateWindow struct {
+	start    time.Time
+	prev     int
+	curr     int
+	window   time.Duration
+	limit    int
+	per      string
+	lastSeen time.Time
+}
+
+func (w *rateWindow) advance(now time.Time) {
+	bucket := now.Truncate(w.window)
+	if bucket.Equal(w.start) {
+		return
+	}
+	if bucket.Sub(w.start) == w.window {
+		w.prev = w.curr
+	} else {
+		w.prev = 0
+	}
+	w.curr = 0
+	w.start = bucket
+}
+
+func (w *rateWindow) estimate(now time.Time) float64 {
+	remaining := float64(w.window-now.Sub(w.start)) / float64(w.window)
+	return float64(w.prev)*remaining + float64(w.curr)
+}
+
+// rateTracker holds the sliding-window counters of all rate-limited rules, keyed
+// by rule ID so they survive policy reloads.
+type rateTracker struct {
+	mu       sync.Mutex
+	windows  map[rateKey]*rateWindow
+	exceeded map[string]uint64
+}
+
+func newRateTracker() *rateTracker {
+	return &rateTracker{windows: make(map[rateKey]*rateWindow), exceeded: make(map[string]uint64)}
+}
+
+// over reports whether one more call selected by rule would exceed its limit.
+// With a nil pending the call is counted (and, if over, added to the exceeded
+// total); otherwise it is only tallied in pending on top of the live counts.
+// New keys arriving while the table is full share the rule's RateOverflowKey
+// counter, so a full table never lets calls through uncounted.
+func (t *rateTracker) over(rule *Rule, a Action, now time.Time, pending map[rateKey]int) bool {
+	limit := rule.RateLimit
+	key, counted := limit.key(a)
+	if !counted {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := rateKey{rule: rule.ID, key: key}
+	calls := 0.0
+	w, ok := t.windows[k]
+	if !ok && len(t.windows) >= maxRateKeys {
+		t.evictIdleLocked(now)
+		if len(t.windows) >= maxRateKeys {
+			logging.Warn("rate_counter_table_full", logging.Fields{Component: "observer", PolicyID: rule.ID})
+			k.key = RateOverflowKey
+			w, ok = t.windows[k]
+		}
+	}
+	if ok {
+		w.window, w.limit, w.per = limit.Window(), limit.MaxCalls, limit.Per
+		w.advance(now)
+		calls = w.estimate(now)
+	}
+	if pending != nil {
+		calls += float64(pending[k])
+		pending[k]++
+		return calls+1 > float64(limit.MaxCalls)
+	}
+
+	if !ok {
+		// Overflow counters (one per rule) may exceed maxRateKeys.
+		w = &rateWindow{start: now.Truncate(limit.Window()), window: limit.Window(), limit: limit.MaxCalls, per: limit.Per}
+		t.windows[k] = w
+	}
+	isOver := calls+1 > float64(limit.MaxCalls)
+	w.curr++
+	w.lastSeen = now
+	if isOver {
+		t.exceeded[rule.ID]++
+	}
+	return isOver
+}
+
+// evictIdleLocked drops counters idle for more than two windows.
+func (t *rateTracker) evictIdleLocked(now time.Time) {
+	for k, w := range t.windows {
+		if now.Sub(w.lastSeen) > 2*w.window {
+			delete(t.windows, k)
+		}
+	}
+}
+
+func (t *rateTracker) snapshot(now time.Time) ([]RateWindow, map[string]uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.evictIdleLocked(now)
+	windows := make([]RateWindow, 0, len(t.windows))
+	for k, w := range t.windows {
+		w.advance(now)
+		windows = append(windows, RateWindow{PolicyID: k.rule, Per: w.per, Key: k.key, Calls: w.estimate(now), Limit: w.limit})
+	}
+	sort.Slice(windows, func(a, b int) bool {
+		if windows[a].PolicyID != windows[b].PolicyID {
+			return windows[a].PolicyID < windows[b].PolicyID
+		}
+		return windows[a].Key < windows[b].Key
+	})
+	exceeded := make(map[string]uint64, len(t.exceeded))
+	for id, n := range t.exceeded {
+		exceeded[id] = n
+	}
+	return windows, exceeded
+}
+
+// RateWindows returns the current sliding-window counters of rate-limited rules
+// and, per rule ID, how many calls exceeded the limit since startup.
+func (e *ObserverEngine) RateWindows() ([]RateWindow, map[string]uint64) {
+	if e == nil || e.rates == nil {
+		return nil, nil
+	}
+	return e.rates.snapshot(time.Now())
+}
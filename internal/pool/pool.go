@@ -78,6 +78,18 @@ func PutEvent(e *models.Event) {
 	e.ParentID = ""
 	e.PolicyID = ""
 	e.RiskLevel = ""
+	e.BatchID = ""
+	e.LatencyMs = 0
+	e.HTTPStatus = 0
+	e.Upstream = ""
+	e.HTTP = nil
+	e.SessionID = ""
+	e.BodySize = 0
+	e.BodySHA256 = ""
+	e.BodyStorage = ""
+	e.BlobRefs = nil
+	e.TraceID = ""
+	e.SpanID = ""
 	e.WasBlocked = false
 
 	// Clear maps but keep allocated capacity
@@ -87,23 +99,23 @@ func PutEvent(e *models.Event) {
 	if err := assert.Check(len(e.Response) <= maxEventFields, "response map too large: %d", len(e.Response)); err != nil {
 		return
 	}
-	for i := 0; i < maxEventFields; i++ {
-		key := ""
-		found := false
-		for k := range e.Params {
-			key = k
-			found = true
-			break
-		}
-		if !found {
-			break
-		}
-		delete(e.Params, key)
+	clearFields(e.Params)
+	clearFields(e.Response)
+	// Callers may have replaced Params with nil; GetEvent promises a usable map.
+	if e.Params == nil {
+		e.Params = make(map[string]interface{}, 8)
 	}
+
+	eventPool.Put(e)
+}
+
+// clearFields empties an event map of at most maxEventFields entries, keeping
+// its allocated capacity.
+func clearFields(m map[string]interface{}) {
 	for i := 0; i < maxEventFields; i++ {
 		key := ""
 		found := false
-		for k := range e.Response {
+		for k := range m {
 			key = k
 			found = true
 			break
@@ -111,10 +123,8 @@ func PutEvent(e *models.Event) {
 		if !found {
 			break
 		}
-		delete(e.Response, key)
+		delete(m, key)
 	}
-
-	eventPool.Put(e)
 }
 
 var bufferPool = sync.Pool{
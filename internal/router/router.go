@@ -0,0 +1,223 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+const (
+	// DefaultName labels the --target upstream used when no route matches.
+	DefaultName = "default"
+	maxRoutes   = 64
+)
+
+// Target is a resolved upstream tool server.
+type Target struct {
+	Name        string
+	URL         *url.URL
+	stripPrefix string
+}
+
+// Router maps proxied requests to upstreams by path prefix, Host header or
+// tool-name pattern. Routes are tried in declaration order; every criterion set
+// on a route must match. Requests matching no route go to the default target.
+type Router struct {
+	routes       []route
+	fallback     *Target
+	hasToolRules bool
+}
+
+type route struct {
+	target      *Target
+	pathPrefix  string
+	host        string
+	toolPattern *observer.PatternSet // Compiled tool_prefix, nil when unset
+}
+
+type contextKey struct{}
+
+// New compiles the upstream table. defaultTarget is the --target URL.
+// Returns an error on duplicate or reserved names, unparsable targets, or
+// routes without any match criterion.
+func New(defaultTarget string, upstreams []observer.Upstream) (*Router, error) {
+	if err := assert.Check(len(upstreams) <= maxRoutes, "upstreams exceed max: %d", len(upstreams)); err != nil {
+		return nil, err
+	}
+	fallbackURL, err := parseTarget(defaultTarget)
+	if err != nil {
+		return nil, fmt.Errorf("default target: %w", err)
+	}
+
+	r := &Router{fallback: &Target{Name: DefaultName, URL: fallbackURL}}
+	seen := map[string]bool{DefaultName: true}
+	for i := 0; i < maxRoutes; i++ {
+		if i >= len(upstreams) {
+			break
+		}
+		u := upstreams[i]
+		if u.Name == "" {
+			return nil, fmt.Errorf("upstream %d: name must not be empty", i)
+		}
+		if seen[u.Name] {
+			return nil, fmt.Errorf("upstream %q: duplicate or reserved name", u.Name)
+		}
+		seen[u.Name] = true
+		if u.PathPrefix == "" && u.Host == "" && u.ToolPrefix == "" {
+			return nil, fmt.Errorf("upstream %q: needs path_prefix, host or tool_prefix", u.Name)
+		}
+		if u.PathPrefix != "" && !strings.HasPrefix(u.PathPrefix, "/") {
+			return nil, fmt.Errorf("upstream %q: path_prefix must start with /", u.Name)
+		}
+		targetURL, err := parseTarget(u.Target)
+		if err != nil {
+			return nil, fmt.Errorf("upstream %q: %w", u.Name, err)
+		}
+
+		var toolPattern *observer.PatternSet
+		if u.ToolPrefix != "" {
+			if toolPattern, err = observer.CompilePatterns([]string{u.ToolPrefix}); err != nil {
+				return nil, fmt.Errorf("upstream %q: tool_prefix: %w", u.Name, err)
+			}
+		}
+
+		t := &Target{Name: u.Name, URL: targetURL}
+		if u.StripPrefix {
+			t.stripPrefix = u.PathPrefix
+		}
+		r.routes = append(r.routes, route{
+			target:      t,
+			pathPrefix:  u.PathPrefix,
+			host:        strings.ToLower(u.Host),
+			toolPattern: toolPattern,
+		})
+		if u.ToolPrefix != "" {
+			r.hasToolRules = true
+		}
+	}
+	return r, nil
+}
+
+func parseTarget(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("target must not be empty")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("target %q must use http or https", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("target %q has no host", raw)
+	}
+	return u, nil
+}
+
+// HasToolRules reports whether any route needs the tool name, so callers can
+// skip decoding the body otherwise.
+func (r *Router) HasToolRules() bool {
+	if r == nil {
+		return false
+	}
+	return r.hasToolRules
+}
+
+// Resolve returns the upstream for a request. toolName is the params.name of a
+// tools/call request, or "" when there is none (other methods, batches, GETs).
+func (r *Router) Resolve(req *http.Request, toolName string) *Target {
+	if err := assert.NotNil(r, "router"); err != nil {
+		return nil
+	}
+	if err := assert.NotNil(req, "request"); err != nil {
+		return r.fallback
+	}
+	host := requestHost(req)
+	for i := 0; i < maxRoutes; i++ {
+		if i >= len(r.routes) {
+			break
+		}
+		rt := &r.routes[i]
+		if rt.pathPrefix != "" && !hasPathPrefix(req.URL.Path, rt.pathPrefix) {
+			continue
+		}
+		if rt.host != "" && rt.host != host {
+			continue
+		}
+		if rt.toolPattern != nil && !rt.toolPattern.Match(toolName) {
+			continue
+		}
+		return rt.target
+	}
+	return r.fallback
+}
+
+// hasPathPrefix matches whole path segments, so "/git" does not match "/github".
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || path[len(prefix)] == '/'
+}
+
+func requestHost(req *http.Request) string {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// WithTarget returns a copy of req whose context carries the chosen upstream.
+func WithTarget(req *http.Request, t *Target) *http.Request {
+	if err := assert.NotNil(req, "request"); err != nil {
+		return req
+	}
+	if t == nil {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), contextKey{}, t))
+}
+
+// TargetFrom returns the upstream recorded on a request context, if any.
+func TargetFrom(ctx context.Context) (*Target, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	t, ok := ctx.Value(contextKey{}).(*Target)
+	return t, ok
+}
+
+// Rewrite is the httputil.ReverseProxy Rewrite hook: it points the outbound
+// request at the upstream chosen for the inbound one (or the default target).
+func (r *Router) Rewrite(pr *httputil.ProxyRequest) {
+	if err := assert.NotNil(pr, "proxy request"); err != nil {
+		return
+	}
+	t, ok := TargetFrom(pr.In.Context())
+	if !ok {
+		t = r.fallback
+	}
+	if t.stripPrefix != "" {
+		path := strings.TrimPrefix(pr.Out.URL.Path, strings.TrimSuffix(t.stripPrefix, "/"))
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		pr.Out.URL.Path = path
+		pr.Out.URL.RawPath = ""
+	}
+	pr.SetURL(t.URL)
+	pr.SetXForwarded()
+}
@@ -0,0 +1,75 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"testing"
+
+	"github.com/slyt3/Logryph/internal/observer"
+)
+
+func testRouter(t *testing.T) *Router {
+	t.Helper()
+	r, err := New("http://localhost:8080", []observer.Upstream{
+		{Name: "github", Target: "http://gh:9000", ToolPrefix: "github:*"},
+		{Name: "files", Target: "http://files:9001/base", PathPrefix: "/files", StripPrefix: true},
+		{Name: "slack", Target: "https://slack:9002", Host: "slack.local"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return r
+}
+
+func TestResolve(t *testing.T) {
+	r := testRouter(t)
+	tests := []struct {
+		name, url, host, tool, want string
+	}{
+		{"tool prefix", "/mcp", "proxy", "github:create_issue", "github"},
+		{"tool prefix mismatch", "/mcp", "proxy", "gitlab:create_issue", DefaultName},
+		{"path prefix", "/files/mcp", "proxy", "", "files"},
+		{"path segment boundary", "/filesystem/mcp", "proxy", "", DefaultName},
+		{"host header with port", "/mcp", "Slack.Local:9999", "", "slack"},
+		{"fallback", "/mcp", "proxy", "", DefaultName},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.url, nil)
+		req.Host = tt.host
+		if got := r.Resolve(req, tt.tool); got.Name != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got.Name)
+		}
+	}
+}
+
+func TestRewriteStripsPrefix(t *testing.T) {
+	r := testRouter(t)
+	in := httptest.NewRequest(http.MethodPost, "/files/mcp", nil)
+	in = WithTarget(in, r.Resolve(in, ""))
+	pr := &httputil.ProxyRequest{In: in, Out: in.Clone(in.Context())}
+
+	r.Rewrite(pr)
+	if pr.Out.URL.Host != "files:9001" {
+		t.Errorf("expected host files:9001, got %s", pr.Out.URL.Host)
+	}
+	if pr.Out.URL.Path != "/base/mcp" {
+		t.Errorf("expected path /base/mcp, got %s", pr.Out.URL.Path)
+	}
+}
+
+func TestNewRejectsInvalidUpstreams(t *testing.T) {
+	tests := map[string][]observer.Upstream{
+		"reserved name": {{Name: DefaultName, Target: "http://a", PathPrefix: "/a"}},
+		"duplicate":     {{Name: "a", Target: "http://a", PathPrefix: "/a"}, {Name: "a", Target: "http://b", PathPrefix: "/b"}},
+		"no criteria":   {{Name: "a", Target: "http://a"}},
+		"bad scheme":    {{Name: "a", Target: "ftp://a", PathPrefix: "/a"}},
+		"relative path": {{Name: "a", Target: "http://a", PathPrefix: "a"}},
+		"bad tool glob": {{Name: "a", Target: "http://a", ToolPrefix: "github:[a"}},
+	}
+	for name, upstreams := range tests {
+		if _, err := New("http://localhost:8080", upstreams); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/slyt3/Logryph/internal/assert"
+	"github.com/slyt3/Logryph/internal/logging"
+)
+
+// WatchInterval is how often certificate files are checked for changes.
+const WatchInterval = 5 * time.Second
+
+// Files names the PEM files of a TLS listener. ClientCA is optional: when set,
+// clients must present a certificate that chains to one of its CAs (mutual TLS).
+type Files struct {
+	Cert     string
+	Key      string
+	ClientCA string
+}
+
+// Enabled reports whether TLS is configured.
+func (f Files) Enabled() bool {
+	return f.Cert != "" || f.Key != ""
+}
+
+// Validate checks that the files are set consistently.
+func (f Files) Validate() error {
+	if f.Cert == "" && f.Key == "" {
+		if f.ClientCA != "" {
+			return fmt.Errorf("client CA requires a certificate and key")
+		}
+		return nil
+	}
+	if f.Cert == "" || f.Key == "" {
+		return fmt.Errorf("certificate and key must be set together")
+	}
+	return nil
+}
+
+// Reloader serves a listener's certificate and client CA pool, reloading them
+// when their files change so certificates can be rotated without a restart. A
+// reload that fails (e.g. a key written before its certificate) keeps the
+// previous material and is retried on the next change.
+type Reloader struct {
+	files Files
+	label string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  [3]time.Time
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewReloader loads the files once and fails if they are unusable.
+func NewReloader(files Files, label string) (*Reloader, error) {
+	if err := files.Validate(); err != nil {
+		return nil, err
+	}
+	if err := assert.Check(files.Enabled(), "tls files must be set"); err != nil {
+		return nil, err
+	}
+	r := &Reloader{files: files, label: label, stopChan: make(chan struct{})}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the certificate, key and client CA bundle from disk.
+func (r *Reloader) Reload() error {
+	if err := assert.NotNil(r, "reloader"); err != nil {
+		return err
+	}
+	modTimes := r.stat()
+	cert, err := tls.LoadX509KeyPair(r.files.Cert, r.files.Key)
+	if err != nil {
+		return fmt.Errorf("loading %s certificate: %w", r.label, err)
+	}
+	if cert.Leaf == nil && len(cert.Certificate) > 0 {
+		cert.Leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	}
+	var pool *x509.CertPool
+	if r.files.ClientCA != "" {
+		pem, err := os.ReadFile(r.files.ClientCA)
+		if err != nil {
+			return fmt.Errorf("reading %s client CA: %w", r.label, err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s client CA %s contains no PEM certificates", r.label, r.files.ClientCA)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = pool
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	if cert.Leaf != nil && time.Now().After(cert.Leaf.NotAfter) {
+		logging.Warn("tls_certificate_expired", logging.Fields{Component: r.label + "_tls", Error: fmt.Sprintf("certificate expired %s", cert.Leaf.NotAfter.Format(time.RFC3339))})
+	}
+	return nil
+}
+
+func (r *Reloader) stat() [3]time.Time {
+	var mod [3]time.Time
+	paths := [3]string{r.files.Cert, r.files.Key, r.files.ClientCA}
+	for i := 0; i < len(paths); i++ {
+		if paths[i] == "" {
+			continue
+		}
+		if info, err := os.Stat(paths[i]); err == nil {
+			mod[i] = info.ModTime()
+		}
+	}
+	return mod
+}
+
+// MutualTLS reports whether client certificates are required.
+func (r *Reloader) MutualTLS() bool {
+	return r != nil && r.files.ClientCA != ""
+}
+
+// ServerConfig returns the TLS config for an http.Server. Every handshake uses
+// the most recently loaded certificate and client CA pool.
+func (r *Reloader) ServerConfig() *tls.Config {
+	protos := []string{"h2", "http/1.1"}
+	base := &tls.Config{MinVersion: tls.VersionTLS12, NextProtos: protos}
+	base.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.cert, nil
+	}
+	// http.Server adds its ALPN protocols to a clone of base, not base itself,
+	// so each handshake config lists them explicitly to keep HTTP/2 working.
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		cfg := &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{*r.cert},
+			NextProtos:   protos,
+		}
+		if r.clientCAs != nil {
+			cfg.ClientCAs = r.clientCAs
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		return cfg, nil
+	}
+	return base
+}
+
+// Watch reloads the files in the background whenever one of them changes.
+func (r *Reloader) Watch(interval time.Duration) {
+	if err := assert.NotNil(r, "reloader"); err != nil {
+		return
+	}
+	if err := assert.Check(interval > 0, "watch interval must be positive"); err != nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		const maxWatchTicks = 1 << 30
+		for i := 0; i < maxWatchTicks; i++ {
+			select {
+			case <-ticker.C:
+				if !r.changed() {
+					continue
+				}
+				if err := r.Reload(); err != nil {
+					logging.Warn("tls_reload_failed", logging.Fields{Component: r.label + "_tls", Error: err.Error()})
+					continue
+				}
+				logging.Info("tls_reloaded", logging.Fields{Component: r.label + "_tls"})
+			case <-r.stopChan:
+				return
+			}
+		}
+		if err := assert.Check(false, "tls watch loop exceeded max ticks"); err != nil {
+			return
+		}
+	}()
+}
+
+func (r *Reloader) changed() bool {
+	mod := r.stat()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i := 0; i < len(mod); i++ {
+		if mod[i].After(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop ends the watcher. Safe to call multiple times.
+func (r *Reloader) Stop() {
+	if r == nil {
+		return
+	}
+	r.stopOnce.Do(func() {
+		close(r.stopChan)
+	})
+}
@@ -0,0 +1,239 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// issue creates a certificate signed by parent, or a self-signed CA when parent is nil.
+func issue(t *testing.T, cn string, serial int64, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func startServer(t *testing.T, r *Reloader) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = r.ServerConfig()
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func client(ca *testCert, cert *testCert) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	cfg := &tls.Config{RootCAs: roots}
+	if cert != nil {
+		pair, _ := tls.X509KeyPair(cert.certPEM, cert.keyPEM)
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}, Timeout: 5 * time.Second}
+}
+
+func TestMutualTLSRequiresClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "test-ca", 1, nil)
+	server := issue(t, "logryph", 2, ca)
+	agent := issue(t, "agent-1", 3, ca)
+	stranger := issue(t, "stranger", 4, issue(t, "other-ca", 5, nil))
+	files := Files{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem"), ClientCA: filepath.Join(dir, "ca.pem")}
+	writeFile(t, files.Cert, server.certPEM)
+	writeFile(t, files.Key, server.keyPEM)
+	writeFile(t, files.ClientCA, ca.certPEM)
+
+	r, err := NewReloader(files, "proxy")
+	if err != nil {
+		t.Fatalf("reloader: %v", err)
+	}
+	srv := startServer(t, r)
+
+	if resp, err := client(ca, agent).Get(srv.URL); err != nil {
+		t.Fatalf("expected client signed by the CA to connect: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+	if resp, err := client(ca, nil).Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Error("expected client without a certificate to be rejected")
+	}
+	if resp, err := client(ca, stranger).Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Error("expected client signed by another CA to be rejected")
+	}
+}
+
+func TestReloadRotatesCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "test-ca", 1, nil)
+	first := issue(t, "logryph", 10, ca)
+	files := Files{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem")}
+	writeFile(t, files.Cert, first.certPEM)
+	writeFile(t, files.Key, first.keyPEM)
+
+	r, err := NewReloader(files, "admin")
+	if err != nil {
+		t.Fatalf("reloader: %v", err)
+	}
+	srv := startServer(t, r)
+	serial := func() int64 {
+		t.Helper()
+		resp, err := client(ca, nil).Get(srv.URL)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+	if got := serial(); got != 10 {
+		t.Fatalf("expected serial 10, got %d", got)
+	}
+
+	// A half-written rotation (new cert, old key) keeps serving the old pair.
+	second := issue(t, "logryph", 11, ca)
+	writeFile(t, files.Cert, second.certPEM)
+	if err := r.Reload(); err == nil {
+		t.Fatal("expected mismatched key to fail reload")
+	}
+	if got := serial(); got != 10 {
+		t.Fatalf("expected failed reload to keep serial 10, got %d", got)
+	}
+
+	writeFile(t, files.Key, second.keyPEM)
+	future := time.Now().Add(time.Minute)
+	for _, p := range []string{files.Cert, files.Key} {
+		if err := os.Chtimes(p, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !r.changed() {
+		t.Fatal("expected rotated files to be detected")
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := serial(); got != 11 {
+		t.Fatalf("expected rotated serial 11, got %d", got)
+	}
+}
+
+func TestFilesValidate(t *testing.T) {
+	cases := []struct {
+		files Files
+		ok    bool
+	}{
+		{Files{}, true},
+		{Files{Cert: "c", Key: "k"}, true},
+		{Files{Cert: "c", Key: "k", ClientCA: "ca"}, true},
+		{Files{Cert: "c"}, false},
+		{Files{ClientCA: "ca"}, false},
+	}
+	for _, c := range cases {
+		if err := c.files.Validate(); (err == nil) != c.ok {
+			t.Errorf("%+v: unexpected result %v", c.files, err)
+		}
+	}
+}
+
+func TestServerConfigNegotiatesHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, "test-ca", 1, nil)
+	server := issue(t, "logryph", 2, ca)
+	agent := issue(t, "agent-1", 3, ca)
+	files := Files{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem"), ClientCA: filepath.Join(dir, "ca.pem")}
+	writeFile(t, files.Cert, server.certPEM)
+	writeFile(t, files.Key, server.keyPEM)
+	writeFile(t, files.ClientCA, ca.certPEM)
+
+	r, err := NewReloader(files, "proxy")
+	if err != nil {
+		t.Fatalf("reloader: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Serve like main does, so the server's own ALPN handling is exercised.
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}),
+		TLSConfig: r.ServerConfig(),
+	}
+	go srv.ServeTLS(ln, "", "")
+	t.Cleanup(func() { srv.Close() })
+
+	c := client(ca, agent)
+	c.Transport.(*http.Transport).ForceAttemptHTTP2 = true
+	resp, err := c.Get("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 || resp.TLS == nil || resp.TLS.NegotiatedProtocol != "h2" {
+		t.Fatalf("expected h2, got %s (ALPN %q)", resp.Proto, resp.TLS.NegotiatedProtocol)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"log"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
@@ -20,6 +19,8 @@ import (
 	"github.com/slyt3/Logryph/internal/ledger"
 	"github.com/slyt3/Logryph/internal/ledger/store"
 	"github.com/slyt3/Logryph/internal/observer"
+	"github.com/slyt3/Logryph/internal/router"
+	"github.com/slyt3/Logryph/internal/tlsconfig"
 )
 
 const (
@@ -27,28 +28,98 @@ const (
 	shutdownTimeout = 10 * time.Second
 )
 
-func main() {
-	configPath := flag.String("config", "logryph-policy.yaml", "path to policy configuration")
-	target := flag.String("target", "http://localhost:8080", "target tool server URL")
-	listenPort := flag.Int("port", 9999, "port to listen on")
-	backpressure := flag.String("backpressure", "drop", "backpressure strategy: 'drop' (fail-open) or 'block' (fail-closed)")
-	flag.Parse()
+// options holds the command-line flags.
+type options struct {
+	configPath   string
+	target       string
+	listenPort   int
+	backpressure string
+	stdioMode    bool
+	adminListen  string
+	proxyTLS     tlsconfig.Files
+	adminTLS     tlsconfig.Files
+}
 
-	if err := assert.Check(*target != "", "target must not be empty"); err != nil {
-		log.Fatalf("Invalid target: %v", err)
-	}
-	if err := assert.Check(*listenPort > 0, "listen port must be positive"); err != nil {
-		log.Fatalf("Invalid listen port: %v", err)
-	}
+func main() {
+	opts := parseFlags()
 
 	// 1. Load Observer Rules
-	obsEngine, err := observer.NewObserverEngine(*configPath)
+	obsEngine, err := observer.NewObserverEngine(opts.configPath)
 	if err != nil {
 		log.Fatalf("Failed to load observer rules: %v", err)
 	}
 	obsEngine.Watch()
 
 	// 2. Initialize Ledger Store & Worker
+	worker := startWorker(opts.backpressure)
+
+	// 3. Initialize Core Engine
+	engine := core.NewEngine(worker, obsEngine)
+
+	// 4. Initialize Interceptor
+	interceptorSvc := interceptor.NewInterceptor(engine)
+	interceptorSvc.WatchSessions()
+
+	// 5. Initialize API Handlers
+	var servers []namedServer
+	if opts.adminListen != "" {
+		servers = append(servers, startAdmin(opts.adminListen, api.NewHandlers(engine), opts.adminTLS))
+	}
+
+	// 6. Stdio mode: relay to a subprocess instead of proxying HTTP
+	if opts.stdioMode {
+		exitCode := runStdio(interceptorSvc, flag.Args())
+		gracefulShutdown(obsEngine, interceptorSvc, worker, shutdownTimeout, servers...)
+		os.Exit(exitCode)
+	}
+
+	// 7. Setup Proxy (--target plus any upstreams routed from the policy file)
+	proxy := startProxy(interceptorSvc, obsEngine.GetUpstreams(), opts)
+	servers = append([]namedServer{proxy}, servers...)
+
+	shutdownSignal := waitForShutdownSignal(syscall.SIGINT, syscall.SIGTERM)
+	log.Printf("Shutdown signal received: %v", shutdownSignal)
+	gracefulShutdown(obsEngine, interceptorSvc, worker, shutdownTimeout, servers...)
+}
+
+// parseFlags parses and validates the command line. Invalid flags are fatal.
+func parseFlags() options {
+	var opts options
+	flag.StringVar(&opts.configPath, "config", "logryph-policy.yaml", "path to policy configuration")
+	flag.StringVar(&opts.target, "target", "http://localhost:8080", "target tool server URL")
+	flag.IntVar(&opts.listenPort, "port", 9999, "port to listen on")
+	flag.StringVar(&opts.backpressure, "backpressure", "drop", "backpressure strategy: 'drop' (fail-open) or 'block' (fail-closed)")
+	flag.BoolVar(&opts.stdioMode, "stdio", false, "wrap a local MCP server over stdio instead of proxying HTTP (server command follows --)")
+	flag.StringVar(&opts.adminListen, "admin", adminAddr, "admin API listen address (empty disables the admin API)")
+	flag.StringVar(&opts.proxyTLS.Cert, "tls-cert", "", "PEM certificate for the proxy listener (enables HTTPS)")
+	flag.StringVar(&opts.proxyTLS.Key, "tls-key", "", "PEM private key for the proxy listener")
+	flag.StringVar(&opts.proxyTLS.ClientCA, "tls-client-ca", "", "PEM CA bundle; proxy clients must present a certificate it signed (mTLS)")
+	flag.StringVar(&opts.adminTLS.Cert, "admin-tls-cert", "", "PEM certificate for the admin listener (enables HTTPS)")
+	flag.StringVar(&opts.adminTLS.Key, "admin-tls-key", "", "PEM private key for the admin listener")
+	flag.StringVar(&opts.adminTLS.ClientCA, "admin-tls-client-ca", "", "PEM CA bundle; admin clients must present a certificate it signed (mTLS)")
+	flag.Parse()
+
+	if err := assert.Check(opts.target != "", "target must not be empty"); err != nil {
+		log.Fatalf("Invalid target: %v", err)
+	}
+	if err := assert.Check(opts.listenPort > 0, "listen port must be positive"); err != nil {
+		log.Fatalf("Invalid listen port: %v", err)
+	}
+	if opts.stdioMode && flag.NArg() == 0 {
+		log.Fatalf("Stdio mode requires a server command, e.g. logryph --stdio -- npx some-mcp-server")
+	}
+	if err := opts.proxyTLS.Validate(); err != nil {
+		log.Fatalf("Invalid proxy TLS: %v", err)
+	}
+	if err := opts.adminTLS.Validate(); err != nil {
+		log.Fatalf("Invalid admin TLS: %v", err)
+	}
+	return opts
+}
+
+// startWorker opens the ledger database and starts the worker with the given
+// backpressure strategy. Failures are fatal.
+func startWorker(backpressure string) *ledger.Worker {
 	db, err := store.NewDB("logryph.db")
 	if err != nil {
 		log.Fatalf("Database init failed: %v", err)
@@ -57,7 +128,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("Worker init failed: %v", err)
 	}
-	switch *backpressure {
+	switch backpressure {
 	case "block":
 		if err := worker.SetBackpressureMode(ledger.BackpressureBlock); err != nil {
 			log.Fatalf("Failed to set backpressure mode: %v", err)
@@ -69,41 +140,132 @@ func main() {
 		}
 		log.Printf("Backpressure mode: DROP (fail-open, default) - events dropped if buffer is full")
 	default:
-		log.Fatalf("Invalid backpressure mode '%s': must be 'drop' or 'block'", *backpressure)
+		log.Fatalf("Invalid backpressure mode '%s': must be 'drop' or 'block'", backpressure)
 	}
 	if err := worker.Start(); err != nil {
 		log.Fatalf("Worker start failed: %v", err)
 	}
+	return worker
+}
 
-	// 3. Initialize Core Engine
-	engine := core.NewEngine(worker, obsEngine)
-
-	// 4. Initialize Interceptor
-	interceptorSvc := interceptor.NewInterceptor(engine)
-
-	// 5. Initialize API Handlers
-	apiHandlers := api.NewHandlers(engine)
+// startAdmin starts the admin API on addr, with TLS when files are configured.
+func startAdmin(addr string, apiHandlers *api.Handlers, files tlsconfig.Files) namedServer {
+	adminServer := newAdminServer(addr, apiHandlers)
+	admin := namedServer{server: adminServer, label: "Admin API"}
+	admin.certs = enableTLS(adminServer, files, "admin")
+	log.Printf("Admin API: %s%s", addr, tlsMode(admin.certs))
+	if os.Getenv("LOGRYPH_ADMIN_TOKEN") == "" && files.ClientCA == "" {
+		log.Printf("WARNING: neither LOGRYPH_ADMIN_TOKEN nor --admin-tls-client-ca is set; /api/rekey and /api/approvals will refuse every request")
+	}
+	startHTTPServer(adminServer, "Admin API")
+	return admin
+}
 
-	// 6. Setup Proxy
-	targetURL, err := url.Parse(*target)
+// startProxy routes the proxy to --target and the policy's upstreams and starts
+// listening on --port, with TLS when configured. Invalid routing is fatal.
+func startProxy(interceptorSvc *interceptor.Interceptor, upstreams []observer.Upstream, opts options) namedServer {
+	proxyRouter, err := router.New(opts.target, upstreams)
 	if err != nil {
-		log.Fatalf("Invalid target URL: %v", err)
+		log.Fatalf("Invalid upstream routing: %v", err)
+	}
+	interceptorSvc.Router = proxyRouter
+	reverseProxy := &httputil.ReverseProxy{
+		Rewrite:        proxyRouter.Rewrite,
+		ModifyResponse: interceptorSvc.InterceptResponse,
 	}
-	reverseProxy := httputil.NewSingleHostReverseProxy(targetURL)
-	reverseProxy.ModifyResponse = interceptorSvc.InterceptResponse
 
 	wrappedProxy := buildProxyHandler(interceptorSvc, reverseProxy)
-	adminServer := newAdminServer(apiHandlers)
-	proxyServer := newProxyServer(*listenPort, wrappedProxy)
+	proxyServer := newProxyServer(opts.listenPort, wrappedProxy)
+	proxy := namedServer{server: proxyServer, label: "Proxy Server"}
+	proxy.certs = enableTLS(proxyServer, opts.proxyTLS, "proxy")
 
-	log.Printf("Admin API: %s", adminAddr)
-	startHTTPServer(adminServer, "Admin API")
-	log.Printf("Proxy Server: :%d -> %s", *listenPort, *target)
+	log.Printf("Proxy Server: :%d%s -> %s", opts.listenPort, tlsMode(proxy.certs), opts.target)
+	for i := 0; i < len(upstreams); i++ {
+		log.Printf("Upstream %s: %s", upstreams[i].Name, upstreams[i].Target)
+	}
 	startHTTPServer(proxyServer, "Proxy Server")
+	return proxy
+}
 
-	shutdownSignal := waitForShutdownSignal(syscall.SIGINT, syscall.SIGTERM)
-	log.Printf("Shutdown signal received: %v", shutdownSignal)
-	gracefulShutdown(obsEngine, worker, adminServer, proxyServer, shutdownTimeout)
+// namedServer pairs an HTTP server with the label used in shutdown logs and,
+// when it serves TLS, the reloader of its certificates.
+type namedServer struct {
+	server *http.Server
+	label  string
+	certs  *tlsconfig.Reloader
+}
+
+// enableTLS configures server for HTTPS from files and starts watching them for
+// rotation. Returns nil (plain HTTP) when no certificate is configured.
+func enableTLS(server *http.Server, files tlsconfig.Files, label string) *tlsconfig.Reloader {
+	if err := assert.NotNil(server, "server"); err != nil {
+		return nil
+	}
+	if !files.Enabled() {
+		return nil
+	}
+	certs, err := tlsconfig.NewReloader(files, label)
+	if err != nil {
+		log.Fatalf("TLS init failed: %v", err)
+	}
+	server.TLSConfig = certs.ServerConfig()
+	certs.Watch(tlsconfig.WatchInterval)
+	return certs
+}
+
+func tlsMode(certs *tlsconfig.Reloader) string {
+	switch {
+	case certs == nil:
+		return ""
+	case certs.MutualTLS():
+		return " (mTLS)"
+	}
+	return " (TLS)"
+}
+
+// runStdio spawns the MCP server command and relays the agent's stdin/stdout to it
+// until the server exits or a shutdown signal arrives. Returns the process exit code.
+func runStdio(interceptorSvc *interceptor.Interceptor, command []string) int {
+	if err := assert.NotNil(interceptorSvc, "interceptor"); err != nil {
+		return 1
+	}
+	if err := assert.Check(len(command) > 0, "stdio command must not be empty"); err != nil {
+		return 1
+	}
+
+	relay, err := interceptor.NewStdioRelay(interceptorSvc, command[0], command[1:])
+	if err != nil {
+		log.Printf("Stdio relay init failed: %v", err)
+		return 1
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- relay.Run(os.Stdin, os.Stdout)
+	}()
+	log.Printf("Stdio relay: %v", command)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Printf("Stdio relay failed: %v", err)
+			return 1
+		}
+	case sig := <-sigCh:
+		log.Printf("Shutdown signal received: %v", sig)
+		if err := relay.Stop(); err != nil {
+			log.Printf("[WARN] stopping stdio server failed: %v", err)
+		}
+		<-done
+	}
+	if code := relay.ExitCode(); code > 0 {
+		return code
+	}
+	return 0
 }
 
 func buildProxyHandler(interceptorSvc *interceptor.Interceptor, reverseProxy *httputil.ReverseProxy) http.Handler {
@@ -115,27 +277,32 @@ func buildProxyHandler(interceptorSvc *interceptor.Interceptor, reverseProxy *ht
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		interceptorSvc.InterceptRequest(r)
-		reverseProxy.ServeHTTP(w, r)
+		if forward := interceptorSvc.InterceptRequest(w, r); forward != nil {
+			// Tee selected read-only calls to the shadow server, if configured.
+			interceptorSvc.Mirror(forward)
+			reverseProxy.ServeHTTP(w, forward)
+		}
 	})
 }
 
-func newAdminServer(apiHandlers *api.Handlers) *http.Server {
+func newAdminServer(addr string, apiHandlers *api.Handlers) *http.Server {
 	if err := assert.NotNil(apiHandlers, "api handlers"); err != nil {
 		return &http.Server{}
 	}
-	if err := assert.Check(adminAddr != "", "admin addr must not be empty"); err != nil {
+	if err := assert.Check(addr != "", "admin addr must not be empty"); err != nil {
 		return &http.Server{}
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/rekey", apiHandlers.HandleRekey)
+	mux.HandleFunc("/api/approvals", apiHandlers.HandleApprovals)
+	mux.HandleFunc("/api/approvals/", apiHandlers.HandleApprovalDecision)
 	mux.HandleFunc("/api/metrics", apiHandlers.HandleStats)
 	mux.HandleFunc("/metrics", apiHandlers.HandlePrometheus)
 	mux.HandleFunc("/healthz", apiHandlers.HandleHealth)
 	mux.HandleFunc("/readyz", apiHandlers.HandleReady)
 
-	return &http.Server{Addr: adminAddr, Handler: mux}
+	return &http.Server{Addr: addr, Handler: mux}
 }
 
 func newProxyServer(port int, handler http.Handler) *http.Server {
@@ -159,7 +326,14 @@ func startHTTPServer(server *http.Server, label string) {
 	}
 
 	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if server.TLSConfig != nil {
+			// Certificates come from TLSConfig, so no files are passed.
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("%s error: %v", label, err)
 		}
 	}()
@@ -193,26 +367,31 @@ func waitForShutdownSignal(signals ...os.Signal) os.Signal {
 	return <-sigCh
 }
 
-func gracefulShutdown(obsEngine *observer.ObserverEngine, worker *ledger.Worker, adminServer *http.Server, proxyServer *http.Server, timeout time.Duration) {
+func gracefulShutdown(obsEngine *observer.ObserverEngine, interceptorSvc *interceptor.Interceptor, worker *ledger.Worker, timeout time.Duration, servers ...namedServer) {
 	if err := assert.NotNil(obsEngine, "observer engine"); err != nil {
 		return
 	}
-	if err := assert.NotNil(worker, "worker"); err != nil {
-		return
-	}
-	if err := assert.NotNil(adminServer, "admin server"); err != nil {
+	if err := assert.NotNil(interceptorSvc, "interceptor"); err != nil {
 		return
 	}
-	if err := assert.NotNil(proxyServer, "proxy server"); err != nil {
+	if err := assert.NotNil(worker, "worker"); err != nil {
 		return
 	}
 	if err := assert.Check(timeout > 0, "timeout must be positive"); err != nil {
 		return
 	}
 
-	shutdownHTTPServer(proxyServer, timeout, "Proxy Server")
-	shutdownHTTPServer(adminServer, timeout, "Admin API")
+	// Release held calls first so their proxy handlers can return.
+	interceptorSvc.RejectPendingApprovals()
+
+	// Servers are stopped in the order given (proxy before admin).
+	for i := 0; i < len(servers); i++ {
+		shutdownHTTPServer(servers[i].server, timeout, servers[i].label)
+		servers[i].certs.Stop()
+	}
 
+	// Record session_ended for open sessions while the worker still accepts events.
+	interceptorSvc.Stop()
 	if err := obsEngine.Stop(); err != nil {
 		log.Printf("[WARN] observer stop failed: %v", err)
 	}